@@ -15,11 +15,9 @@ const EditorRoute = () => {
 
   // Ensure user ID exists
   useEffect(() => {
+    // The ID proves ownership of rooms, so it must be hard to guess
     if (!localStorage.getItem("notex_user_id")) {
-      localStorage.setItem(
-        "notex_user_id",
-        "user_" + Math.random().toString(36).substr(2, 9),
-      );
+      localStorage.setItem("notex_user_id", "user_" + crypto.randomUUID());
     }
   }, []);
 
@@ -31,12 +29,14 @@ const EditorRoute = () => {
           `${
             import.meta.env.VITE_API_URL || "http://localhost:8080"
           }/api/rooms/${roomSlug}`,
+          {
+            headers: {
+              "X-User-ID": localStorage.getItem("notex_user_id") || "",
+            },
+          },
         )
         .then((res) => {
-          const currentUserId = localStorage.getItem("notex_user_id");
-          if (res.data.owner === currentUserId) {
-            setIsOwner(true);
-          }
+          setIsOwner(!!res.data.isOwner);
         })
         .catch((err) => {
           console.error(
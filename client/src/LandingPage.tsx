@@ -10,6 +10,21 @@ export const LandingPage: React.FC = () => {
   const [showAnimation, setShowAnimation] = useState(true);
   const [joinRoomCode, setJoinRoomCode] = useState("");
   const [customSlug, setCustomSlug] = useState("");
+  // Burn after reading: "off", "read" (first open) or "leave" (first viewer leaves)
+  const [burnMode, setBurnMode] = useState("off");
+  // Optional email for a heads-up before the room expires
+  const [contact, setContact] = useState("");
+  // Built-in templates plus the ones this user saved from their rooms
+  const [templates, setTemplates] = useState<
+    { id: string; name: string; builtIn?: boolean }[]
+  >([]);
+  const [template, setTemplate] = useState("");
+  const [slugCheck, setSlugCheck] = useState<{
+    slug: string;
+    available: boolean;
+    error?: string;
+    suggestions: string[];
+  } | null>(null);
   const [username, setUsername] = useState(
     localStorage.getItem("notex_username") || "",
   );
@@ -23,6 +38,46 @@ export const LandingPage: React.FC = () => {
     // }
   }, []);
 
+  useEffect(() => {
+    const userId = localStorage.getItem("notex_user_id");
+    axios
+      .get(
+        `${import.meta.env.VITE_API_URL || "http://localhost:8080"}/api/templates`,
+        { headers: userId ? { "X-User-ID": userId } : {} },
+      )
+      .then((res) => setTemplates(res.data))
+      .catch((e) => console.error("Failed to load templates", e));
+  }, []);
+
+  // Check custom slug availability while typing
+  useEffect(() => {
+    const slug = customSlug.trim().toLowerCase();
+    if (!slug) {
+      setSlugCheck(null);
+      return;
+    }
+
+    const controller = new AbortController();
+    const timer = setTimeout(async () => {
+      try {
+        const res = await axios.get(
+          `${
+            import.meta.env.VITE_API_URL || "http://localhost:8080"
+          }/api/slugs/${encodeURIComponent(slug)}`,
+          { signal: controller.signal },
+        );
+        setSlugCheck(res.data);
+      } catch (e) {
+        if (!axios.isCancel(e)) setSlugCheck(null);
+      }
+    }, 300);
+
+    return () => {
+      clearTimeout(timer);
+      controller.abort();
+    };
+  }, [customSlug]);
+
   const handleAnimationComplete = () => {
     setShowAnimation(false);
     // sessionStorage.setItem("notex_intro_seen", "true");
@@ -32,10 +87,8 @@ export const LandingPage: React.FC = () => {
   const getUserId = () => {
     let id = localStorage.getItem("notex_user_id");
     if (!id) {
-      id =
-        "user_" +
-        Math.random().toString(36).substr(2, 9) +
-        Date.now().toString(36);
+      // The ID proves ownership of rooms, so it must be hard to guess
+      id = "user_" + crypto.randomUUID();
       localStorage.setItem("notex_user_id", id);
     }
     return id;
@@ -74,6 +127,17 @@ export const LandingPage: React.FC = () => {
       if (customSlug.trim()) {
         payload.customSlug = customSlug.trim().toLowerCase();
       }
+      if (contact.trim()) {
+        payload.contact = contact.trim();
+      }
+      if (template) {
+        payload.template = template;
+      }
+      if (burnMode === "read") {
+        payload.burnAfterReads = 1;
+      } else if (burnMode === "leave") {
+        payload.burnOnDisconnect = true;
+      }
 
       const res = await axios.post(
         `${import.meta.env.VITE_API_URL || "http://localhost:8080"}/api/rooms`,
@@ -148,6 +212,108 @@ export const LandingPage: React.FC = () => {
             >
               Max 2 words • Leave empty for auto-generated
             </small>
+            {slugCheck && !slugCheck.available && (
+              <div className="slug-check">
+                <small style={{ color: "#f87171", fontSize: "0.7em" }}>
+                  {slugCheck.error}
+                </small>
+                {slugCheck.suggestions.length > 0 && (
+                  <div
+                    style={{
+                      display: "flex",
+                      flexWrap: "wrap",
+                      gap: "4px",
+                      marginTop: "4px",
+                    }}
+                  >
+                    {slugCheck.suggestions.map((s) => (
+                      <button
+                        key={s}
+                        type="button"
+                        className="btn-secondary"
+                        style={{ fontSize: "0.7em", padding: "2px 8px" }}
+                        onClick={() => setCustomSlug(s)}
+                      >
+                        {s}
+                      </button>
+                    ))}
+                  </div>
+                )}
+              </div>
+            )}
+          </div>
+
+          {templates.length > 0 && (
+            <div className="input-group" style={{ marginBottom: "8px" }}>
+              <label
+                style={{
+                  fontSize: "0.85em",
+                  marginBottom: "4px",
+                  opacity: 0.8,
+                }}
+              >
+                Start From
+              </label>
+              <select
+                value={template}
+                onChange={(e) => setTemplate(e.target.value)}
+                className="glass-input"
+                style={{
+                  fontSize: "0.85em",
+                  padding: "8px 12px",
+                  height: "38px",
+                }}
+              >
+                <option value="">Blank page</option>
+                {templates.map((t) => (
+                  <option key={t.id} value={t.id}>
+                    {t.builtIn ? t.name : `${t.name} (mine)`}
+                  </option>
+                ))}
+              </select>
+            </div>
+          )}
+
+          <div className="input-group" style={{ marginBottom: "8px" }}>
+            <label
+              style={{ fontSize: "0.85em", marginBottom: "4px", opacity: 0.8 }}
+            >
+              Self-destruct
+            </label>
+            <select
+              value={burnMode}
+              onChange={(e) => setBurnMode(e.target.value)}
+              className="glass-input"
+              style={{
+                fontSize: "0.85em",
+                padding: "8px 12px",
+                height: "38px",
+              }}
+            >
+              <option value="off">Never</option>
+              <option value="read">After it is opened once</option>
+              <option value="leave">When the first viewer leaves</option>
+            </select>
+          </div>
+
+          <div className="input-group" style={{ marginBottom: "8px" }}>
+            <label
+              style={{ fontSize: "0.85em", marginBottom: "4px", opacity: 0.8 }}
+            >
+              Email (Optional)
+            </label>
+            <input
+              type="email"
+              placeholder="Warn me before the room expires"
+              value={contact}
+              onChange={(e) => setContact(e.target.value)}
+              className="glass-input"
+              style={{
+                fontSize: "0.85em",
+                padding: "8px 12px",
+                height: "38px",
+              }}
+            />
           </div>
 
           <div className="actions">
@@ -2,7 +2,12 @@ import React, { useEffect, useState } from "react";
 import { useNavigate } from "react-router-dom";
 import { AlertCircle } from "lucide-react";
 
-export const NotFoundView: React.FC = () => {
+interface NotFoundViewProps {
+  // The room burned after reading rather than never existing
+  burned?: boolean;
+}
+
+export const NotFoundView: React.FC<NotFoundViewProps> = ({ burned }) => {
   const navigate = useNavigate();
   const [countdown, setCountdown] = useState(3);
 
@@ -67,10 +72,14 @@ export const NotFoundView: React.FC = () => {
           <AlertCircle size={48} />
         </div>
 
-        <h1 style={{ fontSize: "2rem", margin: 0 }}>Room Not Found</h1>
+        <h1 style={{ fontSize: "2rem", margin: 0 }}>
+          {burned ? "Already Read" : "Room Not Found"}
+        </h1>
 
         <p style={{ color: "var(--text-dim)", fontSize: "1.1rem" }}>
-          This room does not exist or has been deleted by the owner.
+          {burned
+            ? "This room self-destructed after it was read. Its content is gone for good."
+            : "This room does not exist or has been deleted by the owner."}
         </p>
 
         <div
@@ -1,4 +1,4 @@
-import React, { useEffect, useState } from "react";
+import React, { useEffect, useRef, useState } from "react";
 import { useEditor, EditorContent } from "@tiptap/react";
 import StarterKit from "@tiptap/starter-kit";
 import Collaboration from "@tiptap/extension-collaboration";
@@ -23,10 +23,28 @@ import { UsersSidebar } from "./UsersSidebar";
 import { FilesModal } from "./FilesModal";
 import { Toolbar } from "./Toolbar";
 import axios from "axios";
-import { Users, LogOut, Trash, Save, Loader2, File } from "lucide-react";
+import {
+  Users,
+  LogOut,
+  Trash,
+  Save,
+  Loader2,
+  File,
+  Pencil,
+  GitFork,
+  LayoutTemplate,
+} from "lucide-react";
 import { useNavigate } from "react-router-dom";
 import { cacheManager } from "../utils/SmartCacheManager";
 import { NotFoundView } from "../components/NotFoundView";
+import {
+  CLOSE_KICKED,
+  CLOSE_ROOM_BURNED,
+  CONTROL_EVENT,
+  MESSAGE_CONTROL,
+  isFinalClose,
+  onControlEvent,
+} from "../utils/controlEvents";
 
 interface EditorProps {
   roomSlug: string;
@@ -48,7 +66,7 @@ const cursorColors = [
 
 const TiptapEditor: React.FC<{
   provider: WebsocketProvider;
-  userDetails: { name: string; color: string; userId: string };
+  userDetails: { name: string; color: string; peerId: string };
   roomSlug: string;
   status: string;
   isOwner: boolean;
@@ -58,6 +76,9 @@ const TiptapEditor: React.FC<{
   setShowFilesModal: (show: boolean) => void;
   handleLeave: () => void;
   handleDeleteRoom: () => void;
+  handleRenameRoom: () => void;
+  handleForkRoom: () => void;
+  handleSaveTemplate: (doc: any) => void;
   handleSave: () => void;
   initialContent: any; // Add initial content prop
 }> = ({
@@ -72,6 +93,9 @@ const TiptapEditor: React.FC<{
   setShowFilesModal,
   handleLeave,
   handleDeleteRoom,
+  handleRenameRoom,
+  handleForkRoom,
+  handleSaveTemplate,
   handleSave,
   initialContent,
 }) => {
@@ -171,6 +195,31 @@ const TiptapEditor: React.FC<{
               >
                 <LogOut size={20} />
               </button>
+              <button
+                onClick={handleForkRoom}
+                className="btn-icon"
+                title="Fork Room"
+              >
+                <GitFork size={20} />
+              </button>
+              {isOwner && (
+                <button
+                  onClick={() => editor && handleSaveTemplate(editor.getJSON())}
+                  className="btn-icon"
+                  title="Save as Template"
+                >
+                  <LayoutTemplate size={20} />
+                </button>
+              )}
+              {isOwner && (
+                <button
+                  onClick={handleRenameRoom}
+                  className="btn-icon"
+                  title="Rename Room"
+                >
+                  <Pencil size={20} />
+                </button>
+              )}
               {isOwner && (
                 <button
                   onClick={handleDeleteRoom}
@@ -253,6 +302,13 @@ export const Editor: React.FC<EditorProps> = ({
   const [ydoc, setYdoc] = useState<Y.Doc | null>(null);
   const [saving, setSaving] = useState(false);
   const [notFound, setNotFound] = useState(false);
+  const [burned, setBurned] = useState(false);
+  // Set when our own read burned the room: we keep the last copy on screen
+  const lastCopy = useRef(false);
+  const keptCopy = useRef(false);
+  // ETag of the server snapshot our document already contains; saves send
+  // it as If-Match so they never overwrite a snapshot we have not seen
+  const snapshotTag = useRef<string | null>(null);
   const [initialContent, setInitialContent] = useState<any>(null); // State for initial content
   const [showUsers, setShowUsers] = useState(() => {
     const saved = localStorage.getItem("notex_show_users");
@@ -261,12 +317,76 @@ export const Editor: React.FC<EditorProps> = ({
   const [showFilesModal, setShowFilesModal] = useState(false);
   const [files, setFiles] = useState<any[]>([]);
   const [uploading, setUploading] = useState(false);
+  // Slug shown in the UI; requests keep using roomSlug, which still
+  // resolves as an alias after a rename
+  const [currentSlug, setCurrentSlug] = useState(roomSlug);
   const navigate = useNavigate();
 
   const handleLeave = () => {
     navigate("/");
   };
 
+  const handleRenameRoom = async () => {
+    const slug = prompt("New room name", currentSlug)?.trim().toLowerCase();
+    if (!slug || slug === currentSlug) return;
+    try {
+      await axios.patch(
+        `${
+          import.meta.env.VITE_API_URL || "http://localhost:8080"
+        }/api/rooms/${roomSlug}`,
+        { slug },
+        { headers: { "X-User-ID": userId } },
+      );
+      // The room.renamed event updates the URL for everyone, including us
+    } catch (err: any) {
+      alert(err.response?.data?.error || "Failed to rename room");
+    }
+  };
+
+  // Copy this room into a new one, e.g. to reuse last week's notes
+  const handleForkRoom = async () => {
+    const slug = prompt("Name for the copy (leave empty to generate one)", "");
+    if (slug === null) return;
+    const includeFiles =
+      files.length > 0 && confirm("Copy the uploaded files too?");
+    const api = import.meta.env.VITE_API_URL || "http://localhost:8080";
+    try {
+      // The fork copies the saved snapshot, so save what is on screen first
+      if (ydoc) {
+        const update = Y.encodeStateAsUpdate(ydoc);
+        let binary = "";
+        update.forEach((b) => (binary += String.fromCharCode(b)));
+        await axios.post(`${api}/api/rooms/${roomSlug}/save`, {
+          content: window.btoa(binary),
+        });
+      }
+      const res = await axios.post(`${api}/api/rooms/${roomSlug}/fork`, {
+        owner: userId,
+        customSlug: slug.trim().toLowerCase() || undefined,
+        includeFiles,
+      });
+      navigate(`/${res.data.slug}`);
+    } catch (err: any) {
+      alert(err.response?.data?.error || "Failed to fork room");
+    }
+  };
+
+  // Keep the current document as a template for new rooms
+  const handleSaveTemplate = async (doc: any) => {
+    const name = prompt("Template name", currentSlug);
+    if (!name?.trim()) return;
+    try {
+      await axios.post(
+        `${import.meta.env.VITE_API_URL || "http://localhost:8080"}/api/templates`,
+        { room: roomSlug, name: name.trim(), doc },
+        { headers: { "X-User-ID": userId } },
+      );
+      alert(`Saved "${name.trim()}". Pick it when starting a new room.`);
+    } catch (err: any) {
+      alert(err.response?.data?.error || "Failed to save template");
+    }
+  };
+
   const handleDeleteRoom = async () => {
     if (
       confirm(
@@ -351,9 +471,16 @@ export const Editor: React.FC<EditorProps> = ({
       color = cursorColors[Math.floor(Math.random() * cursorColors.length)];
       localStorage.setItem("notex_user_color", color);
     }
+    // Awareness is public, so it carries a separate ID rather than the
+    // user ID, which proves ownership of rooms
+    let peerId = localStorage.getItem("notex_peer_id");
+    if (!peerId) {
+      peerId = crypto.randomUUID();
+      localStorage.setItem("notex_peer_id", peerId);
+    }
     return {
       name: username,
-      userId: userId,
+      peerId,
       color: color,
     };
   });
@@ -371,6 +498,7 @@ export const Editor: React.FC<EditorProps> = ({
           `${
             import.meta.env.VITE_API_URL || "http://localhost:8080"
           }/api/rooms/${roomSlug}/files`,
+          { headers: { "X-User-ID": userId } },
         );
         setFiles(Array.isArray(res.data) ? res.data : []);
       } catch (e) {
@@ -388,10 +516,90 @@ export const Editor: React.FC<EditorProps> = ({
         fetchFiles();
       };
       yMeta.observe(observer);
-      return () => yMeta.unobserve(observer);
+
+      // Refetch on server file events
+      const unsubscribe = onControlEvent((event) => {
+        if (event.type.startsWith("file.")) fetchFiles();
+      });
+
+      return () => {
+        yMeta.unobserve(observer);
+        unsubscribe();
+      };
     }
   }, [roomSlug, ydoc]);
 
+  // Room renamed by its owner: switch the address bar without reconnecting
+  useEffect(
+    () =>
+      onControlEvent((event) => {
+        if (event.type === "room.renamed" && event.data?.slug) {
+          setCurrentSlug(event.data.slug);
+          window.history.replaceState(null, "", `/${event.data.slug}`);
+        }
+      }),
+    [],
+  );
+
+  // Room deleted, expired or burned while connected
+  useEffect(
+    () =>
+      onControlEvent((event) => {
+        if (event.type === "room.deleted" || event.type === "room.expired") {
+          cacheManager.remove(roomSlug);
+          setNotFound(true);
+        }
+        if (event.type === "room.burned") {
+          handleBurned();
+        }
+      }),
+    [roomSlug],
+  );
+
+  // Expiry is near: the owner can push it back, everyone else is told
+  useEffect(
+    () =>
+      onControlEvent(async (event) => {
+        if (event.type !== "room.expiring") return;
+        const when = new Date(event.data.expireAt).toLocaleString();
+        if (!isOwner) {
+          alert(`This room expires at ${when}. Save anything you need.`);
+          return;
+        }
+        if (!confirm(`This room expires at ${when}. Keep it longer?`)) return;
+        try {
+          await axios.post(
+            `${
+              import.meta.env.VITE_API_URL || "http://localhost:8080"
+            }/api/rooms/${roomSlug}/extend`,
+            null,
+            { headers: { "X-User-ID": userId } },
+          );
+        } catch (err: any) {
+          alert(err.response?.data?.error || "Failed to extend room");
+        }
+      }),
+    [roomSlug, isOwner, userId],
+  );
+
+  // Burn-after-reading room destroyed. The reader whose open burned it
+  // keeps what they fetched; everyone else is told it was already read.
+  const handleBurned = () => {
+    cacheManager.remove(roomSlug);
+    if (lastCopy.current) {
+      // Both the event and the close code land here; tell the user once
+      lastCopy.current = false;
+      keptCopy.current = true;
+      alert(
+        "This room has self-destructed. What you see is the only remaining copy.",
+      );
+      return;
+    }
+    if (keptCopy.current) return;
+    setBurned(true);
+    setNotFound(true);
+  };
+
   // Initial Load from SmartCache OR Server
   useEffect(() => {
     const fetchRoomData = async () => {
@@ -419,7 +627,16 @@ export const Editor: React.FC<EditorProps> = ({
           `${
             import.meta.env.VITE_API_URL || "http://localhost:8080"
           }/api/rooms/${roomSlug}`,
+          { headers: { "X-User-ID": userId } },
         );
+        if (
+          res.data.burnAfterReads &&
+          res.data.reads >= res.data.burnAfterReads
+        ) {
+          lastCopy.current = true;
+        }
+
+        snapshotTag.current = res.headers["etag"] || null;
 
         if (res.data.content && ydoc) {
           try {
@@ -440,6 +657,10 @@ export const Editor: React.FC<EditorProps> = ({
           setNotFound(true);
           return;
         }
+        if (e.response && e.response.status === 410) {
+          handleBurned();
+          return;
+        }
         // Other errors (e.g. network) just fail silently for now or retry
       }
     };
@@ -458,11 +679,15 @@ export const Editor: React.FC<EditorProps> = ({
             `${
               import.meta.env.VITE_API_URL || "http://localhost:8080"
             }/api/rooms/${roomSlug}`,
+            { headers: { "X-User-ID": userId } },
           );
         } catch (e: any) {
           if (e.response && e.response.status === 404) {
             setNotFound(true);
           }
+          if (e.response && e.response.status === 410) {
+            handleBurned();
+          }
         }
       };
       checkRoom();
@@ -484,9 +709,23 @@ export const Editor: React.FC<EditorProps> = ({
         ) + "/ws";
 
       provider = new WebsocketProvider(wsUrl, roomSlug, doc, {
-        params: { room: roomSlug },
+        params: { room: roomSlug, user: userId },
       });
 
+      // Re-dispatch server control events as window events
+      provider.messageHandlers[MESSAGE_CONTROL] = (_encoder, decoder) => {
+        try {
+          const payload = new TextDecoder().decode(
+            decoder.arr.subarray(decoder.pos),
+          );
+          window.dispatchEvent(
+            new CustomEvent(CONTROL_EVENT, { detail: JSON.parse(payload) }),
+          );
+        } catch (err) {
+          console.error("Failed to parse control message", err);
+        }
+      };
+
       provider.on("status", (event: any) => {
         setStatus(event.status);
         if (event.status === "connected" && provider) {
@@ -495,6 +734,21 @@ export const Editor: React.FC<EditorProps> = ({
         }
       });
 
+      // Deliberate closes (room deleted/expired, kicked) must not reconnect
+      provider.on("connection-close", (event: CloseEvent | null) => {
+        if (!provider || !event || !isFinalClose(event.code)) return;
+        provider.shouldConnect = false;
+        cacheManager.remove(roomSlug);
+        if (event.code === CLOSE_KICKED) {
+          alert("You were removed from this room.");
+          navigate("/");
+        } else if (event.code === CLOSE_ROOM_BURNED) {
+          handleBurned();
+        } else {
+          setNotFound(true);
+        }
+      });
+
       setProvider(provider);
     };
 
@@ -524,15 +778,49 @@ export const Editor: React.FC<EditorProps> = ({
       const reader = new FileReader();
       reader.onload = async () => {
         const base64 = (reader.result as string).split(",")[1];
-        await axios.post(
-          `${
-            import.meta.env.VITE_API_URL || "http://localhost:8080"
-          }/api/rooms/${roomSlug}/save`,
-          {
-            content: base64,
-          },
-        );
-        if (!silent) alert("Saved!");
+        const api = import.meta.env.VITE_API_URL || "http://localhost:8080";
+        const save = (content: string) =>
+          axios.post(
+            `${api}/api/rooms/${roomSlug}/save`,
+            { content },
+            {
+              headers: snapshotTag.current
+                ? { "If-Match": snapshotTag.current }
+                : {},
+            },
+          );
+        try {
+          let res;
+          try {
+            res = await save(base64);
+          } catch (err: any) {
+            if (err.response?.status !== 412) throw err;
+            // Someone saved since we loaded: pull their snapshot into our
+            // document (Yjs merges it) and save the combined result
+            const latest = await axios.get(`${api}/api/rooms/${roomSlug}`, {
+              headers: { "X-User-ID": userId },
+            });
+            if (latest.data.content) {
+              const binary = window.atob(latest.data.content);
+              const bytes = new Uint8Array(binary.length);
+              for (let i = 0; i < binary.length; i++) {
+                bytes[i] = binary.charCodeAt(i);
+              }
+              Y.applyUpdate(ydoc, bytes);
+            }
+            snapshotTag.current = latest.headers["etag"] || null;
+            const merged = Y.encodeStateAsUpdate(ydoc);
+            let mergedBinary = "";
+            merged.forEach((b) => (mergedBinary += String.fromCharCode(b)));
+            res = await save(window.btoa(mergedBinary));
+          }
+          snapshotTag.current = res.headers["etag"] || null;
+          if (!silent) alert("Saved!");
+        } catch (err: any) {
+          console.error(err);
+          // e.g. the document outgrew the server's size limit
+          if (!silent) alert(err.response?.data?.error || "Failed to save");
+        }
       };
       reader.readAsDataURL(blob);
     } catch (e) {
@@ -545,7 +833,7 @@ export const Editor: React.FC<EditorProps> = ({
   };
 
   if (notFound) {
-    return <NotFoundView />;
+    return <NotFoundView burned={burned} />;
   }
 
   if (!provider || !ydoc) {
@@ -567,7 +855,7 @@ export const Editor: React.FC<EditorProps> = ({
         <TiptapEditor
           provider={provider}
           userDetails={userDetails}
-          roomSlug={roomSlug}
+          roomSlug={currentSlug}
           status={status}
           isOwner={isOwner}
           saving={saving}
@@ -576,6 +864,9 @@ export const Editor: React.FC<EditorProps> = ({
           setShowFilesModal={setShowFilesModal}
           handleLeave={handleLeave}
           handleDeleteRoom={handleDeleteRoom}
+          handleRenameRoom={handleRenameRoom}
+          handleForkRoom={handleForkRoom}
+          handleSaveTemplate={handleSaveTemplate}
           handleSave={() => handleSave(false)}
           initialContent={initialContent}
         />
@@ -596,7 +887,6 @@ export const Editor: React.FC<EditorProps> = ({
         onUpload={handleFileUpload}
         onDelete={handleFileDelete}
         uploading={uploading}
-        userId={userId}
         isRoomOwner={isOwner}
       />
     </div>
@@ -18,7 +18,7 @@ interface FileData {
   url: string;
   size: number;
   type?: string;
-  uploaderId?: string;
+  canEdit?: boolean;
 }
 
 interface FilesModalProps {
@@ -28,7 +28,6 @@ interface FilesModalProps {
   onUpload: (file: File) => Promise<void>;
   onDelete: (fileId: string) => Promise<void>;
   uploading: boolean;
-  userId: string;
   isRoomOwner: boolean;
 }
 
@@ -39,7 +38,6 @@ export const FilesModal: React.FC<FilesModalProps> = ({
   onUpload,
   onDelete,
   uploading,
-  userId,
   isRoomOwner,
 }) => {
   const fileInputRef = React.useRef<HTMLInputElement>(null);
@@ -105,7 +103,7 @@ export const FilesModal: React.FC<FilesModalProps> = ({
           ) : (
             files.map((f) => {
               const canDelete =
-                isRoomOwner || (f.uploaderId && f.uploaderId === userId);
+                isRoomOwner || f.canEdit;
               return (
                 <div key={f.id} className="file-item-glass">
                   <div className="file-icon">{getFileIcon(f.name)}</div>
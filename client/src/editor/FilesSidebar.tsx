@@ -1,6 +1,7 @@
 import React, { useEffect, useState } from "react";
 import axios from "axios";
 import * as Y from "yjs";
+import { onControlEvent } from "../utils/controlEvents";
 import {
   File,
   Trash2,
@@ -26,7 +27,7 @@ interface FileData {
   url: string;
   size: number;
   type?: string;
-  uploaderId?: string;
+  canEdit?: boolean;
 }
 
 interface ActiveUpload {
@@ -56,7 +57,16 @@ export const FilesSidebar: React.FC<FilesSidebarProps> = ({
       fetchFiles();
     };
     yMeta.observe(observer);
-    return () => yMeta.unobserve(observer);
+
+    // Server file events (uploads, deletes, renames by anyone in the room)
+    const unsubscribe = onControlEvent((event) => {
+      if (event.type.startsWith("file.")) fetchFiles();
+    });
+
+    return () => {
+      yMeta.unobserve(observer);
+      unsubscribe();
+    };
   }, [roomSlug, ydoc]);
 
   const fetchFiles = async () => {
@@ -65,6 +75,7 @@ export const FilesSidebar: React.FC<FilesSidebarProps> = ({
         `${
           import.meta.env.VITE_API_URL || "http://localhost:8080"
         }/api/rooms/${roomSlug}/files`,
+        { headers: { "X-User-ID": userId } },
       );
       setFiles(Array.isArray(res.data) ? res.data : []);
     } catch (e) {
@@ -368,7 +379,7 @@ export const FilesSidebar: React.FC<FilesSidebarProps> = ({
         ) : (
           files.map((f) => {
             const canDelete =
-              isRoomOwner || (f.uploaderId && f.uploaderId === userId);
+              isRoomOwner || f.canEdit;
             return (
               <div key={f.id} className="file-item-glass">
                 <div className="file-icon">{getFileIcon(f.name)}</div>
@@ -11,7 +11,7 @@ interface UsersSidebarProps {
 interface UserData {
   name: string;
   color: string;
-  userId?: string;
+  peerId?: string;
 }
 
 export const UsersSidebar: React.FC<UsersSidebarProps> = ({
@@ -27,8 +27,8 @@ export const UsersSidebar: React.FC<UsersSidebarProps> = ({
       const uniqueUsers = new Map<string, UserData>();
 
       states.forEach((state: any) => {
-        if (state.user && state.user.userId) {
-          uniqueUsers.set(state.user.userId, state.user);
+        if (state.user && state.user.peerId) {
+          uniqueUsers.set(state.user.peerId, state.user);
         } else if (state.user) {
           uniqueUsers.set(state.user.name, state.user);
         }
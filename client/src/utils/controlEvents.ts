@@ -0,0 +1,33 @@
+// Server-originated control messages on the room websocket
+// (see server/internal/ws/control.go). Wire format: [MESSAGE_CONTROL][JSON].
+export const MESSAGE_CONTROL = 100;
+
+// Window event the websocket handler re-dispatches control messages as
+export const CONTROL_EVENT = "notex:control";
+
+// Close codes the server uses when it ends a session on purpose
+// (see server/internal/ws/close.go). Reconnecting after these is pointless.
+export const CLOSE_ROOM_DELETED = 4000;
+export const CLOSE_ROOM_EXPIRED = 4001;
+export const CLOSE_KICKED = 4002;
+export const CLOSE_ROOM_BURNED = 4003;
+
+export const isFinalClose = (code?: number): boolean =>
+  code !== undefined && code >= 4000 && code < 5000;
+
+export interface ControlEvent {
+  type: string;
+  data?: any;
+}
+
+/**
+ * Subscribe to control events for the current room.
+ * Returns an unsubscribe function suitable for useEffect cleanup.
+ */
+export const onControlEvent = (
+  handler: (event: ControlEvent) => void,
+): (() => void) => {
+  const listener = (e: Event) => handler((e as CustomEvent<ControlEvent>).detail);
+  window.addEventListener(CONTROL_EVENT, listener);
+  return () => window.removeEventListener(CONTROL_EVENT, listener);
+};
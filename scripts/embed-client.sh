@@ -0,0 +1,31 @@
+#!/usr/bin/env sh
+# Build the React client and copy it into the Go server for embedding, so
+# `go build` in server/ produces a single self-contained binary.
+#
+# Usage: VITE_API_URL=https://notex.example.com scripts/embed-client.sh
+set -eu
+
+ROOT="$(cd "$(dirname "$0")/.." && pwd)"
+DIST="$ROOT/server/internal/web/dist"
+
+if [ -z "${VITE_API_URL:-}" ]; then
+  echo "VITE_API_URL is not set; the client will call http://localhost:8080" >&2
+fi
+
+cd "$ROOT/client"
+npm install --legacy-peer-deps
+npm run build
+
+find "$DIST" -mindepth 1 ! -name .gitkeep -exec rm -rf {} +
+cp -R "$ROOT/client/dist/." "$DIST/"
+
+# Precompressed variants, served when the browser accepts them
+find "$DIST" -type f \( -name '*.js' -o -name '*.css' -o -name '*.html' -o -name '*.svg' -o -name '*.json' \) |
+  while read -r f; do
+    gzip -9 -k -f "$f"
+    if command -v brotli >/dev/null 2>&1; then
+      brotli -q 11 -k -f "$f"
+    fi
+  done
+
+echo "Client embedded in $DIST; now run: cd server && go build -o notex-server ."
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pranavdhawale/notex/server/internal/models"
+	"github.com/pranavdhawale/notex/server/internal/state"
+	"github.com/pranavdhawale/notex/server/internal/ws"
+)
+
+// maxBurnReads caps burnAfterReads; beyond that a room is hardly a secret
+const maxBurnReads = 100
+
+// roomGone tells the client a room burned after reading
+func roomGone(c *gin.Context, room *models.Room) {
+	c.JSON(http.StatusGone, gin.H{"error": "This room has already been read and no longer exists", "burnedAt": room.BurnedAt})
+}
+
+// recordRead counts an open of a burn-after-reading room, reporting whether
+// it was the last one allowed. Reads that lose a race for the last one are
+// answered as if the room had already burned. On failure the error
+// response is written and nil is returned.
+func (h *Handler) recordRead(ctx context.Context, c *gin.Context, room *models.Room) (*models.Room, bool) {
+	read, err := h.rooms.RecordRead(ctx, room.ID)
+	switch {
+	case errors.Is(err, state.ErrBurned):
+		roomGone(c, room)
+		return nil, false
+	case errors.Is(err, state.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+		return nil, false
+	case err != nil:
+		log.Printf("Failed to record read of room %s: %v", room.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return nil, false
+	}
+
+	if read.Reads > read.BurnAfterReads {
+		roomGone(c, read)
+		return nil, false
+	}
+	return read, read.Reads == read.BurnAfterReads
+}
+
+// burnRoom destroys the content and files of a room, leaving a tombstone
+// that keeps the slug and reports the room as read until it expires
+func (h *Handler) burnRoom(ctx context.Context, room *models.Room) {
+	tombstoneExpiry := time.Now().Add(h.cfg.Rooms.EmptyTTL)
+	err := h.rooms.Burn(ctx, room.ID, tombstoneExpiry)
+	if errors.Is(err, state.ErrBurned) || errors.Is(err, state.ErrNotFound) {
+		return // Somebody else cleaned up
+	}
+	if err != nil {
+		log.Printf("Failed to burn room %s: %v", room.ID, err)
+		return
+	}
+
+	h.purgeRoom(ctx, room, ws.Event{Type: ws.EventRoomBurned, Data: gin.H{"slug": room.Slug}}, ws.ReasonRoomBurned)
+	log.Printf("Room burned after reading: %s", room.ID)
+}
+
+// ViewerLeft burns a burn-on-disconnect room once its first viewer has
+// left. Wire it up with Hub.OnViewerLeft.
+func (h *Handler) ViewerLeft(roomID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	room, err := h.rooms.Get(ctx, roomID)
+	if err != nil || room.Burned() || !room.BurnOnDisconnect {
+		return
+	}
+	h.burnRoom(ctx, room)
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pranavdhawale/notex/server/internal/models"
+	"github.com/pranavdhawale/notex/server/internal/state"
+)
+
+// roomETag identifies a revision of a room as GET returns it. The room ID
+// is part of it so a room recreated under the same slug never matches, and
+// the content version so saves can check it with If-Match.
+func roomETag(room *models.Room) string {
+	return `"` + room.ID + "." + strconv.FormatInt(room.Version, 10) + "." + strconv.FormatInt(room.Revision, 10) + `"`
+}
+
+// contentETag identifies a version of the content of a room alone, for the
+// export and the answers to saves
+func contentETag(room *models.Room) string {
+	return `"` + room.ID + "." + strconv.FormatInt(room.Version, 10) + `"`
+}
+
+// etagMatches reports whether an If-Match or If-None-Match header lists etag.
+// Weak validators compare equal to strong ones, as If-None-Match requires.
+func etagMatches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchVersion turns an If-Match header into the version a save must
+// replace. Both ETags name it, and only the content version is checked.
+// ok is false if the header names no version of room, which can never
+// succeed.
+func ifMatchVersion(header string, room *models.Room) (version int64, ok bool) {
+	if header == "" {
+		return state.AnyVersion, true
+	}
+	if strings.TrimSpace(header) == "*" {
+		return state.AnyVersion, true // The room exists
+	}
+	prefix := `"` + room.ID + "."
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if strings.HasPrefix(tag, "W/") {
+			continue // If-Match uses strong comparison
+		}
+		if !strings.HasPrefix(tag, prefix) || !strings.HasSuffix(tag, `"`) {
+			continue
+		}
+		number, _, _ := strings.Cut(tag[len(prefix):len(tag)-1], ".")
+		v, err := strconv.ParseInt(number, 10, 64)
+		if err == nil && v >= 0 {
+			return v, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pranavdhawale/notex/server/internal/models"
+	"github.com/pranavdhawale/notex/server/internal/yjs"
+)
+
+// maxSaveAttempts bounds how often a save is merged again when another save
+// lands between reading the stored content and writing the merge
+const maxSaveAttempts = 5
+
+var errInvalidContent = errors.New("Content must be a base64-encoded Yjs update")
+
+// validateContent checks that saved content is a Yjs update, the editor's
+// Y.encodeStateAsUpdate
+func validateContent(update []byte) error {
+	if yjs.ValidateUpdate(update) != nil {
+		return errInvalidContent
+	}
+	return nil
+}
+
+// mergeContent merges an update into the stored content of room, so a tab
+// saving a stale document adds its edits without erasing anyone else's.
+// Content that is not an update, left by clients from before merging, is
+// replaced.
+func mergeContent(room *models.Room, update []byte) ([]byte, error) {
+	if len(room.Content) == 0 {
+		return update, nil
+	}
+	if validateContent(room.Content) != nil {
+		log.Printf("Replacing content of room %s that is not a Yjs update", room.ID)
+		return update, nil
+	}
+	return yjs.MergeUpdates(room.Content, update)
+}
+
+// maxSaveBody is the largest save request body: the content in base64,
+// which is a third larger, and some room for the JSON around it
+func (h *Handler) maxSaveBody() int64 {
+	return h.cfg.Rooms.MaxContentSize/3*4 + 4 + 1024
+}
+
+// contentTooLarge writes the response for content over the size limit
+func (h *Handler) contentTooLarge(c *gin.Context) {
+	c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+		"error":   fmt.Sprintf("Room content exceeds the %s limit", formatSize(h.cfg.Rooms.MaxContentSize)),
+		"maxSize": h.cfg.Rooms.MaxContentSize,
+	})
+}
+
+// formatSize renders a byte count for error messages
+func formatSize(n int64) string {
+	switch {
+	case n >= 1024*1024 && n%(1024*1024) == 0:
+		return fmt.Sprintf("%dMB", n/(1024*1024))
+	case n >= 1024 && n%1024 == 0:
+		return fmt.Sprintf("%dKB", n/1024)
+	default:
+		return fmt.Sprintf("%d byte", n)
+	}
+}
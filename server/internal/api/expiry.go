@@ -0,0 +1,174 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/pranavdhawale/notex/server/internal/config"
+	"github.com/pranavdhawale/notex/server/internal/models"
+	"github.com/pranavdhawale/notex/server/internal/state"
+)
+
+// roomTTL is how long a room lives after activity: the owner's choice if
+// they made one, otherwise the server default for empty or saved rooms
+func (h *Handler) roomTTL(room *models.Room, hasContent bool) time.Duration {
+	if room.TTL > 0 {
+		return time.Duration(room.TTL) * time.Second
+	}
+	if hasContent {
+		return h.cfg.Rooms.ContentTTL
+	}
+	return h.cfg.Rooms.EmptyTTL
+}
+
+// nextExpiry is the expiry of room after activity now. Expiry only moves
+// forward, so a later expireAt set by the owner is kept. Pinned rooms have
+// no expiry.
+func (h *Handler) nextExpiry(room *models.Room, hasContent bool) time.Time {
+	if room.Pinned {
+		return time.Time{}
+	}
+	next := time.Now().Add(h.roomTTL(room, hasContent))
+	if room.ExpireAt.After(next) {
+		return room.ExpireAt
+	}
+	return next
+}
+
+// hasContent reports whether a room has saved content. Rooms read for
+// streaming come without it, but with its size.
+func hasContent(room *models.Room) bool {
+	return len(room.Content) > 0 || room.ContentSize > 0
+}
+
+// refreshExpiry pushes back the expiry of room on a read or websocket
+// edit, if the refresh policy allows it. room.ExpireAt and room.Revision
+// are updated.
+func (h *Handler) refreshExpiry(ctx context.Context, room *models.Room) {
+	if h.cfg.Rooms.Refresh != config.RefreshActivity || room.Pinned {
+		return
+	}
+
+	next := h.nextExpiry(room, hasContent(room))
+	if !next.After(room.ExpireAt) {
+		return
+	}
+	if err := h.rooms.SetExpiry(ctx, room.ID, next); err != nil {
+		log.Printf("Failed to refresh expiry of room %s: %v", room.ID, err)
+		return
+	}
+	room.ExpireAt = next
+	room.Revision++
+}
+
+// RefreshOnActivity records the activity of rooms edited over the
+// websocket and refreshes their expiry until ctx is done, at most once per
+// room per refreshThrottle.
+func (h *Handler) RefreshOnActivity(ctx context.Context, activity <-chan string) {
+	refreshed := make(map[string]time.Time)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case roomID := <-activity:
+			now := time.Now()
+			if now.Sub(refreshed[roomID]) < h.cfg.Rooms.RefreshThrottle {
+				continue
+			}
+			refreshed[roomID] = now
+
+			// Forget rooms that have gone quiet
+			if len(refreshed) > 1000 {
+				for id, t := range refreshed {
+					if now.Sub(t) >= h.cfg.Rooms.RefreshThrottle {
+						delete(refreshed, id)
+					}
+				}
+			}
+
+			queryCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			if err := h.rooms.Touch(queryCtx, roomID, now); err != nil && !errors.Is(err, state.ErrNotFound) {
+				log.Printf("Failed to record activity of room %s: %v", roomID, err)
+			}
+			room, err := h.rooms.Get(queryCtx, roomID)
+			if err == nil {
+				h.refreshExpiry(queryCtx, room)
+			}
+			cancel()
+		}
+	}
+}
+
+// validateTTL checks an owner-chosen lifetime against the server limits
+func (h *Handler) validateTTL(seconds int64) error {
+	ttl := time.Duration(seconds) * time.Second
+	if ttl < h.cfg.Rooms.MinTTL || ttl > h.cfg.Rooms.MaxTTL {
+		return fmt.Errorf("Expiry must be between %s and %s", formatTTL(h.cfg.Rooms.MinTTL), formatTTL(h.cfg.Rooms.MaxTTL))
+	}
+	return nil
+}
+
+// formatTTL renders whole days or hours the way users write them
+func formatTTL(d time.Duration) string {
+	switch {
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	}
+	return d.String()
+}
+
+// expiryUpdate turns the expiry fields of an update request into a store
+// update for room, working out the new expiry. Returns nil if the request
+// leaves expiry alone, or the status and error to respond with.
+func (h *Handler) expiryUpdate(room *models.Room, req UpdateRoomRequest) (*state.RoomUpdate, int, error) {
+	if req.TTL == nil && req.Pinned == nil && req.ExpireAt == nil {
+		return nil, 0, nil
+	}
+
+	if req.TTL != nil && *req.TTL != 0 {
+		if err := h.validateTTL(*req.TTL); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+	}
+	if req.Pinned != nil && *req.Pinned && !room.Pinned && !h.cfg.Rooms.AllowPinning {
+		return nil, http.StatusForbidden, errors.New("Pinning rooms is disabled on this server")
+	}
+
+	// Apply to a copy to work out the expiry
+	next := *room
+	update := state.RoomUpdate{TTL: req.TTL, Pinned: req.Pinned}
+	if req.TTL != nil {
+		next.TTL = *req.TTL
+	}
+	if req.Pinned != nil {
+		next.Pinned = *req.Pinned
+	}
+
+	var expireAt time.Time
+	switch {
+	case req.ExpireAt != nil:
+		if next.Pinned {
+			return nil, http.StatusBadRequest, errors.New("Pinned rooms do not expire")
+		}
+		now := time.Now()
+		if req.ExpireAt.Before(now.Add(h.cfg.Rooms.MinTTL)) || req.ExpireAt.After(now.Add(h.cfg.Rooms.MaxTTL)) {
+			return nil, http.StatusBadRequest, fmt.Errorf("Expiry must be between %s and %s from now", formatTTL(h.cfg.Rooms.MinTTL), formatTTL(h.cfg.Rooms.MaxTTL))
+		}
+		expireAt = *req.ExpireAt
+	case next.Pinned:
+		// Zero expiry
+	default:
+		// A new lifetime or unpinning starts the clock again from now
+		expireAt = time.Now().Add(h.roomTTL(&next, hasContent(room)))
+	}
+	update.ExpireAt = &expireAt
+
+	return &update, 0, nil
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pranavdhawale/notex/server/internal/models"
+	"github.com/pranavdhawale/notex/server/internal/state"
+)
+
+// ExportRoom downloads the content of a room as a Yjs update, which
+// Y.applyUpdate loads into a document. Reading counts like opening the
+// room. Range requests let large downloads resume, and If-None-Match
+// skips content the client already has.
+func (h *Handler) ExportRoom(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	room, content := h.readRoom(ctx, c, contentETag)
+	if room == nil {
+		return
+	}
+	// The download outlasts the lookup, so it reads with the request
+	r := &contentSeeker{ctx: c.Request.Context(), content: content}
+	defer r.Close()
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.yjs"`, room.Slug))
+	http.ServeContent(c.Writer, c.Request, "", time.Time{}, r)
+}
+
+// contentSeeker serves room content to http.ServeContent, which seeks to
+// learn the size and to the start of a range. The content is only read
+// from the store once ServeContent reads; seeking back reads it again.
+type contentSeeker struct {
+	ctx     context.Context
+	content *state.Content
+	r       io.ReadCloser
+	read    int64 // Offset r is at
+	offset  int64 // Offset the next Read starts from
+}
+
+func (s *contentSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += s.offset
+	case io.SeekEnd:
+		offset += s.content.Size
+	}
+	if offset < 0 {
+		return 0, errors.New("seek before start of content")
+	}
+	s.offset = offset
+	return offset, nil
+}
+
+func (s *contentSeeker) Read(p []byte) (int, error) {
+	if s.r != nil && s.offset < s.read {
+		s.Close()
+	}
+	if s.r == nil {
+		r, err := s.content.Open(s.ctx)
+		if err != nil {
+			return 0, err
+		}
+		s.r, s.read = r, 0
+	}
+	if s.offset > s.read {
+		n, err := io.CopyN(io.Discard, s.r, s.offset-s.read)
+		s.read += n
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := s.r.Read(p)
+	s.read += int64(n)
+	s.offset = s.read
+	return n, err
+}
+
+func (s *contentSeeker) Close() error {
+	if s.r == nil {
+		return nil
+	}
+	err := s.r.Close()
+	s.r = nil
+	return err
+}
+
+// writeRoom sends a room as JSON with its content. Content can run to the
+// size limit, so its base64 is streamed from storage into the response
+// instead of building the whole body in memory first.
+func writeRoom(c *gin.Context, room *models.Room, content *state.Content) {
+	meta := *room
+	meta.Content = nil
+	meta.IsOwner = ownedBy(c, room)
+	data, err := json.Marshal(&meta)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode room"})
+		return
+	}
+
+	if content.Size == 0 {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+		return
+	}
+	r, err := content.Open(c.Request.Context())
+	if err != nil {
+		log.Printf("Failed to read content of room %s: %v", room.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read room content"})
+		return
+	}
+	defer r.Close()
+
+	const field = `,"content":"`
+	length := len(data) + len(field) + base64.StdEncoding.EncodedLen(int(content.Size)) + 1
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Header("Content-Length", strconv.Itoa(length))
+	c.Status(http.StatusOK)
+
+	// The content goes last, before the closing brace. A read that fails
+	// partway leaves the response short of its length, so clients see it
+	// fail too.
+	w := c.Writer
+	w.Write(data[:len(data)-1])
+	io.WriteString(w, field)
+	enc := base64.NewEncoder(base64.StdEncoding, w)
+	if _, err := io.Copy(enc, r); err != nil {
+		log.Printf("Failed to send room %s: %v", room.ID, err)
+		return
+	}
+	enc.Close()
+	if _, err := io.WriteString(w, `"}`); err != nil {
+		log.Printf("Failed to send room %s: %v", room.ID, err)
+	}
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/pranavdhawale/notex/server/internal/models"
+	"github.com/pranavdhawale/notex/server/internal/state"
+)
+
+type ForkRoomRequest struct {
+	CreateRoomRequest
+	IncludeFiles bool `json:"includeFiles,omitempty"` // Copy the uploaded files too
+}
+
+// ForkRoom creates a new room holding a copy of the saved content of an
+// existing one, and optionally its files
+func (h *Handler) ForkRoom(c *gin.Context) {
+	var req ForkRoomRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Template != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Forks start from their source room, not a template"})
+		return
+	}
+
+	// Copying files can take a while
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	source, _ := h.findRoom(ctx, c)
+	if source == nil {
+		return
+	}
+	if source.Burns() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Burn-after-reading rooms cannot be forked"})
+		return
+	}
+
+	room := h.newRoom(c, req.CreateRoomRequest)
+	if room == nil {
+		return
+	}
+	room.Content = source.Content
+	room.ForkedFrom = source.ID
+	room.ExpireAt = h.nextExpiry(room, hasContent(room))
+
+	if !h.insertRoom(ctx, c, room, req.CustomSlug) {
+		return
+	}
+
+	if req.IncludeFiles {
+		if err := h.copyFiles(ctx, source.ID, room.ID); err != nil {
+			log.Printf("Failed to copy files from room %s to %s: %v", source.ID, room.ID, err)
+			h.removeUploads(ctx, room.ID)
+			_ = h.files.DeleteByRoom(ctx, room.ID)
+			_ = h.rooms.Delete(ctx, room.ID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to copy files"})
+			return
+		}
+	}
+	h.indexRoom(ctx, room)
+
+	room.IsOwner = room.Owner != ""
+	c.JSON(http.StatusCreated, room)
+}
+
+// copyFiles copies the uploads of one room into another
+func (h *Handler) copyFiles(ctx context.Context, fromID, toID string) error {
+	files, _, err := h.files.List(ctx, fromID, state.ListFilesOptions{})
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	uploadDir := filepath.Join(h.cfg.Uploads.Dir, toID)
+	if err := os.MkdirAll(uploadDir, os.ModePerm); err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		id := uuid.New().String()
+		dst := filepath.Join(uploadDir, id+filepath.Ext(f.Path))
+		if err := copyFile(f.Path, dst); err != nil {
+			return fmt.Errorf("copy %s: %w", f.Name, err)
+		}
+
+		copied := models.File{
+			ID:          id,
+			RoomID:      toID,
+			UploaderID:  f.UploaderID,
+			Name:        f.Name,
+			Description: f.Description,
+			Pinned:      f.Pinned,
+			Size:        f.Size,
+			Path:        dst,
+			CreatedAt:   time.Now(),
+		}
+		if err := h.files.Create(ctx, &copied); err != nil {
+			os.Remove(dst)
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
@@ -2,210 +2,590 @@ package api
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/pranavdhawale/notex/server/internal/config"
 	"github.com/pranavdhawale/notex/server/internal/models"
+	"github.com/pranavdhawale/notex/server/internal/notify"
+	"github.com/pranavdhawale/notex/server/internal/search"
 	"github.com/pranavdhawale/notex/server/internal/state"
+	"github.com/pranavdhawale/notex/server/internal/templates"
 	"github.com/pranavdhawale/notex/server/internal/utils"
 	"github.com/pranavdhawale/notex/server/internal/ws"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// Handler serves the REST API
+type Handler struct {
+	cfg   *config.Config
+	hub   *ws.Hub
+	rooms state.RoomStore
+	files state.FileStore
+
+	templates state.TemplateStore
+	builtins  []models.Template // Loaded from templates.dir or the binary
+
+	index *search.Index // Full-text index of room content and file names
+
+	slugs      *utils.SlugGenerator
+	slugRules  utils.SlugRules
+	slugFilter *utils.SlugFilter
+
+	notifiers []notify.Sender // Where expiry warnings go besides the websocket
+	extendKey []byte          // Signs extend links
+}
+
+// NewHandler fails if the slug settings name an unknown strategy or rules
+// version, or the built-in templates cannot be loaded
+func NewHandler(cfg *config.Config, hub *ws.Hub, rooms state.RoomStore, files state.FileStore, tmpls state.TemplateStore, index *search.Index) (*Handler, error) {
+	filter := utils.NewSlugFilter(cfg.Slugs.Reserved, cfg.Slugs.Blocklist)
+	slugs, err := utils.NewSlugGenerator(cfg.Slugs.Strategies, cfg.Slugs.AttemptsPerStrategy, filter)
+	if err != nil {
+		return nil, fmt.Errorf("slugs.strategies: %w", err)
+	}
+
+	rules, err := utils.SlugRulesFor(cfg.Slugs.Rules)
+	if err != nil {
+		return nil, fmt.Errorf("slugs.rules: %w", err)
+	}
+	if cfg.Slugs.MaxWords > 0 {
+		rules.MaxWords = cfg.Slugs.MaxWords
+	}
+	if cfg.Slugs.MaxLength > 0 {
+		rules.MaxLength = cfg.Slugs.MaxLength
+	}
+
+	builtins, err := templates.Load(cfg.Templates.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("templates.dir: %w", err)
+	}
+
+	return &Handler{
+		cfg: cfg, hub: hub, rooms: rooms, files: files,
+		templates: tmpls, builtins: builtins, index: index,
+		slugs: slugs, slugRules: rules, slugFilter: filter,
+		notifiers: notify.Senders(cfg.Notify), extendKey: extendKeyFor(cfg.Notify.Secret),
+	}, nil
+}
+
+// validateSlug checks a custom slug against the configured rules and filter
+func (h *Handler) validateSlug(slug string) error {
+	if err := h.slugRules.Validate(slug); err != nil {
+		return err
+	}
+	return h.slugFilter.Check(slug)
+}
+
 type CreateRoomRequest struct {
 	Owner      string  `json:"owner"`
 	CustomSlug *string `json:"customSlug,omitempty"` // Optional custom slug
-}
+	TTL        int64   `json:"ttl,omitempty"`        // Optional lifetime in seconds
+	Pinned     bool    `json:"pinned,omitempty"`     // Never expires, if the server allows it
 
+	// Burn after reading: destroy the room after this many opens by
+	// anyone but the owner, or when its first viewer disconnects
+	BurnAfterReads   int  `json:"burnAfterReads,omitempty"`
+	BurnOnDisconnect bool `json:"burnOnDisconnect,omitempty"`
 
-// Helper to calculate expiration based on content
-func calculateExpiry(hasContent bool) time.Time {
-	if hasContent {
-		return time.Now().Add(7 * 24 * time.Hour) // 7 Days
-	}
-	return time.Now().Add(24 * time.Hour) // 1 Day
+	Contact string `json:"contact,omitempty"` // Optional owner email for expiry warnings
+
+	Template string `json:"template,omitempty"` // Optional ID of a template to start from
 }
 
-func CreateRoom(c *gin.Context) {
+func (h *Handler) CreateRoom(c *gin.Context) {
 	var req CreateRoomRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	collection := state.MongoDatabase.Collection("rooms")
+	room := h.newRoom(c, req)
+	if room == nil {
+		return
+	}
+	room.ExpireAt = h.nextExpiry(room, false) // Initially empty
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	var slug string
-	var err error
+	// Saved templates are private to their owner
+	var template *models.Template
+	if req.Template != "" {
+		if template = h.findTemplate(ctx, c, req.Template); template == nil {
+			return
+		}
+		if !template.BuiltIn && template.Owner != req.Owner {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+			return
+		}
+	}
 
-	// If user provided custom slug, validate and use it
-	if req.CustomSlug != nil && *req.CustomSlug != "" {
-		customSlug := strings.ToLower(strings.TrimSpace(*req.CustomSlug))
+	if !h.insertRoom(ctx, c, room, req.CustomSlug) {
+		return
+	}
+	if template != nil {
+		if !h.applyTemplate(ctx, c, room, template) {
+			return
+		}
+		h.indexRoom(ctx, room)
+	}
 
-		// Validate format
-		if err := utils.ValidateCustomSlug(customSlug); err != nil {
+	room.IsOwner = room.Owner != ""
+	c.JSON(http.StatusCreated, room)
+}
+
+// newRoom validates the settings of a room to create, without its slug or
+// expiry. On failure the error response is written and nil is returned.
+func (h *Handler) newRoom(c *gin.Context, req CreateRoomRequest) *models.Room {
+	if req.TTL != 0 {
+		if err := h.validateTTL(req.TTL); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
+			return nil
 		}
+	}
+	if req.Pinned && !h.cfg.Rooms.AllowPinning {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Pinning rooms is disabled on this server"})
+		return nil
+	}
+	if req.BurnAfterReads < 0 || req.BurnAfterReads > maxBurnReads {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("burnAfterReads must be between 0 and %d", maxBurnReads)})
+		return nil
+	}
+	contact, err := parseContact(req.Contact)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil
+	}
 
-		// Check if already exists
-		count, err := collection.CountDocuments(ctx, bson.M{"slug": customSlug})
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check slug availability"})
-			return
+	now := time.Now()
+	return &models.Room{
+		Owner:     req.Owner,
+		CreatedAt: now,
+		ActiveAt:  now,
+		TTL:       req.TTL,
+		Pinned:    req.Pinned,
+
+		BurnAfterReads:   req.BurnAfterReads,
+		BurnOnDisconnect: req.BurnOnDisconnect,
+
+		Contact: contact,
+	}
+}
+
+// insertRoom stores room under the custom slug if one was given, otherwise
+// under a generated one. Uniqueness is enforced by the store on insert, so
+// concurrent requests cannot both get the same slug. On failure the error
+// response is written and false is returned.
+func (h *Handler) insertRoom(ctx context.Context, c *gin.Context, room *models.Room, customSlug *string) bool {
+	// If user provided custom slug, validate and use it
+	if customSlug != nil && *customSlug != "" {
+		slug := strings.ToLower(strings.TrimSpace(*customSlug))
+
+		// Validate format
+		if err := h.validateSlug(slug); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return false
 		}
 
-		if count > 0 {
+		room.Slug = slug
+		err := h.rooms.Create(ctx, room)
+		if errors.Is(err, state.ErrSlugTaken) {
 			c.JSON(http.StatusConflict, gin.H{"error": "Room slug already taken"})
-			return
+			return false
 		}
-
-		slug = customSlug
-	} else {
-		// Auto-generate 2-word slug
-		slug, err = utils.GenerateUniqueSlug(ctx, collection)
 		if err != nil {
-			log.Printf("Failed to generate unique slug: %v", err)
+			log.Printf("Failed to insert room: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create room"})
-			return
+			return false
 		}
+		return true
 	}
 
-	room := models.Room{
-		Slug:      slug,
-		Owner:     req.Owner,
-		CreatedAt: time.Now(),
-		ExpireAt:  calculateExpiry(false), // Initially empty, expires in 24h
+	// Auto-generate a slug, escalating to larger name spaces on collisions
+	_, err := h.slugs.Generate(ctx, func(ctx context.Context, slug string) (bool, error) {
+		room.Slug = slug
+		err := h.rooms.Create(ctx, room)
+		if errors.Is(err, state.ErrSlugTaken) {
+			return false, nil
+		}
+		return err == nil, err
+	})
+	if errors.Is(err, utils.ErrSlugSpaceExhausted) {
+		log.Printf("Failed to generate unique slug: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "No free room names right now, try a custom slug"})
+		return false
 	}
-
-	_, err = collection.InsertOne(ctx, room)
 	if err != nil {
 		log.Printf("Failed to insert room: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create room"})
-		return
+		return false
 	}
+	return true
+}
 
-	c.JSON(http.StatusCreated, room)
+// ownedBy reports whether the requestor owns room. Rooms without an owner
+// belong to nobody.
+func ownedBy(c *gin.Context, room *models.Room) bool {
+	return room.Owner != "" && c.GetHeader("X-User-ID") == room.Owner
 }
 
-func GetRoom(c *gin.Context) {
-	slug := c.Param("room")
+// findRoom resolves the :room parameter, which is the current slug of a
+// room or an alias left behind by a rename. On failure, including when the
+// room burned after reading, the error response is written and nil is
+// returned.
+func (h *Handler) findRoom(ctx context.Context, c *gin.Context) (*models.Room, bool) {
+	room, aliased, err := h.rooms.Resolve(ctx, c.Param("room"))
+	if !roomFound(c, room, err) {
+		return nil, false
+	}
+	return room, aliased
+}
+
+// roomFound writes the error response for a failed lookup of the :room
+// parameter and reports whether the room can be used
+func roomFound(c *gin.Context, room *models.Room, err error) bool {
+	if err != nil {
+		if errors.Is(err, state.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+			return false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return false
+	}
+	if room.Burned() {
+		roomGone(c, room)
+		return false
+	}
+	return true
+}
 
-	collection := state.MongoDatabase.Collection("rooms")
+func (h *Handler) GetRoom(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	var room models.Room
-	err := collection.FindOne(ctx, bson.M{"slug": slug}).Decode(&room)
+	if room, content := h.readRoom(ctx, c, roomETag); room != nil {
+		writeRoom(c, room, content)
+	}
+}
+
+// readRoom resolves the room a read is for and applies what reading it
+// does: redirects from old slugs, burning, expiry refreshes and conditional
+// requests, with the ETag etag makes of the room as read.
+// The room comes without its content, which is returned to be streamed.
+// Returns nil if the response was already written.
+func (h *Handler) readRoom(ctx context.Context, c *gin.Context, etag func(*models.Room) string) (*models.Room, *state.Content) {
+	room, aliased, content, err := h.rooms.ResolveContent(ctx, c.Param("room"))
+	if !roomFound(c, room, err) {
+		return nil, nil
+	}
+
+	// Old slugs of renamed rooms redirect permanently to the current one
+	if aliased {
+		location := "/api/rooms/" + room.Slug + strings.TrimPrefix(c.FullPath(), "/api/rooms/:room")
+		if c.Request.URL.RawQuery != "" {
+			location += "?" + c.Request.URL.RawQuery
+		}
+		c.Redirect(http.StatusPermanentRedirect, location)
+		return nil, nil
+	}
+
+	// Burn-on-disconnect rooms are only shown live, where the viewer
+	// leaving burns them; anyone but the owner cannot read them here
+	if room.BurnOnDisconnect && !ownedBy(c, room) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This room can only be viewed live"})
+		return nil, nil
+	}
+
+	// Opens by anyone but the owner count towards burning the room
+	last := false
+	if room.BurnAfterReads > 0 && !ownedBy(c, room) {
+		if room, last = h.recordRead(ctx, c, room); room == nil {
+			return nil, nil
+		}
+		// The content is read with the count, before a burn can destroy it
+		content = state.ContentOf(room.Content)
+		room.Content = nil
+	}
+	if last {
+		// This reader gets the content, nobody after them does
+		h.burnRoom(ctx, room)
+	} else {
+		// Reads count as activity under the default refresh policy
+		h.refreshExpiry(ctx, room)
+	}
+
+	// Clients that already hold this revision skip the body
+	tag := etag(room)
+	c.Header("ETag", tag)
+	if inm := c.GetHeader("If-None-Match"); inm != "" && etagMatches(inm, tag) {
+		c.Status(http.StatusNotModified)
+		return nil, nil
+	}
+	return room, content
+}
+
+type UpdateRoomRequest struct {
+	Slug     *string    `json:"slug,omitempty"`     // Rename; the old slug keeps working as an alias
+	TTL      *int64     `json:"ttl,omitempty"`      // Lifetime in seconds; 0 restores the server defaults
+	Pinned   *bool      `json:"pinned,omitempty"`   // Never expires, if the server allows it
+	ExpireAt *time.Time `json:"expireAt,omitempty"` // Explicit expiry
+	Contact  *string    `json:"contact,omitempty"`  // Email for expiry warnings; empty removes it
+}
+
+// UpdateRoom lets the owner of a room change its settings
+func (h *Handler) UpdateRoom(c *gin.Context) {
+	requestorID := c.GetHeader("X-User-ID")
+	if requestorID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing User ID header"})
+		return
+	}
+
+	var req UpdateRoomRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if req.Slug == nil && req.TTL == nil && req.Pinned == nil && req.ExpireAt == nil && req.Contact == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to update"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	room, _ := h.findRoom(ctx, c)
+	if room == nil {
+		return
+	}
+	if room.Owner == "" || room.Owner != requestorID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the room owner can change it"})
+		return
+	}
+
+	// Validate everything before changing anything
+	var slug string
+	if req.Slug != nil {
+		slug = strings.ToLower(strings.TrimSpace(*req.Slug))
+		if err := h.validateSlug(slug); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	update, status, err := h.expiryUpdate(room, req)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Contact != nil {
+		contact, err := parseContact(*req.Contact)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if update == nil {
+			update = &state.RoomUpdate{}
+		}
+		update.Contact = &contact
+	}
+
+	if req.Slug != nil {
+		previous := room.Slug
+		err := h.rooms.Rename(ctx, room.ID, slug)
+		if errors.Is(err, state.ErrSlugTaken) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Room slug already taken"})
+			return
+		}
+		if errors.Is(err, state.ErrNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-		return
+		if err != nil {
+			log.Printf("Failed to rename room %s: %v", room.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update room"})
+			return
+		}
+		room.Slug = slug
+
+		if previous != slug {
+			h.hub.Notify(room.ID, ws.Event{Type: ws.EventRoomRenamed, Data: gin.H{"slug": slug, "previous": previous}})
+			h.indexRoom(ctx, room)
+		}
 	}
 
-	// Refresh Expiration (Smart TTL)
-	hasContent := false
-	if room.Content != nil {
-		// Check if content is empty string if it's a string, or just nil check
-		// Currently Content is interface{}, usually string(base64) or map
-		// Let's assume non-nil means content for now, or check empty string
-		if s, ok := room.Content.(string); ok && s != "" {
-			hasContent = true
-		} else if room.Content != nil {
-			hasContent = true // Non-string content
+	if update != nil {
+		updated, err := h.rooms.Update(ctx, room.ID, *update)
+		if errors.Is(err, state.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+			return
 		}
+		if err != nil {
+			log.Printf("Failed to update room %s: %v", room.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update room"})
+			return
+		}
+		room = updated
 	}
-	
-	newExpiry := calculateExpiry(hasContent)
-	// Update ExpireAt in background (don't block read)
-	go func(s string, t time.Time) {
-		bgCtx, bgCancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer bgCancel()
-		_, _ = collection.UpdateOne(bgCtx, bson.M{"slug": s}, bson.M{"$set": bson.M{"expire_at": t}})
-	}(slug, newExpiry)
 
+	room.IsOwner = true
 	c.JSON(http.StatusOK, room)
 }
 
-func DeleteRoom(c *gin.Context) {
-	slug := c.Param("room")
-
-	collection := state.MongoDatabase.Collection("rooms")
+func (h *Handler) DeleteRoom(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	// 1. Delete Room Metadata
-	// We ignore if it wasn't found, because we want to clean up files anyway.
-	_, err := collection.DeleteOne(ctx, bson.M{"slug": slug})
-	if err != nil {
+	room, _ := h.findRoom(ctx, c)
+	if room == nil {
+		return
+	}
+
+	// 1. Delete Room Metadata (and aliases)
+	if err := h.rooms.Delete(ctx, room.ID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
 	}
 
-	// 2. Delete Associated Files
-	fileCollection := state.MongoDatabase.Collection("files")
-	_, _ = fileCollection.DeleteMany(ctx, bson.M{"room_id": slug})
+	h.purgeRoom(ctx, room, ws.Event{Type: ws.EventRoomDeleted, Data: gin.H{"slug": room.Slug}}, ws.ReasonRoomDeleted)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Room deleted"})
+}
 
-	// 3. Cleanup Disk (Uploads)
-	_ = os.RemoveAll("uploads/" + slug)
+// purgeRoom removes everything a room leaves behind once its record is
+// gone or burned, and disconnects its clients with event and reason
+func (h *Handler) purgeRoom(ctx context.Context, room *models.Room, event ws.Event, reason ws.CloseReason) {
+	// 2. Cleanup Disk (Uploads)
+	h.removeUploads(ctx, room.ID)
 
-	// 4. Close WebSocket Connections
-	ws.MainHub.CloseRoom(slug)
+	// 3. Delete Associated Files
+	_ = h.files.DeleteByRoom(ctx, room.ID)
 
-	c.JSON(http.StatusOK, gin.H{"message": "Room deleted"})
+	// 4. Notify & Close WebSocket Connections
+	h.hub.Notify(room.ID, event)
+	h.hub.CloseRoom(room.ID, reason)
+
+	h.unindexRoom(room.ID)
+}
+
+// removeUploads deletes the files of a room from disk. Files uploaded before
+// rooms had IDs live in a directory named after the slug, so every stored
+// path is removed along with its directory if that leaves it empty.
+func (h *Handler) removeUploads(ctx context.Context, roomID string) {
+	files, _, err := h.files.List(ctx, roomID, state.ListFilesOptions{})
+	if err != nil {
+		log.Printf("Failed to list files of room %s: %v", roomID, err)
+	}
+	for _, f := range files {
+		_ = os.Remove(f.Path)
+		_ = os.Remove(filepath.Dir(f.Path)) // Fails unless empty
+	}
+	_ = os.RemoveAll(filepath.Join(h.cfg.Uploads.Dir, roomID))
 }
 
 type SaveRoomRequest struct {
-	Content interface{} `json:"content"`
+	Content []byte `json:"content"` // Yjs update in base64, merged into the stored content
 }
 
-func SaveRoom(c *gin.Context) {
-	slug := c.Param("room")
+func (h *Handler) SaveRoom(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxSaveBody())
+
 	var req SaveRoomRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.contentTooLarge(c)
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
+	if int64(len(req.Content)) > h.cfg.Rooms.MaxContentSize {
+		h.contentTooLarge(c)
+		return
+	}
+	if err := validateContent(req.Content); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	collection := state.MongoDatabase.Collection("rooms")
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	// Use Upsert: false to prevent creating rooms on save if they don't exist
-	opts := options.Update().SetUpsert(false)
-	filter := bson.M{"slug": slug}
-	
-	// Saving implies content exists -> 7 Days TTL
-	newExpiry := calculateExpiry(true)
-	
-	update := bson.M{
-		"$set": bson.M{
-			"content":   req.Content,
-			"expire_at": newExpiry,
-		},
-	}
-
-	result, err := collection.UpdateOne(ctx, filter, update, opts)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save room"})
+	room, _ := h.findRoom(ctx, c)
+	if room == nil {
 		return
 	}
 
-	if result.MatchedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+	// With If-Match the save only goes through over the version the client
+	// last saw, so a stale tab cannot clobber newer content
+	ifMatch := c.GetHeader("If-Match")
+	version, ok := ifMatchVersion(ifMatch, room)
+	if !ok || (version != state.AnyVersion && version != room.Version) {
+		c.Header("ETag", contentETag(room))
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Room content changed", "version": room.Version})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Room saved"})
+	// The update is merged into what is stored, and the merge only written
+	// over the version it was made from. Without If-Match a concurrent save
+	// just means merging again.
+	var saved int64
+	for attempt := 1; ; attempt++ {
+		content, err := mergeContent(room, req.Content)
+		if err != nil {
+			log.Printf("Failed to merge content of room %s: %v", room.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save room"})
+			return
+		}
+		if int64(len(content)) > h.cfg.Rooms.MaxContentSize {
+			h.contentTooLarge(c)
+			return
+		}
+
+		// Saving implies content exists -> content TTL
+		newExpiry := room.ExpireAt
+		if h.cfg.Rooms.Refresh != config.RefreshOff {
+			newExpiry = h.nextExpiry(room, true)
+		}
+
+		saved, err = h.rooms.SaveContent(ctx, room.ID, content, newExpiry, room.Version)
+		if errors.Is(err, state.ErrVersionMismatch) && ifMatch == "" && attempt < maxSaveAttempts {
+			if room, err = h.rooms.Get(ctx, room.ID); err == nil {
+				if room.Burned() {
+					roomGone(c, room)
+					return
+				}
+				continue
+			}
+		}
+		if errors.Is(err, state.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+			return
+		}
+		if errors.Is(err, state.ErrVersionMismatch) && ifMatch != "" {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Room content changed"})
+			return
+		}
+		if errors.Is(err, state.ErrVersionMismatch) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Room is busy saving, try again"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save room"})
+			return
+		}
+		room.Content = content
+		break
+	}
+
+	room.Version = saved
+	h.indexRoom(ctx, room)
+	c.Header("ETag", contentETag(room))
+	c.JSON(http.StatusOK, gin.H{"message": "Room saved", "version": saved})
 }
@@ -0,0 +1,1107 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pranavdhawale/notex/server/internal/config"
+	"github.com/pranavdhawale/notex/server/internal/models"
+	"github.com/pranavdhawale/notex/server/internal/notify"
+	"github.com/pranavdhawale/notex/server/internal/search"
+	"github.com/pranavdhawale/notex/server/internal/state"
+	"github.com/pranavdhawale/notex/server/internal/utils"
+	"github.com/pranavdhawale/notex/server/internal/ws"
+	"github.com/pranavdhawale/notex/server/internal/yjs"
+)
+
+type testServer struct {
+	cfg     *config.Config
+	handler *Handler
+	router  *gin.Engine
+	rooms   *state.MemoryRoomStore
+	files   *state.MemoryFileStore
+	uploads string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	cfg := config.Default()
+	cfg.Uploads.Dir = t.TempDir()
+
+	rooms := state.NewMemoryRoomStore()
+	files := state.NewMemoryFileStore()
+	index, err := search.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { index.Close() })
+	h, err := NewHandler(cfg, ws.NewHub(cfg.WebSocket, rooms), rooms, files, state.NewMemoryTemplateStore(), index)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.POST("/api/rooms", h.CreateRoom)
+	r.GET("/api/rooms", h.ListRooms)
+	r.GET("/api/rooms/:room", h.GetRoom)
+	r.PATCH("/api/rooms/:room", h.UpdateRoom)
+	r.DELETE("/api/rooms/:room", h.DeleteRoom)
+	r.POST("/api/rooms/:room/save", h.SaveRoom)
+	r.GET("/api/rooms/:room/export", h.ExportRoom)
+	r.POST("/api/rooms/:room/fork", h.ForkRoom)
+	r.POST("/api/rooms/:room/extend", h.ExtendRoom)
+	r.GET("/api/rooms/:room/extend", h.ConfirmExtend)
+	r.GET("/api/slugs/:slug", h.CheckSlug)
+	r.GET("/api/search", h.Search)
+	r.GET("/api/templates", h.ListTemplates)
+	r.POST("/api/templates", h.SaveTemplate)
+	r.DELETE("/api/templates/:id", h.DeleteTemplate)
+	r.GET("/api/rooms/:room/files", h.ListFiles)
+	r.PATCH("/api/rooms/:room/files/:fileId", h.UpdateFile)
+	r.DELETE("/api/rooms/:room/files/:fileId", h.DeleteFile)
+	r.GET("/uploads/:room/:file", h.ServeUpload)
+
+	return &testServer{cfg: cfg, handler: h, router: r, rooms: rooms, files: files, uploads: cfg.Uploads.Dir}
+}
+
+func (s *testServer) do(method, path, userID string, body interface{}) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	if userID != "" {
+		req.Header.Set("X-User-ID", userID)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// textContent is saved room content: a Yjs update in which client inserts
+// text into the root type "t"
+func textContent(client byte, text string) []byte {
+	update := []byte{1, 1, client, 0, 4, 1, 1, 't', byte(len(text))}
+	update = append(update, text...)
+	return append(update, 0)
+}
+
+func TestCreateGetAndSaveRoom(t *testing.T) {
+	s := newTestServer(t)
+
+	slug := "team-alpha"
+	w := s.do(http.MethodPost, "/api/rooms", "", CreateRoomRequest{Owner: "alice", CustomSlug: &slug})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d: %s", w.Code, w.Body)
+	}
+
+	w = s.do(http.MethodPost, "/api/rooms", "", CreateRoomRequest{Owner: "bob", CustomSlug: &slug})
+	if w.Code != http.StatusConflict {
+		t.Errorf("duplicate slug: expected 409, got %d", w.Code)
+	}
+
+	content := textContent(1, "hello")
+	w = s.do(http.MethodPost, "/api/rooms/team-alpha/save", "", SaveRoomRequest{Content: content})
+	if w.Code != http.StatusOK {
+		t.Fatalf("save: expected 200, got %d: %s", w.Code, w.Body)
+	}
+
+	w = s.do(http.MethodGet, "/api/rooms/team-alpha", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("get: expected 200, got %d", w.Code)
+	}
+	var room models.Room
+	json.Unmarshal(w.Body.Bytes(), &room)
+	if room.IsOwner || !bytes.Equal(room.Content, content) {
+		t.Errorf("unexpected room: %+v", room)
+	}
+	// The owner ID proves ownership, so readers never see it
+	if strings.Contains(w.Body.String(), "alice") {
+		t.Errorf("owner ID sent to a reader: %s", w.Body)
+	}
+	w = s.do(http.MethodGet, "/api/rooms/team-alpha", "alice", nil)
+	if json.Unmarshal(w.Body.Bytes(), &room); !room.IsOwner {
+		t.Errorf("owner: expected isOwner, got %s", w.Body)
+	}
+
+	if w := s.do(http.MethodGet, "/api/rooms/missing", "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("missing room: expected 404, got %d", w.Code)
+	}
+	if w := s.do(http.MethodPost, "/api/rooms/missing/save", "", SaveRoomRequest{Content: content}); w.Code != http.StatusNotFound {
+		t.Errorf("save missing room: expected 404, got %d", w.Code)
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	s := newTestServer(t)
+
+	slug := "synced"
+	if w := s.do(http.MethodPost, "/api/rooms", "", CreateRoomRequest{Owner: "alice", CustomSlug: &slug}); w.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d", w.Code)
+	}
+
+	// Reads that push back the expiry change the room; here only saves do
+	s.cfg.Rooms.Refresh = config.RefreshSave
+
+	get := func(path, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/api/rooms/synced", "")
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected an ETag")
+	}
+	exportTag := get("/api/rooms/synced/export", "").Header().Get("ETag")
+	if exportTag == "" || exportTag == etag {
+		t.Fatalf("expected an ETag of the content alone, got %q", exportTag)
+	}
+
+	// Neither the room nor its export is modified while nothing changes
+	for path, tag := range map[string]string{"/api/rooms/synced": etag, "/api/rooms/synced/export": exportTag} {
+		if w := get(path, tag); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+			t.Errorf("%s with If-None-Match: expected 304 without body, got %d", path, w.Code)
+		}
+	}
+
+	// Settings change the room but not its content
+	ttl := int64(3600)
+	s.do(http.MethodPatch, "/api/rooms/synced", "alice", UpdateRoomRequest{TTL: &ttl})
+	w = get("/api/rooms/synced", etag)
+	var updated models.Room
+	json.Unmarshal(w.Body.Bytes(), &updated)
+	if w.Code != http.StatusOK || updated.TTL != ttl || w.Header().Get("ETag") == etag {
+		t.Errorf("If-None-Match after a settings change: expected 200 with the new TTL and ETag, got %d: %s", w.Code, w.Body)
+	}
+	if w := get("/api/rooms/synced/export", exportTag); w.Code != http.StatusNotModified {
+		t.Errorf("export after a settings change: expected 304, got %d", w.Code)
+	}
+
+	// Reads that refresh the expiry change the room too
+	s.cfg.Rooms.Refresh = config.RefreshActivity
+	etag = w.Header().Get("ETag")
+	if w := get("/api/rooms/synced", etag); w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Errorf("If-None-Match with a refreshed expiry: expected 200 with a new ETag, got %d", w.Code)
+	}
+	s.cfg.Rooms.Refresh = config.RefreshSave
+	etag = get("/api/rooms/synced", "").Header().Get("ETag")
+
+	save := func(ifMatch string, content []byte) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(SaveRoomRequest{Content: content})
+		req := httptest.NewRequest(http.MethodPost, "/api/rooms/synced/save", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	first := textContent(1, "a")
+	w = save(etag, first)
+	if w.Code != http.StatusOK {
+		t.Fatalf("save with current ETag: expected 200, got %d: %s", w.Code, w.Body)
+	}
+	newTag := w.Header().Get("ETag")
+	if newTag == "" || newTag == etag {
+		t.Errorf("expected a new ETag after saving, got %q", newTag)
+	}
+
+	// A second tab still holding the old version must not clobber the save
+	if w := save(etag, textContent(2, "b")); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("save with stale ETag: expected 412, got %d", w.Code)
+	}
+	if w := save(`"someone-else.1"`, textContent(2, "b")); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("save with another room's ETag: expected 412, got %d", w.Code)
+	}
+
+	w = s.do(http.MethodGet, "/api/rooms/synced", "", nil)
+	var room models.Room
+	json.Unmarshal(w.Body.Bytes(), &room)
+	if !bytes.Equal(room.Content, first) || room.Version != 1 || !strings.HasPrefix(w.Header().Get("ETag"), strings.TrimSuffix(newTag, `"`)+".") {
+		t.Errorf("unexpected room after conflicting save: %+v (ETag %s)", room, w.Header().Get("ETag"))
+	}
+
+	// Unconditional saves still overwrite
+	if w := save("", textContent(3, "c")); w.Code != http.StatusOK {
+		t.Errorf("unconditional save: expected 200, got %d", w.Code)
+	}
+	if w := save("*", textContent(4, "d")); w.Code != http.StatusOK {
+		t.Errorf("save with If-Match *: expected 200, got %d", w.Code)
+	}
+}
+
+func TestSaveRoomMergesContent(t *testing.T) {
+	s := newTestServer(t)
+
+	slug := "merged"
+	if w := s.do(http.MethodPost, "/api/rooms", "", CreateRoomRequest{Owner: "alice", CustomSlug: &slug}); w.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d", w.Code)
+	}
+
+	// Two tabs that never saw each other's edits both save
+	a, b := textContent(1, "from a"), textContent(2, "from b")
+	for _, content := range [][]byte{a, b} {
+		if w := s.do(http.MethodPost, "/api/rooms/merged/save", "", SaveRoomRequest{Content: content}); w.Code != http.StatusOK {
+			t.Fatalf("save: expected 200, got %d: %s", w.Code, w.Body)
+		}
+	}
+
+	w := s.do(http.MethodGet, "/api/rooms/merged", "", nil)
+	var room models.Room
+	json.Unmarshal(w.Body.Bytes(), &room)
+	want, _ := yjs.MergeUpdates(a, b)
+	if !bytes.Equal(room.Content, want) {
+		t.Errorf("expected both saves merged:\n got %v\nwant %v", room.Content, want)
+	}
+	if room.ContentSize != int64(len(want)) || room.StoredSize == 0 {
+		t.Errorf("expected content sizes, got %d raw and %d stored", room.ContentSize, room.StoredSize)
+	}
+
+	// Saving the same state again changes nothing
+	s.do(http.MethodPost, "/api/rooms/merged/save", "", SaveRoomRequest{Content: a})
+	w = s.do(http.MethodGet, "/api/rooms/merged", "", nil)
+	json.Unmarshal(w.Body.Bytes(), &room)
+	if !bytes.Equal(room.Content, want) {
+		t.Errorf("resaving changed the content: %v", room.Content)
+	}
+
+	for name, body := range map[string]interface{}{
+		"not base64": gin.H{"content": "not base64!"},
+		"not yjs":    SaveRoomRequest{Content: []byte(`{"type":"doc"}`)},
+		"json":       gin.H{"content": gin.H{"type": "doc"}},
+	} {
+		if w := s.do(http.MethodPost, "/api/rooms/merged/save", "", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, w.Code)
+		}
+	}
+
+	// Over the size limit, whether the update itself, the request body or
+	// the merged document is too large
+	s.cfg.Rooms.MaxContentSize = int64(len(want)) + 8
+	for name, content := range map[string][]byte{
+		"update": textContent(3, strings.Repeat("x", 40)),
+		"body":   bytes.Repeat([]byte{0}, 4096),
+		"merged": textContent(3, "more"),
+	} {
+		if w := s.do(http.MethodPost, "/api/rooms/merged/save", "", SaveRoomRequest{Content: content}); w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s too large: expected 413, got %d: %s", name, w.Code, w.Body)
+		}
+	}
+}
+
+func TestExportRoom(t *testing.T) {
+	s := newTestServer(t)
+
+	slug := "exported"
+	s.do(http.MethodPost, "/api/rooms", "", CreateRoomRequest{Owner: "alice", CustomSlug: &slug})
+	content := textContent(1, strings.Repeat("export ", 16))
+	if w := s.do(http.MethodPost, "/api/rooms/exported/save", "", SaveRoomRequest{Content: content}); w.Code != http.StatusOK {
+		t.Fatalf("save: expected 200, got %d: %s", w.Code, w.Body)
+	}
+
+	w := s.do(http.MethodGet, "/api/rooms/exported/export", "", nil)
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), content) {
+		t.Fatalf("export: expected the content, got %d with %d bytes", w.Code, w.Body.Len())
+	}
+	if got := w.Header().Get("Content-Disposition"); got != `attachment; filename="exported.yjs"` {
+		t.Errorf("unexpected Content-Disposition %q", got)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/rooms/exported/export", nil)
+	req.Header.Set("Range", "bytes=10-19")
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusPartialContent || !bytes.Equal(w.Body.Bytes(), content[10:20]) {
+		t.Errorf("range: expected 206 with bytes 10-19, got %d: %v", w.Code, w.Body.Bytes())
+	}
+
+	// Ranges out of order read the content again from the start
+	req = httptest.NewRequest(http.MethodGet, "/api/rooms/exported/export", nil)
+	req.Header.Set("Range", "bytes=10-19,0-4")
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if body := w.Body.Bytes(); w.Code != http.StatusPartialContent || !bytes.Contains(body, content[10:20]) || !bytes.Contains(body, content[0:5]) {
+		t.Errorf("ranges: expected 206 with both ranges, got %d: %q", w.Code, body)
+	}
+
+	// The streamed JSON of GetRoom carries the same content
+	w = s.do(http.MethodGet, "/api/rooms/exported", "", nil)
+	var room models.Room
+	if err := json.Unmarshal(w.Body.Bytes(), &room); err != nil || !bytes.Equal(room.Content, content) {
+		t.Errorf("get: expected the content, got %v (err %v)", room.Content, err)
+	}
+	if got := w.Header().Get("Content-Length"); got != strconv.Itoa(w.Body.Len()) {
+		t.Errorf("Content-Length %s does not match the body of %d bytes", got, w.Body.Len())
+	}
+
+	newSlug := "renamed-export"
+	s.do(http.MethodPatch, "/api/rooms/exported", "alice", UpdateRoomRequest{Slug: &newSlug})
+	w = s.do(http.MethodGet, "/api/rooms/exported/export", "", nil)
+	if w.Code != http.StatusPermanentRedirect || w.Header().Get("Location") != "/api/rooms/renamed-export/export" {
+		t.Errorf("alias: expected redirect to the export of the new slug, got %d to %q", w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestSearchRooms(t *testing.T) {
+	s := newTestServer(t)
+
+	for _, r := range []struct{ slug, owner, text string }{
+		{"alice-notes", "alice", "quarterly budget review"},
+		{"bob-notes", "bob", "budget draft for the offsite"},
+	} {
+		slug := r.slug
+		s.do(http.MethodPost, "/api/rooms", "", CreateRoomRequest{Owner: r.owner, CustomSlug: &slug})
+		if w := s.do(http.MethodPost, "/api/rooms/"+r.slug+"/save", "", SaveRoomRequest{Content: textContent(1, r.text)}); w.Code != http.StatusOK {
+			t.Fatalf("save %s: expected 200, got %d: %s", r.slug, w.Code, w.Body)
+		}
+	}
+
+	find := func(path, userID string) []search.Hit {
+		t.Helper()
+		w := s.do(http.MethodGet, path, userID, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", path, w.Code, w.Body)
+		}
+		var body struct{ Results []search.Hit }
+		json.Unmarshal(w.Body.Bytes(), &body)
+		return body.Results
+	}
+
+	// Only the caller's own rooms, unless others are named by slug
+	hits := find("/api/search?q=budget", "alice")
+	if len(hits) != 1 || hits[0].Slug != "alice-notes" {
+		t.Fatalf("own rooms: unexpected hits %+v", hits)
+	}
+	if len(hits[0].Snippets) == 0 || !strings.Contains(hits[0].Snippets[0], "<mark>budget</mark>") {
+		t.Errorf("expected a highlighted snippet, got %v", hits[0].Snippets)
+	}
+	if hits := find("/api/search?q=budget&rooms=bob-notes,no-such-room", "alice"); len(hits) != 2 {
+		t.Errorf("named rooms: expected 2 hits, got %+v", hits)
+	}
+	if hits := find("/api/search?q=budget&rooms=bob-notes", ""); len(hits) != 1 || hits[0].Slug != "bob-notes" {
+		t.Errorf("anonymous: unexpected hits %+v", hits)
+	}
+	if hits := find("/api/search?q=budget+offsite", "alice"); len(hits) != 0 {
+		t.Errorf("every word must match, got %+v", hits)
+	}
+
+	if w := s.do(http.MethodGet, "/api/search", "alice", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("missing query: expected 400, got %d", w.Code)
+	}
+	if w := s.do(http.MethodGet, "/api/search?q=budget", "", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("no scope: expected 400, got %d", w.Code)
+	}
+
+	if w := s.do(http.MethodDelete, "/api/rooms/alice-notes", "alice", nil); w.Code != http.StatusOK {
+		t.Fatalf("delete: expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if hits := find("/api/search?q=budget", "alice"); len(hits) != 0 {
+		t.Errorf("deleted room still found: %+v", hits)
+	}
+}
+
+func TestFillSearchIndex(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	// Rooms stored before the index existed
+	s.rooms.Create(ctx, &models.Room{Slug: "old-notes", Owner: "alice", Content: textContent(1, "archived roadmap"), ExpireAt: time.Now().Add(time.Hour)})
+	s.rooms.Create(ctx, &models.Room{Slug: "old-secret", Owner: "alice", Content: textContent(1, "roadmap"), BurnAfterReads: 1, ExpireAt: time.Now().Add(time.Hour)})
+	if s.handler.index.Filled() {
+		t.Fatal("a new index should not count as filled")
+	}
+
+	s.handler.FillIndex(ctx)
+	if !s.handler.index.Filled() {
+		t.Error("expected the index to be marked filled")
+	}
+	w := s.do(http.MethodGet, "/api/search?q=roadmap", "alice", nil)
+	var body struct{ Results []search.Hit }
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if len(body.Results) != 1 || body.Results[0].Slug != "old-notes" {
+		t.Errorf("expected only the existing plain room, got %s", w.Body)
+	}
+}
+
+func TestRenameRoomKeepsAliases(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	room := &models.Room{Slug: "old-name", Owner: "owner", ExpireAt: time.Now().Add(time.Hour)}
+	s.rooms.Create(ctx, room)
+
+	path := filepath.Join(s.uploads, room.ID, "f1.txt")
+	os.MkdirAll(filepath.Dir(path), 0o755)
+	os.WriteFile(path, []byte("hello"), 0o644)
+	s.files.Create(ctx, &models.File{ID: "f1", RoomID: room.ID, Name: "notes.txt", Path: path})
+
+	slug := "new-name"
+	if w := s.do(http.MethodPatch, "/api/rooms/old-name", "stranger", UpdateRoomRequest{Slug: &slug}); w.Code != http.StatusForbidden {
+		t.Errorf("stranger rename: expected 403, got %d", w.Code)
+	}
+	if w := s.do(http.MethodPatch, "/api/rooms/old-name", "owner", UpdateRoomRequest{Slug: &slug}); w.Code != http.StatusOK {
+		t.Fatalf("rename: expected 200, got %d: %s", w.Code, w.Body)
+	}
+
+	w := s.do(http.MethodGet, "/api/rooms/old-name", "", nil)
+	if w.Code != http.StatusPermanentRedirect || w.Header().Get("Location") != "/api/rooms/new-name" {
+		t.Errorf("old slug: expected 308 to new slug, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	if w := s.do(http.MethodGet, "/api/rooms/new-name", "", nil); w.Code != http.StatusOK {
+		t.Errorf("new slug: expected 200, got %d", w.Code)
+	}
+
+	// Files and upload links follow the room
+	if w := s.do(http.MethodGet, "/api/rooms/old-name/files", "", nil); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "notes.txt") {
+		t.Errorf("files via alias: got %d %s", w.Code, w.Body)
+	}
+	for _, prefix := range []string{room.ID, "old-name", "new-name"} {
+		if w := s.do(http.MethodGet, "/uploads/"+prefix+"/f1.txt", "", nil); w.Code != http.StatusOK || w.Body.String() != "hello" {
+			t.Errorf("/uploads/%s/f1.txt: got %d", prefix, w.Code)
+		}
+	}
+
+	// The old slug stays reserved for this room
+	old := "old-name"
+	if w := s.do(http.MethodPost, "/api/rooms", "", CreateRoomRequest{CustomSlug: &old}); w.Code != http.StatusConflict {
+		t.Errorf("creating over alias: expected 409, got %d", w.Code)
+	}
+}
+
+func TestUpdateRoomExpiry(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	room := &models.Room{Slug: "team-alpha", Owner: "owner", ExpireAt: time.Now().Add(time.Hour)}
+	s.rooms.Create(ctx, room)
+
+	patch := func(req UpdateRoomRequest) (*httptest.ResponseRecorder, models.Room) {
+		t.Helper()
+		w := s.do(http.MethodPatch, "/api/rooms/team-alpha", "owner", req)
+		var res models.Room
+		json.Unmarshal(w.Body.Bytes(), &res)
+		return w, res
+	}
+
+	// Owner-chosen lifetime within the limits
+	ttl := int64(30 * 24 * 60 * 60)
+	w, res := patch(UpdateRoomRequest{TTL: &ttl})
+	if w.Code != http.StatusOK || res.TTL != ttl || time.Until(res.ExpireAt) < 29*24*time.Hour {
+		t.Errorf("ttl: got %d %+v", w.Code, res)
+	}
+	tooLong := int64(100 * 24 * 60 * 60)
+	if w, _ := patch(UpdateRoomRequest{TTL: &tooLong}); w.Code != http.StatusBadRequest {
+		t.Errorf("ttl over max: expected 400, got %d", w.Code)
+	}
+
+	// Explicit expiry
+	at := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+	if w, res := patch(UpdateRoomRequest{ExpireAt: &at}); w.Code != http.StatusOK || !res.ExpireAt.Equal(at) {
+		t.Errorf("expireAt: got %d %+v", w.Code, res)
+	}
+	soon := time.Now().Add(time.Minute)
+	if w, _ := patch(UpdateRoomRequest{ExpireAt: &soon}); w.Code != http.StatusBadRequest {
+		t.Errorf("expireAt under min: expected 400, got %d", w.Code)
+	}
+
+	// Pinning needs server permission
+	pinned := true
+	if w, _ := patch(UpdateRoomRequest{Pinned: &pinned}); w.Code != http.StatusForbidden {
+		t.Errorf("pin disabled: expected 403, got %d", w.Code)
+	}
+	s.cfg.Rooms.AllowPinning = true
+	if w, res := patch(UpdateRoomRequest{Pinned: &pinned}); w.Code != http.StatusOK || !res.Pinned || !res.ExpireAt.IsZero() {
+		t.Errorf("pin: got %d %+v", w.Code, res)
+	}
+	if w, _ := patch(UpdateRoomRequest{ExpireAt: &at}); w.Code != http.StatusBadRequest {
+		t.Errorf("expireAt on pinned room: expected 400, got %d", w.Code)
+	}
+
+	// Reads do not give pinned rooms an expiry
+	s.do(http.MethodGet, "/api/rooms/team-alpha", "", nil)
+	if got, _ := s.rooms.Get(ctx, room.ID); !got.ExpireAt.IsZero() {
+		t.Errorf("get pinned: expected no expiry, got %v", got.ExpireAt)
+	}
+
+	if w := s.do(http.MethodPatch, "/api/rooms/team-alpha", "stranger", UpdateRoomRequest{TTL: &ttl}); w.Code != http.StatusForbidden {
+		t.Errorf("stranger: expected 403, got %d", w.Code)
+	}
+}
+
+func TestBurnAfterReading(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	w := s.do(http.MethodPost, "/api/rooms", "", CreateRoomRequest{Owner: "owner", BurnAfterReads: 2})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d: %s", w.Code, w.Body)
+	}
+	var room models.Room
+	json.Unmarshal(w.Body.Bytes(), &room)
+	secret := base64.StdEncoding.EncodeToString(textContent(1, "secret"))
+	s.rooms.SaveContent(ctx, room.ID, textContent(1, "secret"), time.Now().Add(time.Hour), state.AnyVersion)
+
+	path := filepath.Join(s.uploads, room.ID, "f1.txt")
+	os.MkdirAll(filepath.Dir(path), 0o755)
+	os.WriteFile(path, []byte("secret"), 0o644)
+	s.files.Create(ctx, &models.File{ID: "f1", RoomID: room.ID, Name: "key.txt", Path: path})
+
+	// The owner's own opens do not count
+	for i := 0; i < 3; i++ {
+		s.do(http.MethodGet, "/api/rooms/"+room.Slug, "owner", nil)
+	}
+	for i := 1; i <= 2; i++ {
+		w := s.do(http.MethodGet, "/api/rooms/"+room.Slug, "", nil)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), secret) {
+			t.Fatalf("read %d: expected content, got %d %s", i, w.Code, w.Body)
+		}
+		// Readers must not learn the ID that would let them read as the owner
+		if strings.Contains(w.Body.String(), `"owner"`) {
+			t.Fatalf("read %d: owner ID sent to a reader: %s", i, w.Body)
+		}
+	}
+
+	// Burned: the slug reports the room was read, content and files are gone
+	w = s.do(http.MethodGet, "/api/rooms/"+room.Slug, "owner", nil)
+	if w.Code != http.StatusGone || !strings.Contains(w.Body.String(), "already been read") {
+		t.Errorf("after burn: expected 410, got %d %s", w.Code, w.Body)
+	}
+	if w := s.do(http.MethodGet, "/api/slugs/"+room.Slug, "", nil); !strings.Contains(w.Body.String(), `"available":false`) {
+		t.Errorf("tombstone should hold the slug: %s", w.Body)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("uploads should be removed, stat err %v", err)
+	}
+	if tomb, _ := s.rooms.Get(ctx, room.ID); tomb == nil || tomb.Content != nil {
+		t.Errorf("unexpected tombstone %+v", tomb)
+	}
+
+	// Without an owner every open counts, even with no user ID
+	s.rooms.Create(ctx, &models.Room{Slug: "unowned", BurnAfterReads: 1, ExpireAt: time.Now().Add(time.Hour)})
+	s.do(http.MethodGet, "/api/rooms/unowned", "", nil)
+	if w := s.do(http.MethodGet, "/api/rooms/unowned", "", nil); w.Code != http.StatusGone {
+		t.Errorf("unowned room: expected 410 after its read, got %d", w.Code)
+	}
+}
+
+func TestBurnOnDisconnect(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	room := &models.Room{Slug: "handover", Owner: "owner", Content: textContent(1, "handover"), BurnOnDisconnect: true, ExpireAt: time.Now().Add(time.Hour)}
+	s.rooms.Create(ctx, room)
+	plain := &models.Room{Slug: "plain", Owner: "owner", ExpireAt: time.Now().Add(time.Hour)}
+	s.rooms.Create(ctx, plain)
+
+	s.handler.ViewerLeft(plain.ID)
+	if w := s.do(http.MethodGet, "/api/rooms/plain", "", nil); w.Code != http.StatusOK {
+		t.Errorf("plain room: expected 200, got %d", w.Code)
+	}
+
+	// Only the owner reads it outside a live session
+	for _, path := range []string{"/api/rooms/handover", "/api/rooms/handover/export"} {
+		if w := s.do(http.MethodGet, path, "viewer", nil); w.Code != http.StatusForbidden {
+			t.Errorf("%s by a viewer: expected 403, got %d", path, w.Code)
+		}
+		if w := s.do(http.MethodGet, path, "owner", nil); w.Code != http.StatusOK {
+			t.Errorf("%s by the owner: expected 200, got %d", path, w.Code)
+		}
+	}
+
+	s.handler.ViewerLeft(room.ID)
+	if w := s.do(http.MethodGet, "/api/rooms/handover", "", nil); w.Code != http.StatusGone {
+		t.Errorf("after viewer left: expected 410, got %d", w.Code)
+	}
+}
+
+func TestForkRoom(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	source := &models.Room{Slug: "weekly-notes", Owner: "alice", Content: textContent(1, "agenda"), ExpireAt: time.Now().Add(time.Hour)}
+	s.rooms.Create(ctx, source)
+	path := filepath.Join(s.uploads, source.ID, "f1.txt")
+	os.MkdirAll(filepath.Dir(path), 0o755)
+	os.WriteFile(path, []byte("agenda"), 0o644)
+	s.files.Create(ctx, &models.File{ID: "f1", RoomID: source.ID, Name: "agenda.txt", Size: 6, Path: path})
+
+	fork := func(req ForkRoomRequest) (*httptest.ResponseRecorder, models.Room) {
+		t.Helper()
+		w := s.do(http.MethodPost, "/api/rooms/weekly-notes/fork", "", req)
+		var res models.Room
+		json.Unmarshal(w.Body.Bytes(), &res)
+		return w, res
+	}
+
+	// Content only, custom slug
+	slug := "notes-week2"
+	w, room := fork(ForkRoomRequest{CreateRoomRequest: CreateRoomRequest{Owner: "bob", CustomSlug: &slug}})
+	if w.Code != http.StatusCreated || room.Slug != slug || !room.IsOwner || !bytes.Equal(room.Content, source.Content) || room.ForkedFrom != source.ID {
+		t.Fatalf("fork: got %d %+v", w.Code, room)
+	}
+	if files, _, _ := s.files.List(ctx, room.ID, state.ListFilesOptions{}); len(files) != 0 {
+		t.Errorf("expected no files without includeFiles, got %d", len(files))
+	}
+
+	// Validation is shared with room creation
+	if w, _ := fork(ForkRoomRequest{CreateRoomRequest: CreateRoomRequest{CustomSlug: &slug}}); w.Code != http.StatusConflict {
+		t.Errorf("taken slug: expected 409, got %d", w.Code)
+	}
+
+	// With files, stored under the new room
+	w, room = fork(ForkRoomRequest{CreateRoomRequest: CreateRoomRequest{Owner: "bob"}, IncludeFiles: true})
+	if w.Code != http.StatusCreated || room.Slug == "" {
+		t.Fatalf("fork with files: got %d %s", w.Code, w.Body)
+	}
+	files, _, _ := s.files.List(ctx, room.ID, state.ListFilesOptions{})
+	if len(files) != 1 || files[0].Name != "agenda.txt" || files[0].ID == "f1" {
+		t.Fatalf("expected a copy of agenda.txt, got %+v", files)
+	}
+	if data, err := os.ReadFile(files[0].Path); err != nil || string(data) != "agenda" || !strings.HasPrefix(files[0].Path, filepath.Join(s.uploads, room.ID)) {
+		t.Errorf("copied file at %s: %q (err %v)", files[0].Path, data, err)
+	}
+}
+
+type recordingSender struct {
+	mu       sync.Mutex
+	warnings []notify.Warning
+}
+
+func (r *recordingSender) Send(ctx context.Context, w notify.Warning) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.warnings = append(r.warnings, w)
+	return nil
+}
+
+func TestRoomTemplates(t *testing.T) {
+	s := newTestServer(t)
+
+	w := s.do(http.MethodGet, "/api/templates", "", nil)
+	var list []models.Template
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if w.Code != http.StatusOK || len(list) == 0 || !list[0].BuiltIn {
+		t.Fatalf("list built-ins: got %d: %s", w.Code, w.Body)
+	}
+
+	slug := "standup"
+	w = s.do(http.MethodPost, "/api/rooms", "", CreateRoomRequest{Owner: "alice", CustomSlug: &slug, Template: "meeting-notes"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create from template: expected 201, got %d: %s", w.Code, w.Body)
+	}
+	var room models.Room
+	json.Unmarshal(w.Body.Bytes(), &room)
+	if yjs.ValidateUpdate(room.Content) != nil || len(room.Content) <= 2 {
+		t.Errorf("expected rendered content, got %+v", room.Content)
+	}
+
+	if w := s.do(http.MethodPost, "/api/rooms", "", CreateRoomRequest{Template: "missing"}); w.Code != http.StatusNotFound {
+		t.Errorf("unknown template: expected 404, got %d", w.Code)
+	}
+
+	doc := models.DocNode{Type: "doc", Content: []models.DocNode{
+		{Type: "paragraph", Content: []models.DocNode{{Type: "text", Text: "Notes for {{slug}}"}}},
+	}}
+	save := SaveTemplateRequest{Room: "standup", Name: "Standup", Doc: doc}
+	if w := s.do(http.MethodPost, "/api/templates", "bob", save); w.Code != http.StatusForbidden {
+		t.Errorf("save as non-owner: expected 403, got %d", w.Code)
+	}
+	w = s.do(http.MethodPost, "/api/templates", "alice", save)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("save template: expected 201, got %d: %s", w.Code, w.Body)
+	}
+	var saved models.Template
+	json.Unmarshal(w.Body.Bytes(), &saved)
+
+	w = s.do(http.MethodGet, "/api/templates", "alice", nil)
+	list = nil
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if last := list[len(list)-1]; last.ID != saved.ID || last.Owner != "alice" {
+		t.Errorf("expected alice's template last, got %+v", last)
+	}
+
+	// Saved templates are private to their owner
+	if w := s.do(http.MethodPost, "/api/rooms", "", CreateRoomRequest{Owner: "bob", Template: saved.ID}); w.Code != http.StatusNotFound {
+		t.Errorf("other user's template: expected 404, got %d", w.Code)
+	}
+	if w := s.do(http.MethodPost, "/api/rooms", "", CreateRoomRequest{Owner: "alice", Template: saved.ID}); w.Code != http.StatusCreated {
+		t.Errorf("own template: expected 201, got %d: %s", w.Code, w.Body)
+	}
+
+	// Templates are held to the content size limit when saved and applied
+	limit := s.cfg.Rooms.MaxContentSize
+	s.cfg.Rooms.MaxContentSize = 1024
+	big := save
+	big.Doc = models.DocNode{Type: "doc", Content: []models.DocNode{
+		{Type: "paragraph", Content: []models.DocNode{{Type: "text", Text: strings.Repeat("long ", 400)}}},
+	}}
+	if w := s.do(http.MethodPost, "/api/templates", "alice", big); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized template: expected 413, got %d", w.Code)
+	}
+	s.cfg.Rooms.MaxContentSize = 8
+	huge := "from-template"
+	if w := s.do(http.MethodPost, "/api/rooms", "", CreateRoomRequest{Owner: "alice", CustomSlug: &huge, Template: saved.ID}); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("template over the limit: expected 413, got %d", w.Code)
+	}
+	if ok, _ := s.rooms.Exists(context.Background(), huge); ok {
+		t.Error("room from a template over the limit should be deleted")
+	}
+	s.cfg.Rooms.MaxContentSize = limit
+
+	if w := s.do(http.MethodDelete, "/api/templates/meeting-notes", "alice", nil); w.Code != http.StatusForbidden {
+		t.Errorf("delete built-in: expected 403, got %d", w.Code)
+	}
+	if w := s.do(http.MethodDelete, "/api/templates/"+saved.ID, "alice", nil); w.Code != http.StatusNoContent {
+		t.Errorf("delete: expected 204, got %d", w.Code)
+	}
+}
+
+func TestExpiryWarnings(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	sent := &recordingSender{}
+	s.handler.notifiers = []notify.Sender{sent}
+
+	soon := &models.Room{Slug: "soon", Owner: "owner", Contact: "owner@example.com", ExpireAt: time.Now().Add(30 * time.Minute)}
+	later := &models.Room{Slug: "later", Owner: "owner", ExpireAt: time.Now().Add(48 * time.Hour)}
+	s.rooms.Create(ctx, soon)
+	s.rooms.Create(ctx, later)
+
+	// Each expiry is warned about once
+	s.handler.warnExpiring(ctx)
+	s.handler.warnExpiring(ctx)
+	if len(sent.warnings) != 1 || sent.warnings[0].Slug != "soon" || sent.warnings[0].Contact != "owner@example.com" {
+		t.Fatalf("expected one warning for soon, got %+v", sent.warnings)
+	}
+
+	extendURL, err := url.Parse(sent.warnings[0].ExtendURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := s.do(http.MethodGet, "/api/rooms/soon/extend?token=forged", "", nil); w.Code != http.StatusForbidden {
+		t.Errorf("forged token: expected 403, got %d", w.Code)
+	}
+
+	// Opening the link only asks to confirm, as scanners open links too
+	w := s.do(http.MethodGet, extendURL.RequestURI(), "", nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `<form method="post">`) {
+		t.Fatalf("extend page: expected 200 with a form, got %d: %s", w.Code, w.Body)
+	}
+	if room, _ := s.rooms.Get(ctx, soon.ID); time.Until(room.ExpireAt) > time.Hour {
+		t.Errorf("opening the link should not extend the room, got %v", room.ExpireAt)
+	}
+
+	form := url.Values{"token": {extendURL.Query().Get("token")}}
+	req := httptest.NewRequest(http.MethodPost, extendURL.Path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Room extended") {
+		t.Fatalf("extend: expected 200 with a page, got %d: %s", w.Code, w.Body)
+	}
+	if room, _ := s.rooms.Get(ctx, soon.ID); time.Until(room.ExpireAt) < 23*time.Hour {
+		t.Errorf("expected expiry pushed back by the empty room TTL, got %v", room.ExpireAt)
+	}
+
+	// The link is spent once the expiry moves; the owner can still extend
+	if w := s.do(http.MethodGet, extendURL.RequestURI(), "", nil); w.Code != http.StatusForbidden {
+		t.Errorf("reused link: expected 403, got %d", w.Code)
+	}
+	if w := s.do(http.MethodPost, "/api/rooms/soon/extend", "owner", nil); w.Code != http.StatusOK {
+		t.Errorf("owner extend: expected 200, got %d", w.Code)
+	}
+
+	s.handler.warnExpiring(ctx)
+	if len(sent.warnings) != 1 {
+		t.Errorf("extended room should not be warned again yet, got %d warnings", len(sent.warnings))
+	}
+}
+
+func TestCheckSlug(t *testing.T) {
+	s := newTestServer(t)
+
+	check := func(slug string) SlugAvailability {
+		t.Helper()
+		w := s.do(http.MethodGet, "/api/slugs/"+slug, "", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("check %s: expected 200, got %d", slug, w.Code)
+		}
+		var res SlugAvailability
+		json.Unmarshal(w.Body.Bytes(), &res)
+		return res
+	}
+
+	if res := check("team-alpha"); !res.Valid || !res.Available || len(res.Suggestions) != 0 {
+		t.Errorf("free slug: unexpected %+v", res)
+	}
+
+	for _, slug := range []string{"team-alpha", "team-alpha2"} {
+		slug := slug
+		s.do(http.MethodPost, "/api/rooms", "", CreateRoomRequest{CustomSlug: &slug})
+	}
+
+	res := check("team-alpha")
+	if !res.Valid || res.Available || res.Error == "" {
+		t.Errorf("taken slug: unexpected %+v", res)
+	}
+	if len(res.Suggestions) == 0 || res.Suggestions[0] != "team-alpha3" {
+		t.Errorf("expected team-alpha3 as the first suggestion, got %v", res.Suggestions)
+	}
+	for _, suggestion := range res.Suggestions {
+		if suggestion == "team-alpha2" {
+			t.Errorf("suggested taken slug %s", suggestion)
+		}
+	}
+
+	res = check("api")
+	if res.Valid || res.Available || res.Error != utils.ErrSlugReserved.Error() || len(res.Suggestions) == 0 {
+		t.Errorf("reserved slug: unexpected %+v", res)
+	}
+}
+
+func TestCreateRoomConcurrentCustomSlug(t *testing.T) {
+	s := newTestServer(t)
+
+	const n = 20
+	slug := "race"
+	codes := make(chan int, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- s.do(http.MethodPost, "/api/rooms", "", CreateRoomRequest{CustomSlug: &slug}).Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	created := 0
+	for code := range codes {
+		switch code {
+		case http.StatusCreated:
+			created++
+		case http.StatusConflict:
+		default:
+			t.Errorf("unexpected status %d", code)
+		}
+	}
+	if created != 1 {
+		t.Errorf("expected exactly one room created, got %d", created)
+	}
+}
+
+func TestUpdateFilePermissions(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	s.rooms.Create(ctx, &models.Room{ID: "room-1", Slug: "docs", Owner: "owner", ExpireAt: time.Now().Add(time.Hour)})
+	s.files.Create(ctx, &models.File{ID: "f1", RoomID: "room-1", UploaderID: "uploader", Name: "a.txt", Path: "uploads/docs/f1.txt"})
+
+	name := "renamed.txt"
+	tests := []struct {
+		user string
+		want int
+	}{
+		{"", http.StatusBadRequest},
+		{"stranger", http.StatusForbidden},
+		{"uploader", http.StatusOK},
+		{"owner", http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := s.do(http.MethodPatch, "/api/rooms/docs/files/f1", tt.user, UpdateFileRequest{Name: &name})
+		if w.Code != tt.want {
+			t.Errorf("user %q: expected %d, got %d", tt.user, tt.want, w.Code)
+		}
+	}
+
+	file, _ := s.files.Get(ctx, "room-1", "f1")
+	if file.Name != name {
+		t.Errorf("expected name %q, got %q", name, file.Name)
+	}
+
+	// Listings say who may edit instead of naming the uploader
+	for user, want := range map[string]bool{"": false, "stranger": false, "uploader": true, "owner": true} {
+		w := s.do(http.MethodGet, "/api/rooms/docs/files", user, nil)
+		var files []models.File
+		json.Unmarshal(w.Body.Bytes(), &files)
+		if len(files) != 1 || files[0].CanEdit != want || strings.Contains(w.Body.String(), "uploader") {
+			t.Errorf("user %q: expected canEdit %v, got %s", user, want, w.Body)
+		}
+	}
+}
+
+func TestListRooms(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	now := time.Now()
+	for _, room := range []*models.Room{
+		{ID: "r1", Slug: "quiet", Owner: "alice", ActiveAt: now.Add(-time.Hour), ExpireAt: now.Add(3 * time.Hour)},
+		{ID: "r2", Slug: "busy", Owner: "alice", ActiveAt: now, ExpireAt: now.Add(2 * time.Hour)},
+		{ID: "r3", Slug: "forever", Owner: "alice", ActiveAt: now.Add(-2 * time.Hour), Pinned: true},
+		{ID: "r4", Slug: "bobs", Owner: "bob", ActiveAt: now, ExpireAt: now.Add(time.Hour)},
+	} {
+		s.rooms.Create(ctx, room)
+	}
+	s.files.Create(ctx, &models.File{ID: "f1", RoomID: "r2", Name: "a.txt"})
+	s.files.Create(ctx, &models.File{ID: "f2", RoomID: "r2", Name: "b.txt"})
+
+	list := func(path string) []RoomSummary {
+		t.Helper()
+		w := s.do(http.MethodGet, path, "alice", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", path, w.Code, w.Body)
+		}
+		if got := w.Header().Get("X-Total-Count"); got != "3" {
+			t.Errorf("%s: expected X-Total-Count 3, got %q", path, got)
+		}
+		var rooms []RoomSummary
+		json.Unmarshal(w.Body.Bytes(), &rooms)
+		return rooms
+	}
+	slugs := func(rooms []RoomSummary) string {
+		var out []string
+		for _, r := range rooms {
+			out = append(out, r.Slug)
+		}
+		return strings.Join(out, ",")
+	}
+
+	rooms := list("/api/rooms?owner=alice")
+	if got := slugs(rooms); got != "busy,quiet,forever" {
+		t.Errorf("by activity: got %s", got)
+	}
+	if rooms[0].Files != 2 || rooms[1].Files != 0 || rooms[0].Participants != 0 {
+		t.Errorf("unexpected counts %+v", rooms[0])
+	}
+	if got := slugs(list("/api/rooms?owner=alice&sort=expiry")); got != "busy,quiet,forever" {
+		t.Errorf("by expiry: got %s", got)
+	}
+	if got := slugs(list("/api/rooms?owner=alice&sort=expiry&order=desc")); got != "forever,quiet,busy" {
+		t.Errorf("by expiry, descending: got %s", got)
+	}
+	if got := slugs(list("/api/rooms?owner=alice&limit=2&page=2")); got != "forever" {
+		t.Errorf("page 2: got %s", got)
+	}
+
+	// Websocket edits count as activity
+	activity := make(chan string)
+	refreshCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	go s.handler.RefreshOnActivity(refreshCtx, activity)
+	activity <- "r1"
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if got := slugs(list("/api/rooms")); got == "quiet,busy,forever" {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("after websocket activity: got %s", got)
+		}
+	}
+
+	if w := s.do(http.MethodGet, "/api/rooms?owner=alice", "bob", nil); w.Code != http.StatusForbidden {
+		t.Errorf("other user: expected 403, got %d", w.Code)
+	}
+	if got := slugs(list("/api/rooms")); got != "quiet,busy,forever" {
+		t.Errorf("without ?owner=: got %s", got)
+	}
+
+	// Nothing a reader of alice's rooms gets names her
+	for _, path := range []string{"/api/rooms/busy", "/api/rooms/busy/files", "/api/rooms?owner=bob"} {
+		if w := s.do(http.MethodGet, path, "bob", nil); strings.Contains(w.Body.String(), "alice") {
+			t.Errorf("%s: owner ID sent to another user: %s", path, w.Body)
+		}
+	}
+	if w := s.do(http.MethodGet, "/api/rooms?owner=alice", "", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("no user: expected 400, got %d", w.Code)
+	}
+	if w := s.do(http.MethodGet, "/api/rooms?owner=alice&sort=name", "alice", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid sort: expected 400, got %d", w.Code)
+	}
+}
+
+func TestListFilesSortingAndPaging(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	s.rooms.Create(ctx, &models.Room{ID: "room-1", Slug: "docs", ExpireAt: time.Now().Add(time.Hour)})
+
+	base := time.Now()
+	for i, f := range []models.File{
+		{ID: "a", Name: "charlie", Size: 30},
+		{ID: "b", Name: "alpha", Size: 10},
+		{ID: "c", Name: "bravo", Size: 20, Pinned: true},
+	} {
+		f.RoomID = "room-1"
+		f.CreatedAt = base.Add(time.Duration(i) * time.Minute)
+		s.files.Create(ctx, &f)
+	}
+
+	names := func(path string) []string {
+		w := s.do(http.MethodGet, path, "", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", path, w.Code)
+		}
+		if got := w.Header().Get("X-Total-Count"); got != "3" {
+			t.Errorf("%s: expected X-Total-Count 3, got %q", path, got)
+		}
+		var files []models.File
+		json.Unmarshal(w.Body.Bytes(), &files)
+		var out []string
+		for _, f := range files {
+			out = append(out, f.Name)
+		}
+		return out
+	}
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"/api/rooms/docs/files", []string{"bravo", "charlie", "alpha"}},
+		{"/api/rooms/docs/files?sort=name", []string{"bravo", "alpha", "charlie"}},
+		{"/api/rooms/docs/files?sort=size&order=desc", []string{"bravo", "charlie", "alpha"}},
+		{"/api/rooms/docs/files?sort=name&limit=2&page=2", []string{"charlie"}},
+	}
+	for _, tt := range tests {
+		got := names(tt.path)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.path, tt.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: expected %v, got %v", tt.path, tt.want, got)
+				break
+			}
+		}
+	}
+
+	if w := s.do(http.MethodGet, "/api/rooms/docs/files?sort=owner", "", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid sort: expected 400, got %d", w.Code)
+	}
+}
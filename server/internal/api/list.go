@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pranavdhawale/notex/server/internal/models"
+	"github.com/pranavdhawale/notex/server/internal/state"
+)
+
+const (
+	defaultRoomsPageSize = 20
+	maxRoomsPageSize     = 100
+)
+
+// RoomSummary is a room in an owner's listing
+type RoomSummary struct {
+	models.Room
+	Files        int64 `json:"files"`
+	Participants int   `json:"participants"` // Clients connected right now
+}
+
+// ListRooms lists the rooms of the caller. The user ID in X-User-ID is
+// the owner's secret: the server never sends it to anyone else, so only
+// the owner can present it. ?owner=, if given, must be that same ID. Rooms
+// sort by last activity (newest first) or by expiry (soonest first, pinned
+// rooms last); ?order= reverses either. Paged like ListFiles, with the
+// total in X-Total-Count.
+func (h *Handler) ListRooms(c *gin.Context) {
+	owner := c.GetHeader("X-User-ID")
+	if owner == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing User ID header"})
+		return
+	}
+	if q := c.Query("owner"); q != "" && q != owner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner can list their rooms"})
+		return
+	}
+
+	sort, ok := state.ParseRoomSort(c.DefaultQuery("sort", "activity"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort field (use activity or expiry)"})
+		return
+	}
+
+	defaultOrder := "asc"
+	if sort == state.RoomSortActivity {
+		defaultOrder = "desc"
+	}
+	desc := false
+	switch c.DefaultQuery("order", defaultOrder) {
+	case "asc":
+	case "desc":
+		desc = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort order (use asc or desc)"})
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultRoomsPageSize)))
+	if err != nil || limit < 1 || limit > maxRoomsPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid limit (1-%d)", maxRoomsPageSize)})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rooms, total, err := h.rooms.ListByOwner(ctx, owner, state.ListRoomsOptions{
+		Sort:  sort,
+		Desc:  desc,
+		Skip:  (page - 1) * limit,
+		Limit: limit,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	summaries := make([]RoomSummary, len(rooms))
+	for i, room := range rooms {
+		room.IsOwner = true
+		// The total of a one-file page is the file count
+		_, files, err := h.files.List(ctx, room.ID, state.ListFilesOptions{Limit: 1})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		summaries[i] = RoomSummary{Room: room, Files: files, Participants: h.hub.Participants(room.ID)}
+	}
+
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	c.JSON(http.StatusOK, summaries)
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pranavdhawale/notex/server/internal/models"
+	"github.com/pranavdhawale/notex/server/internal/search"
+	"github.com/pranavdhawale/notex/server/internal/state"
+	"github.com/pranavdhawale/notex/server/internal/yjs"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	maxSearchRooms     = 100 // Slugs a search can name besides the caller's own rooms
+)
+
+// indexRoom updates the search entry of a room from its content and file
+// names. Burn-after-reading rooms stay out of the index, so search can
+// neither reveal them nor read them without counting. Failures only leave
+// the entry stale until the next change, so they are logged.
+func (h *Handler) indexRoom(ctx context.Context, room *models.Room) {
+	if room.Burns() || room.Burned() {
+		h.unindexRoom(room.ID)
+		return
+	}
+
+	var text string
+	if len(room.Content) > 0 {
+		var err error
+		if text, err = yjs.Text(room.Content); err != nil {
+			log.Printf("Failed to extract text of room %s: %v", room.ID, err)
+		}
+	}
+
+	files, _, err := h.files.List(ctx, room.ID, state.ListFilesOptions{})
+	if err != nil {
+		log.Printf("Failed to list files of room %s: %v", room.ID, err)
+	}
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = f.Name
+	}
+
+	if err := h.index.Put(room, text, names); err != nil {
+		log.Printf("Failed to index room %s: %v", room.ID, err)
+	}
+}
+
+// FillIndex indexes every room unless the search index already holds them,
+// so rooms from before the index existed can be found. Runs at startup,
+// in the background.
+func (h *Handler) FillIndex(ctx context.Context) {
+	if h.index.Filled() {
+		return
+	}
+
+	ids, err := h.rooms.IDs(ctx)
+	if err != nil {
+		log.Printf("Failed to list rooms to index: %v", err)
+		return
+	}
+	failed := false
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return
+		}
+		queryCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		room, err := h.rooms.Get(queryCtx, id)
+		if err == nil {
+			h.indexRoom(queryCtx, room)
+		} else if !errors.Is(err, state.ErrNotFound) {
+			log.Printf("Failed to load room %s to index: %v", id, err)
+			failed = true
+		}
+		cancel()
+	}
+
+	// Try again on the next start
+	if failed {
+		return
+	}
+	if err := h.index.MarkFilled(); err != nil {
+		log.Printf("Failed to mark the search index filled: %v", err)
+		return
+	}
+	if len(ids) > 0 {
+		log.Printf("Indexed %d rooms for search", len(ids))
+	}
+}
+
+func (h *Handler) unindexRoom(roomID string) {
+	if err := h.index.Delete(roomID); err != nil {
+		log.Printf("Failed to remove room %s from the search index: %v", roomID, err)
+	}
+}
+
+// Search finds rooms whose content or file names contain every word of
+// ?q=. It covers the caller's own rooms and the rooms named in ?rooms=, a
+// comma-separated list of slugs: knowing a slug is what lets anyone open a
+// room. Results carry highlighted snippets; ?limit= caps them.
+func (h *Handler) Search(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing search query"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultSearchLimit)))
+	if err != nil || limit < 1 || limit > maxSearchLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid limit (1-%d)", maxSearchLimit)})
+		return
+	}
+
+	var slugs []string
+	if v := c.Query("rooms"); v != "" {
+		slugs = strings.Split(v, ",")
+	}
+	if len(slugs) > maxSearchRooms {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Too many rooms (at most %d)", maxSearchRooms)})
+		return
+	}
+	owner := c.GetHeader("X-User-ID")
+	if owner == "" && len(slugs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing User ID header"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Unknown slugs are skipped rather than reported, so search cannot be
+	// used to probe which rooms exist
+	var ids []string
+	for _, slug := range slugs {
+		room, _, err := h.rooms.Resolve(ctx, strings.TrimSpace(slug))
+		if err == nil {
+			ids = append(ids, room.ID)
+		}
+	}
+
+	hits, err := h.index.Search(q, owner, ids, limit)
+	if err != nil {
+		log.Printf("Failed to search for %q: %v", q, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Search failed"})
+		return
+	}
+
+	// Rooms that expired since they were indexed are dropped from the index
+	hitIDs := make([]string, len(hits))
+	for i, hit := range hits {
+		hitIDs[i] = hit.RoomID
+	}
+	live, err := h.rooms.Live(ctx, hitIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	results := make([]search.Hit, 0, len(hits))
+	for _, hit := range hits {
+		if !live[hit.RoomID] {
+			h.unindexRoom(hit.RoomID)
+			continue
+		}
+		results = append(results, hit)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pranavdhawale/notex/server/internal/utils"
+)
+
+// maxSlugSuggestions caps the alternatives returned by CheckSlug
+const maxSlugSuggestions = 5
+
+// SlugAvailability is the response of CheckSlug
+type SlugAvailability struct {
+	Slug        string   `json:"slug"`
+	Valid       bool     `json:"valid"`
+	Available   bool     `json:"available"`
+	Error       string   `json:"error,omitempty"`
+	Suggestions []string `json:"suggestions"`
+}
+
+// CheckSlug reports whether a custom slug can be used for CreateRoom and,
+// if not, suggests close alternatives that can
+func (h *Handler) CheckSlug(c *gin.Context) {
+	slug := strings.ToLower(strings.TrimSpace(c.Param("slug")))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res := SlugAvailability{Slug: slug, Suggestions: []string{}}
+	if err := h.validateSlug(slug); err != nil {
+		res.Error = err.Error()
+	} else {
+		res.Valid = true
+		exists, err := h.rooms.Exists(ctx, slug)
+		if err != nil {
+			log.Printf("Failed to check slug %s: %v", slug, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		res.Available = !exists
+		if exists {
+			res.Error = "Room slug already taken"
+		}
+	}
+
+	if !res.Available {
+		suggestions, err := h.suggestSlugs(ctx, slug)
+		if err != nil {
+			log.Printf("Failed to suggest slugs for %s: %v", slug, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		res.Suggestions = suggestions
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// suggestSlugs returns up to maxSlugSuggestions valid, free alternatives.
+// Availability is only a snapshot; CreateRoom still returns 409 if a
+// suggestion is claimed in the meantime.
+func (h *Handler) suggestSlugs(ctx context.Context, slug string) ([]string, error) {
+	suggestions := []string{}
+	for _, candidate := range utils.SlugCandidates(slug) {
+		if len(suggestions) == maxSlugSuggestions {
+			break
+		}
+		if h.validateSlug(candidate) != nil {
+			continue
+		}
+
+		exists, err := h.rooms.Exists(ctx, candidate)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			suggestions = append(suggestions, candidate)
+		}
+	}
+	return suggestions, nil
+}
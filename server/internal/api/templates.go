@@ -0,0 +1,187 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pranavdhawale/notex/server/internal/models"
+	"github.com/pranavdhawale/notex/server/internal/state"
+	"github.com/pranavdhawale/notex/server/internal/templates"
+)
+
+type SaveTemplateRequest struct {
+	Room        string         `json:"room" binding:"required"` // Slug of the room the template is saved from
+	Name        string         `json:"name" binding:"required"`
+	Description string         `json:"description,omitempty"`
+	Doc         models.DocNode `json:"doc"` // The editor's JSON document
+}
+
+// ListTemplates returns the built-in templates followed by the caller's own
+func (h *Handler) ListTemplates(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	list := append([]models.Template{}, h.builtins...)
+	if userID := c.GetHeader("X-User-ID"); userID != "" {
+		own, err := h.templates.List(ctx, userID)
+		if err != nil {
+			log.Printf("Failed to list templates of %s: %v", userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		list = append(list, own...)
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// SaveTemplate saves the document of a room as a template of its owner.
+// Templates are kept apart from rooms, so they outlive the room. Like
+// saves, they are limited to rooms.maxContentSize.
+func (h *Handler) SaveTemplate(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxSaveBody())
+
+	var req SaveTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.contentTooLarge(c)
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name must be between 1 and 100 characters"})
+		return
+	}
+	if req.Doc.Type != "doc" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "doc must be an editor document"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	room, _, err := h.rooms.Resolve(ctx, req.Room)
+	if errors.Is(err, state.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	userID := c.GetHeader("X-User-ID")
+	if room.Owner == "" || userID != room.Owner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the room owner can save it as a template"})
+		return
+	}
+
+	t := &models.Template{
+		Name:        name,
+		Description: strings.TrimSpace(req.Description),
+		Owner:       userID,
+		Doc:         req.Doc,
+		CreatedAt:   time.Now(),
+	}
+	// Catch documents that cannot be turned into room content now rather
+	// than when a room is created from them
+	content, err := templates.Render(t, templates.Vars(room.Slug, t.CreatedAt))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid doc: " + err.Error()})
+		return
+	}
+	if int64(len(content)) > h.cfg.Rooms.MaxContentSize {
+		h.contentTooLarge(c)
+		return
+	}
+
+	if err := h.templates.Create(ctx, t); err != nil {
+		log.Printf("Failed to save template: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save template"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, t)
+}
+
+// DeleteTemplate deletes one of the caller's templates
+func (h *Handler) DeleteTemplate(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	t := h.findTemplate(ctx, c, c.Param("id"))
+	if t == nil {
+		return
+	}
+	if t.BuiltIn || t.Owner != c.GetHeader("X-User-ID") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the template owner can delete it"})
+		return
+	}
+
+	if err := h.templates.Delete(ctx, t.ID); err != nil {
+		log.Printf("Failed to delete template %s: %v", t.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete template"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// findTemplate looks up a built-in or saved template. On failure the error
+// response is written and nil is returned.
+func (h *Handler) findTemplate(ctx context.Context, c *gin.Context, id string) *models.Template {
+	for i := range h.builtins {
+		if h.builtins[i].ID == id {
+			return &h.builtins[i]
+		}
+	}
+
+	t, err := h.templates.Get(ctx, id)
+	if errors.Is(err, state.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return nil
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return nil
+	}
+	return t
+}
+
+// applyTemplate renders a template into the content of a newly inserted
+// room, now that its slug is known. The size limit is checked again, as
+// variables grow the content and the limit may have shrunk since the
+// template was saved. On failure the room is deleted, the error response
+// is written and false is returned.
+func (h *Handler) applyTemplate(ctx context.Context, c *gin.Context, room *models.Room, t *models.Template) bool {
+	content, err := templates.Render(t, templates.Vars(room.Slug, room.CreatedAt))
+	if err == nil && int64(len(content)) > h.cfg.Rooms.MaxContentSize {
+		_ = h.rooms.Delete(ctx, room.ID)
+		h.contentTooLarge(c)
+		return false
+	}
+	if err == nil {
+		expireAt := h.nextExpiry(room, true)
+		var version int64
+		if version, err = h.rooms.SaveContent(ctx, room.ID, content, expireAt, room.Version); err == nil {
+			room.Content = content
+			room.ExpireAt = expireAt
+			room.Version = version
+			return true
+		}
+	}
+
+	log.Printf("Failed to apply template %s to room %s: %v", t.ID, room.ID, err)
+	_ = h.rooms.Delete(ctx, room.ID)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create room from template"})
+	return false
+}
@@ -2,41 +2,44 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/pranavdhawale/notex/server/internal/models"
 	"github.com/pranavdhawale/notex/server/internal/state"
-	"go.mongodb.org/mongo-driver/bson"
+	"github.com/pranavdhawale/notex/server/internal/ws"
 )
 
-const MaxFileSize = 200 * 1024 * 1024 // 200MB
-
-func UploadFile(c *gin.Context) {
-	roomID := c.Param("room")
-	roomParam := c.Param("room") // slug acts as room ID
-	
-	// Validate room exists? For V1 speed, we assume slug is valid or create folder on fly.
-	// We'll trust the path param.
-	
+func (h *Handler) UploadFile(c *gin.Context) {
 	file, err := c.FormFile("file")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
 		return
 	}
 
-	if file.Size > MaxFileSize {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File exceeds 200MB limit"})
+	if file.Size > h.cfg.Uploads.MaxFileSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("File exceeds %dMB limit", h.cfg.Uploads.MaxFileSize/(1024*1024))})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	room, _ := h.findRoom(ctx, c)
+	if room == nil {
 		return
 	}
 
-	// Create uploads directory for room
-	uploadDir := fmt.Sprintf("uploads/%s", roomParam)
+	// Create uploads directory for room, named by ID so renames don't move it
+	uploadDir := filepath.Join(h.cfg.Uploads.Dir, room.ID)
 	if err := os.MkdirAll(uploadDir, os.ModePerm); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload directory"})
 		return
@@ -57,7 +60,7 @@ func UploadFile(c *gin.Context) {
 	// Create File Record
 	fileRecord := models.File{
 		ID:        uniqueId,
-		RoomID:    roomID, // using slug as ID for now
+		RoomID:    room.ID,
 		UploaderID: c.GetHeader("X-User-ID"), // Capture from header
 		Name:      file.Filename,
 		Size:      file.Size,
@@ -65,58 +68,147 @@ func UploadFile(c *gin.Context) {
 		CreatedAt: time.Now(),
 	}
 
-	// Save to Mongo
-	collection := state.MongoDatabase.Collection("files")
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	_, err = collection.InsertOne(ctx, fileRecord)
-	if err != nil {
+	// Save Metadata
+	if err := h.files.Create(ctx, &fileRecord); err != nil {
 		os.Remove(dst) // Cleanup
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
 	}
 
 	// Construct public URL
-	fileRecord.URL = fmt.Sprintf("/uploads/%s/%s", roomParam, storedFilename)
+	fileRecord.URL = fileURL(&fileRecord)
+
+	h.hub.Notify(room.ID, ws.Event{Type: ws.EventFileAdded, Data: fileRecord})
+	h.indexRoom(ctx, room)
 
+	fileRecord.CanEdit = canModifyFile(room, &fileRecord, c.GetHeader("X-User-ID"))
 	c.JSON(http.StatusCreated, fileRecord)
 }
 
+const (
+	defaultFilesPageSize = 50
+	maxFilesPageSize     = 200
+)
+
 // ListFiles - helper to get files for a room
-func ListFiles(c *gin.Context) {
-	roomID := c.Param("room")
-	
-	collection := state.MongoDatabase.Collection("files")
+// Supports ?sort=name|size|date, ?order=asc|desc, ?page= and ?limit=.
+// Pinned files always come first. The total count is returned in X-Total-Count.
+func (h *Handler) ListFiles(c *gin.Context) {
+	sort, ok := state.ParseFileSort(c.DefaultQuery("sort", "date"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort field (use name, size or date)"})
+		return
+	}
+
+	desc := false
+	switch c.DefaultQuery("order", "asc") {
+	case "asc":
+	case "desc":
+		desc = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort order (use asc or desc)"})
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultFilesPageSize)))
+	if err != nil || limit < 1 || limit > maxFilesPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid limit (1-%d)", maxFilesPageSize)})
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	room, _ := h.findRoom(ctx, c)
+	if room == nil {
+		return
+	}
 
-	cursor, err := collection.Find(ctx, bson.M{"room_id": roomID})
+	files, total, err := h.files.List(ctx, room.ID, state.ListFilesOptions{
+		Sort:  sort,
+		Desc:  desc,
+		Skip:  (page - 1) * limit,
+		Limit: limit,
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
 	}
-	defer cursor.Close(ctx)
 
-	var files []models.File
-	if err = cursor.All(ctx, &files); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode files"})
+	// Enrich with URLs and what the requestor may do
+	requestorID := c.GetHeader("X-User-ID")
+	for i := range files {
+		files[i].URL = fileURL(&files[i])
+		files[i].CanEdit = canModifyFile(room, &files[i], requestorID)
+	}
+
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	c.JSON(http.StatusOK, files)
+}
+
+// fileURL builds the public URL of a file; the filename is derived from Path.
+// URLs use the room ID, so they survive renames.
+func fileURL(f *models.File) string {
+	_, fname := filepath.Split(f.Path)
+	return fmt.Sprintf("/uploads/%s/%s", f.RoomID, fname)
+}
+
+// ServeUpload serves an uploaded file. The room may be given by ID (as in
+// fileURL), current slug or alias, so links made before a rename or before
+// rooms had IDs keep working.
+func (h *Handler) ServeUpload(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	roomParam := c.Param("room")
+	room, _, err := h.rooms.Resolve(ctx, roomParam)
+	if errors.Is(err, state.ErrNotFound) {
+		room, err = h.rooms.Get(ctx, roomParam)
+	}
+	if err != nil {
+		c.Status(http.StatusNotFound)
 		return
 	}
 
-	// Enrich with URLs
-	for i, f := range files {
-		// filename is derived from Path
-		_, fname := filepath.Split(f.Path)
-		files[i].URL = fmt.Sprintf("/uploads/%s/%s", f.RoomID, fname)
+	fname := c.Param("file")
+	file, err := h.files.Get(ctx, room.ID, strings.TrimSuffix(fname, filepath.Ext(fname)))
+	if err != nil || filepath.Base(file.Path) != fname {
+		c.Status(http.StatusNotFound)
+		return
 	}
 
-	c.JSON(http.StatusOK, files)
+	c.File(file.Path)
+}
+
+// canModifyFile reports whether the requestor uploaded the file or owns its room
+func canModifyFile(room *models.Room, file *models.File, requestorID string) bool {
+	if file.UploaderID != "" && file.UploaderID == requestorID {
+		return true
+	}
+
+	// Check if requestor is Room Owner
+	return room.Owner != "" && room.Owner == requestorID
+}
+
+const (
+	maxFileNameLength        = 255
+	maxFileDescriptionLength = 1000
+)
+
+type UpdateFileRequest struct {
+	Name        *string `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+	Pinned      *bool   `json:"pinned,omitempty"`
 }
 
-func DeleteFile(c *gin.Context) {
-	roomID := c.Param("room")
+// UpdateFile edits the display metadata of a file (name, description, pinned)
+func (h *Handler) UpdateFile(c *gin.Context) {
 	fileID := c.Param("fileId")
 	requestorID := c.GetHeader("X-User-ID")
 
@@ -125,44 +217,113 @@ func DeleteFile(c *gin.Context) {
 		return
 	}
 
-	collection := state.MongoDatabase.Collection("files")
+	var req UpdateFileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	update := state.FileUpdate{Pinned: req.Pinned}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "File name cannot be empty"})
+			return
+		}
+		if len(name) > maxFileNameLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("File name too long (max %d characters)", maxFileNameLength)})
+			return
+		}
+		update.Name = &name
+	}
+	if req.Description != nil {
+		description := strings.TrimSpace(*req.Description)
+		if len(description) > maxFileDescriptionLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Description too long (max %d characters)", maxFileDescriptionLength)})
+			return
+		}
+		update.Description = &description
+	}
+	if update.Name == nil && update.Description == nil && update.Pinned == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to update"})
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	room, _ := h.findRoom(ctx, c)
+	if room == nil {
+		return
+	}
+
 	// 1. Fetch File Metadata
-	var file models.File
-	err := collection.FindOne(ctx, bson.M{"_id": fileID, "room_id": roomID}).Decode(&file)
+	file, err := h.files.Get(ctx, room.ID, fileID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
 		return
 	}
 
 	// 2. Check Permissions
-	canDelete := false
-	if file.UploaderID != "" && file.UploaderID == requestorID {
-		canDelete = true
-	} else {
-		// Check if requestor is Room Owner
-		roomCollection := state.MongoDatabase.Collection("rooms")
-		var room models.Room
-		err := roomCollection.FindOne(ctx, bson.M{"slug": roomID}).Decode(&room)
-		
-		fmt.Printf("DEBUG DELETE: FileID=%s Requestor=%s Uploader=%s RoomOwner=%s (Error=%v)\n", 
-			fileID, requestorID, file.UploaderID, room.Owner, err)
-
-		if err == nil && room.Owner == requestorID {
-			canDelete = true
+	if !canModifyFile(room, file, requestorID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	// 3. Apply Update
+	file, err = h.files.Update(ctx, room.ID, fileID, update)
+	if err != nil {
+		if errors.Is(err, state.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+			return
 		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	file.URL = fileURL(file)
+
+	h.hub.Notify(room.ID, ws.Event{Type: ws.EventFileUpdated, Data: file})
+	if update.Name != nil {
+		h.indexRoom(ctx, room)
 	}
 
-	if !canDelete {
+	file.CanEdit = true
+	c.JSON(http.StatusOK, file)
+}
+
+func (h *Handler) DeleteFile(c *gin.Context) {
+	fileID := c.Param("fileId")
+	requestorID := c.GetHeader("X-User-ID")
+
+	if requestorID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing User ID header"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	room, _ := h.findRoom(ctx, c)
+	if room == nil {
+		return
+	}
+
+	// 1. Fetch File Metadata
+	file, err := h.files.Get(ctx, room.ID, fileID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+
+	// 2. Check Permissions
+	if !canModifyFile(room, file, requestorID) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
 		return
 	}
 
 	// 3. Delete from DB
-	_, err = collection.DeleteOne(ctx, bson.M{"_id": fileID})
-	if err != nil {
+	if err := h.files.Delete(ctx, room.ID, fileID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
 	}
@@ -170,5 +331,8 @@ func DeleteFile(c *gin.Context) {
 	// 4. Delete from Disk
 	os.Remove(file.Path)
 
+	h.hub.Notify(room.ID, ws.Event{Type: ws.EventFileRemoved, Data: gin.H{"id": fileID}})
+	h.indexRoom(ctx, room)
+
 	c.JSON(http.StatusOK, gin.H{"message": "File deleted"})
 }
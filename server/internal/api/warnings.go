@@ -0,0 +1,252 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/pranavdhawale/notex/server/internal/models"
+	"github.com/pranavdhawale/notex/server/internal/notify"
+	"github.com/pranavdhawale/notex/server/internal/state"
+	"github.com/pranavdhawale/notex/server/internal/ws"
+)
+
+// extendKeyFor returns the key signing extend links. Without a configured
+// secret links stop working when the server restarts.
+func extendKeyFor(secret string) []byte {
+	if secret != "" {
+		return []byte(secret)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err) // crypto/rand does not fail on supported platforms
+	}
+	return key
+}
+
+// parseContact checks an owner contact address, returning the bare address
+func parseContact(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", nil
+	}
+	addr, err := mail.ParseAddress(s)
+	if err != nil {
+		return "", errors.New("Contact must be an email address")
+	}
+	return addr.Address, nil
+}
+
+// extendToken signs an extend link for the current expiry of room,
+// so the link works once: extending moves the expiry and retires it
+func (h *Handler) extendToken(room *models.Room) string {
+	mac := hmac.New(sha256.New, h.extendKey)
+	mac.Write([]byte(room.ID + "\n" + strconv.FormatInt(room.ExpireAt.UnixMilli(), 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// warning describes room for the notification senders
+func (h *Handler) warning(room *models.Room) notify.Warning {
+	base := strings.TrimSuffix(h.cfg.Notify.PublicURL, "/")
+	return notify.Warning{
+		RoomID:    room.ID,
+		Slug:      room.Slug,
+		ExpireAt:  room.ExpireAt,
+		Contact:   room.Contact,
+		RoomURL:   base + "/" + room.Slug,
+		ExtendURL: base + "/api/rooms/" + room.Slug + "/extend?token=" + url.QueryEscape(h.extendToken(room)),
+	}
+}
+
+// WatchExpiring warns about rooms nearing expiry until ctx is done.
+// Connected clients get a control event; the configured webhook and
+// email get a link to a page that extends the room.
+func (h *Handler) WatchExpiring(ctx context.Context) {
+	if h.cfg.Notify.WarnBefore <= 0 {
+		return
+	}
+	ticker := time.NewTicker(h.cfg.Notify.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.warnExpiring(ctx)
+		}
+	}
+}
+
+func (h *Handler) warnExpiring(ctx context.Context) {
+	queryCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rooms, err := h.rooms.Expiring(queryCtx, time.Now().Add(h.cfg.Notify.WarnBefore))
+	if err != nil {
+		log.Printf("Failed to check upcoming room expiry: %v", err)
+		return
+	}
+
+	for i := range rooms {
+		room := &rooms[i]
+
+		// Another instance, or an extension, may have beaten us to it
+		claimed, err := h.rooms.ClaimWarning(queryCtx, room.ID, room.ExpireAt)
+		if err != nil {
+			log.Printf("Failed to claim expiry warning for room %s: %v", room.ID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		h.hub.Notify(room.ID, ws.Event{Type: ws.EventRoomExpiring, Data: gin.H{"expireAt": room.ExpireAt}})
+
+		warning := h.warning(room)
+		for _, sender := range h.notifiers {
+			sendCtx, sendCancel := context.WithTimeout(ctx, 10*time.Second)
+			if err := sender.Send(sendCtx, warning); err != nil {
+				log.Printf("Failed to send expiry warning for room %s: %v", room.ID, err)
+			}
+			sendCancel()
+		}
+	}
+}
+
+// extendPage is the page behind the extend link of warning emails
+var extendPage = template.Must(template.New("extend").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Keep {{.Slug}} longer · notex</title>
+</head>
+<body>
+<h1>{{.Slug}}</h1>
+{{if .Message}}<p>{{.Message}}</p>{{end}}
+{{if not .ExpireAt.IsZero}}<p>Expires {{.ExpireAt.UTC.Format "Mon, 02 Jan 2006 15:04 MST"}}.</p>{{end}}
+{{if .Token}}<form method="post">
+<input type="hidden" name="token" value="{{.Token}}">
+<button type="submit">Keep it longer</button>
+</form>{{end}}
+</body>
+</html>
+`))
+
+type extendView struct {
+	Slug     string
+	Message  string
+	ExpireAt time.Time
+	Token    string // Set to offer the form that extends the room
+}
+
+func renderExtendPage(c *gin.Context, status int, view extendView) {
+	c.Status(status)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := extendPage.Execute(c.Writer, view); err != nil {
+		log.Printf("Failed to render extend page: %v", err)
+	}
+}
+
+// validExtendToken reports whether token is the live extend token of room
+func (h *Handler) validExtendToken(room *models.Room, token string) bool {
+	return token != "" && hmac.Equal([]byte(token), []byte(h.extendToken(room)))
+}
+
+// ConfirmExtend shows the page the extend link of warning emails opens.
+// Mail scanners and link previews open links too, so opening it changes
+// nothing: the room is extended once the form on the page is posted.
+func (h *Handler) ConfirmExtend(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	room, _ := h.findRoom(ctx, c)
+	if room == nil {
+		return
+	}
+	view := extendView{Slug: room.Slug, ExpireAt: room.ExpireAt}
+	switch token := c.Query("token"); {
+	case !h.validExtendToken(room, token):
+		renderExtendPage(c, http.StatusForbidden, extendView{Slug: room.Slug, Message: "This link is invalid or was already used."})
+	case room.Pinned:
+		view.Message = "This room is pinned and does not expire."
+		renderExtendPage(c, http.StatusOK, view)
+	default:
+		view.Message = "This room is about to expire and be deleted with everything in it."
+		view.Token = token
+		renderExtendPage(c, http.StatusOK, view)
+	}
+}
+
+// ExtendRoom pushes back the expiry of a room by its lifetime. It takes
+// the token from a warning link, or the owner's user ID. Posts of the
+// form on the confirmation page get a page back rather than JSON.
+func (h *Handler) ExtendRoom(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	room, _ := h.findRoom(ctx, c)
+	if room == nil {
+		return
+	}
+	form := c.ContentType() == binding.MIMEPOSTForm
+	reply := func(status int, body gin.H) {
+		if !form {
+			c.JSON(status, body)
+			return
+		}
+		view := extendView{Slug: room.Slug, ExpireAt: room.ExpireAt}
+		if msg, ok := body["error"].(string); ok {
+			view.Message, view.ExpireAt = msg+".", time.Time{}
+		} else {
+			view.Message = body["message"].(string) + "."
+		}
+		renderExtendPage(c, status, view)
+	}
+
+	token := c.Query("token")
+	if token == "" {
+		token = c.PostForm("token")
+	}
+	if !h.validExtendToken(room, token) && !ownedBy(c, room) {
+		reply(http.StatusForbidden, gin.H{"error": "Extend link is invalid or was already used"})
+		return
+	}
+
+	if room.Pinned {
+		reply(http.StatusOK, gin.H{"message": "Room is pinned and does not expire"})
+		return
+	}
+
+	next := time.Now().Add(h.roomTTL(room, hasContent(room)))
+	if next.After(room.ExpireAt) {
+		err := h.rooms.SetExpiry(ctx, room.ID, next)
+		if errors.Is(err, state.ErrNotFound) {
+			reply(http.StatusNotFound, gin.H{"error": "Room not found"})
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to extend room %s: %v", room.ID, err)
+			reply(http.StatusInternalServerError, gin.H{"error": "Failed to extend room"})
+			return
+		}
+		room.ExpireAt = next
+		h.hub.Notify(room.ID, ws.Event{Type: ws.EventRoomExtended, Data: gin.H{"expireAt": next}})
+	}
+
+	reply(http.StatusOK, gin.H{"message": "Room extended", "expireAt": room.ExpireAt})
+}
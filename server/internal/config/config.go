@@ -0,0 +1,470 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Config holds every tunable setting of the server.
+// Sources are applied in order: defaults, YAML file, environment, flags.
+type Config struct {
+	Server    ServerConfig    `yaml:"server"`
+	Storage   StorageConfig   `yaml:"storage"`
+	Mongo     MongoConfig     `yaml:"mongo"`
+	Uploads   UploadsConfig   `yaml:"uploads"`
+	Client    ClientConfig    `yaml:"client"`
+	Rooms     RoomsConfig     `yaml:"rooms"`
+	Slugs     SlugsConfig     `yaml:"slugs"`
+	Notify    NotifyConfig    `yaml:"notify"`
+	Search    SearchConfig    `yaml:"search"`
+	Templates TemplatesConfig `yaml:"templates"`
+	WebSocket WebSocketConfig `yaml:"websocket"`
+}
+
+type ServerConfig struct {
+	Port            int           `yaml:"port"`
+	Mode            string        `yaml:"mode"` // Gin mode: debug, release or test
+	CORSOrigins     []string      `yaml:"corsOrigins"`
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+}
+
+// Storage backends
+const (
+	BackendMongo  = "mongo"
+	BackendBolt   = "bolt"   // Embedded single-file database, no Mongo needed
+	BackendMemory = "memory" // Non-persistent, for development and tests
+)
+
+// Compression of room content at rest
+const (
+	CompressionZstd   = "zstd"
+	CompressionSnappy = "snappy" // Faster, compresses less
+	CompressionNone   = "none"
+)
+
+type StorageConfig struct {
+	Backend            string        `yaml:"backend"`
+	BoltPath           string        `yaml:"boltPath"`           // Database file for the bolt backend
+	SweepInterval      time.Duration `yaml:"sweepInterval"`      // How often the bolt backend deletes expired rooms
+	ChunkSweepInterval time.Duration `yaml:"chunkSweepInterval"` // How often the mongo backend deletes content chunks no room uses
+	Compression        string        `yaml:"compression"`        // Codec for saved room content: zstd, snappy or none
+}
+
+type MongoConfig struct {
+	URI      string `yaml:"uri"`
+	Database string `yaml:"database"`
+}
+
+type UploadsConfig struct {
+	Dir         string `yaml:"dir"`
+	MaxFileSize int64  `yaml:"maxFileSize"` // Bytes
+}
+
+// ClientConfig controls serving the built React client from this server
+// instead of a separate nginx container
+type ClientConfig struct {
+	Serve bool   `yaml:"serve"`
+	Dir   string `yaml:"dir"` // Vite dist directory on disk; empty uses the copy embedded in the binary
+}
+
+// Sliding refresh policies: what pushes a room's expiry back
+const (
+	RefreshActivity = "activity" // Saves, REST reads and websocket edits
+	RefreshSave     = "save"     // Saves only
+	RefreshOff      = "off"      // Nothing; expiry only changes when the owner sets it
+)
+
+type RoomsConfig struct {
+	EmptyTTL            time.Duration `yaml:"emptyTTL"`            // Lifetime of a room with no saved content
+	ContentTTL          time.Duration `yaml:"contentTTL"`          // Lifetime of a room with saved content
+	ExpiryCheckInterval time.Duration `yaml:"expiryCheckInterval"` // How often live rooms are checked for expiry
+	MinTTL              time.Duration `yaml:"minTTL"`              // Shortest lifetime an owner may choose
+	MaxTTL              time.Duration `yaml:"maxTTL"`              // Longest lifetime an owner may choose
+	AllowPinning        bool          `yaml:"allowPinning"`        // Whether owners may pin rooms so they never expire
+	Refresh             string        `yaml:"refresh"`             // Sliding refresh policy: activity, save or off
+	RefreshThrottle     time.Duration `yaml:"refreshThrottle"`     // Minimum time between refreshes caused by websocket activity
+	MaxContentSize      int64         `yaml:"maxContentSize"`      // Bytes; largest document a room may save
+}
+
+// SlugsConfig controls generated room names and which custom names are accepted
+type SlugsConfig struct {
+	Strategies          []string `yaml:"strategies"`          // Tried in order: petname2, petname3, word-number, theme:<space|ocean|forest>
+	AttemptsPerStrategy int      `yaml:"attemptsPerStrategy"` // Collisions before escalating to the next strategy
+	Rules               string   `yaml:"rules"`               // Custom slug rules version: v1 (1-2 words) or v2 (up to 4 words)
+	MaxWords            int      `yaml:"maxWords"`            // Overrides the rules version's word limit when set
+	MaxLength           int      `yaml:"maxLength"`           // Overrides the rules version's length limit when set
+	Reserved            []string `yaml:"reserved"`            // Extra names rejected on top of the built-in route names
+	Blocklist           []string `yaml:"blocklist"`           // Extra words rejected on top of the built-in list, leetspeak-normalized
+}
+
+type SearchConfig struct {
+	Dir string `yaml:"dir"` // Directory of the full-text index; empty keeps it in memory
+}
+
+type TemplatesConfig struct {
+	Dir string `yaml:"dir"` // Directory of built-in template JSON files; empty uses the copies embedded in the binary
+}
+
+// NotifyConfig controls warnings about rooms that are about to expire.
+// Connected clients are always told; the webhook and email are optional.
+type NotifyConfig struct {
+	WarnBefore    time.Duration `yaml:"warnBefore"`    // How long before expiry to warn; 0 disables warnings
+	CheckInterval time.Duration `yaml:"checkInterval"` // How often rooms are scanned for upcoming expiry
+	PublicURL     string        `yaml:"publicURL"`     // Base URL users reach the server at, for links in warnings
+	Secret        string        `yaml:"secret"`        // Signs extend links; random per process when empty
+	Webhook       string        `yaml:"webhook"`       // Receives every warning as a JSON POST
+	SMTP          SMTPConfig    `yaml:"smtp"`          // Emails owners who left a contact address
+}
+
+type SMTPConfig struct {
+	Host     string `yaml:"host"` // Empty disables email
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"` // Empty skips authentication
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
+}
+
+type WebSocketConfig struct {
+	MaxMessageSize int64         `yaml:"maxMessageSize"` // Bytes
+	WriteWait      time.Duration `yaml:"writeWait"`      // Time allowed to write a message to the peer
+	PongWait       time.Duration `yaml:"pongWait"`       // Time allowed to read the next pong from the peer
+	SendBuffer     int           `yaml:"sendBuffer"`     // Outbound messages buffered per client
+}
+
+// PingPeriod is how often pings are sent. Must be less than PongWait.
+func (w WebSocketConfig) PingPeriod() time.Duration {
+	return (w.PongWait * 9) / 10
+}
+
+// Default returns the built-in configuration
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:            8080,
+			Mode:            "debug",
+			CORSOrigins:     []string{"http://localhost:5173"},
+			ShutdownTimeout: 30 * time.Second,
+		},
+		Storage: StorageConfig{
+			Backend:            BackendMongo,
+			BoltPath:           "notex.db",
+			SweepInterval:      time.Minute, // Same cadence as Mongo's TTL monitor
+			ChunkSweepInterval: time.Hour,
+			Compression:        CompressionZstd,
+		},
+		Mongo: MongoConfig{
+			URI:      "mongodb://localhost:27017",
+			Database: "notex",
+		},
+		Uploads: UploadsConfig{
+			Dir:         "uploads",
+			MaxFileSize: 200 * 1024 * 1024, // 200MB
+		},
+		Search: SearchConfig{
+			Dir: "search-index",
+		},
+		Rooms: RoomsConfig{
+			EmptyTTL:            24 * time.Hour,     // 1 Day
+			ContentTTL:          7 * 24 * time.Hour, // 7 Days
+			ExpiryCheckInterval: 30 * time.Second,
+			MinTTL:              time.Hour,
+			MaxTTL:              90 * 24 * time.Hour, // 90 Days
+			Refresh:             RefreshActivity,
+			RefreshThrottle:     time.Minute,
+			MaxContentSize:      8 * 1024 * 1024, // 8MB
+		},
+		Slugs: SlugsConfig{
+			Strategies:          []string{"petname2", "word-number", "petname3"},
+			AttemptsPerStrategy: 5,
+			Rules:               "v1",
+		},
+		Notify: NotifyConfig{
+			WarnBefore:    time.Hour,
+			CheckInterval: time.Minute,
+			PublicURL:     "http://localhost:8080",
+			SMTP:          SMTPConfig{Port: 25},
+		},
+		WebSocket: WebSocketConfig{
+			MaxMessageSize: 512 * 1024, // 512KB for large syncs
+			WriteWait:      10 * time.Second,
+			PongWait:       60 * time.Second,
+			SendBuffer:     256,
+		},
+	}
+}
+
+// Load builds the configuration from the YAML file named by -config (or
+// NOTEX_CONFIG), environment variables and command line flags, then validates it.
+func Load(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("notex", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv("NOTEX_CONFIG"), "path to a YAML config file")
+	port := fs.Int("port", 0, "HTTP port")
+	mode := fs.String("mode", "", "gin mode (debug, release, test)")
+	corsOrigins := fs.String("cors-origins", "", "comma-separated allowed CORS origins")
+	backend := fs.String("storage", "", "storage backend (mongo, bolt, memory)")
+	boltPath := fs.String("bolt-path", "", "database file for the bolt backend")
+	mongoURI := fs.String("mongo-uri", "", "MongoDB connection URI")
+	mongoDB := fs.String("mongo-db", "", "MongoDB database name")
+	uploadsDir := fs.String("uploads-dir", "", "directory for uploaded files")
+	serveClient := fs.Bool("serve-client", false, "serve the built client (embedded, or from -client-dir)")
+	clientDir := fs.String("client-dir", "", "serve the client from this dist directory instead of the embedded copy")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+
+	if *configPath != "" {
+		if err := cfg.loadFile(*configPath); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+
+	// Flags win over everything, but only when explicitly set
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "port":
+			cfg.Server.Port = *port
+		case "mode":
+			cfg.Server.Mode = *mode
+		case "cors-origins":
+			cfg.Server.CORSOrigins = splitList(*corsOrigins)
+		case "storage":
+			cfg.Storage.Backend = *backend
+		case "bolt-path":
+			cfg.Storage.BoltPath = *boltPath
+		case "mongo-uri":
+			cfg.Mongo.URI = *mongoURI
+		case "mongo-db":
+			cfg.Mongo.Database = *mongoDB
+		case "uploads-dir":
+			cfg.Uploads.Dir = *uploadsDir
+		case "serve-client":
+			cfg.Client.Serve = *serveClient
+		case "client-dir":
+			cfg.Client.Dir = *clientDir
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (cfg *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+	if err := yaml.UnmarshalWithOptions(data, cfg, yaml.Strict()); err != nil {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadEnv applies environment overrides. NOTEX_* variables take precedence
+// over the legacy unprefixed names still used by the compose files.
+func (cfg *Config) loadEnv() error {
+	str := func(dst *string, names ...string) {
+		for _, name := range names {
+			if v, ok := os.LookupEnv(name); ok && v != "" {
+				*dst = v
+			}
+		}
+	}
+	var errs []error
+	num := func(name string, apply func(int64)) {
+		if v, ok := os.LookupEnv(name); ok && v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				return
+			}
+			apply(n)
+		}
+	}
+	dur := func(name string, dst *time.Duration) {
+		if v, ok := os.LookupEnv(name); ok && v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				return
+			}
+			*dst = d
+		}
+	}
+
+	num("PORT", func(n int64) { cfg.Server.Port = int(n) })
+	num("NOTEX_PORT", func(n int64) { cfg.Server.Port = int(n) })
+	str(&cfg.Server.Mode, "GIN_MODE", "NOTEX_MODE")
+	origins := ""
+	str(&origins, "CLIENT_ORIGIN", "NOTEX_CORS_ORIGINS")
+	if origins != "" {
+		cfg.Server.CORSOrigins = splitList(origins)
+	}
+	dur("NOTEX_SHUTDOWN_TIMEOUT", &cfg.Server.ShutdownTimeout)
+
+	str(&cfg.Storage.Backend, "NOTEX_STORAGE_BACKEND")
+	str(&cfg.Storage.BoltPath, "NOTEX_BOLT_PATH")
+	str(&cfg.Storage.Compression, "NOTEX_STORAGE_COMPRESSION")
+
+	str(&cfg.Mongo.URI, "MONGO_URI", "NOTEX_MONGO_URI")
+	str(&cfg.Mongo.Database, "NOTEX_MONGO_DB")
+
+	str(&cfg.Uploads.Dir, "NOTEX_UPLOADS_DIR")
+	num("NOTEX_MAX_FILE_SIZE", func(n int64) { cfg.Uploads.MaxFileSize = n })
+
+	if v, ok := os.LookupEnv("NOTEX_SERVE_CLIENT"); ok && v != "" {
+		serve, err := strconv.ParseBool(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("NOTEX_SERVE_CLIENT: %w", err))
+		} else {
+			cfg.Client.Serve = serve
+		}
+	}
+	str(&cfg.Client.Dir, "NOTEX_CLIENT_DIR")
+
+	dur("NOTEX_ROOM_EMPTY_TTL", &cfg.Rooms.EmptyTTL)
+	dur("NOTEX_ROOM_CONTENT_TTL", &cfg.Rooms.ContentTTL)
+	dur("NOTEX_ROOM_EXPIRY_CHECK_INTERVAL", &cfg.Rooms.ExpiryCheckInterval)
+	dur("NOTEX_ROOM_MIN_TTL", &cfg.Rooms.MinTTL)
+	dur("NOTEX_ROOM_MAX_TTL", &cfg.Rooms.MaxTTL)
+	if v, ok := os.LookupEnv("NOTEX_ROOM_ALLOW_PINNING"); ok && v != "" {
+		allow, err := strconv.ParseBool(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("NOTEX_ROOM_ALLOW_PINNING: %w", err))
+		} else {
+			cfg.Rooms.AllowPinning = allow
+		}
+	}
+	str(&cfg.Rooms.Refresh, "NOTEX_ROOM_REFRESH")
+	num("NOTEX_ROOM_MAX_CONTENT_SIZE", func(n int64) { cfg.Rooms.MaxContentSize = n })
+
+	if v := os.Getenv("NOTEX_SLUG_STRATEGIES"); v != "" {
+		cfg.Slugs.Strategies = splitList(v)
+	}
+	str(&cfg.Slugs.Rules, "NOTEX_SLUG_RULES")
+	if v := os.Getenv("NOTEX_SLUG_BLOCKLIST"); v != "" {
+		cfg.Slugs.Blocklist = splitList(v)
+	}
+
+	dur("NOTEX_NOTIFY_WARN_BEFORE", &cfg.Notify.WarnBefore)
+	str(&cfg.Notify.PublicURL, "NOTEX_PUBLIC_URL")
+	str(&cfg.Notify.Secret, "NOTEX_NOTIFY_SECRET")
+	str(&cfg.Notify.Webhook, "NOTEX_NOTIFY_WEBHOOK")
+	str(&cfg.Notify.SMTP.Host, "NOTEX_SMTP_HOST")
+	num("NOTEX_SMTP_PORT", func(n int64) { cfg.Notify.SMTP.Port = int(n) })
+	str(&cfg.Notify.SMTP.Username, "NOTEX_SMTP_USERNAME")
+	str(&cfg.Notify.SMTP.Password, "NOTEX_SMTP_PASSWORD")
+	str(&cfg.Notify.SMTP.From, "NOTEX_SMTP_FROM")
+
+	str(&cfg.Templates.Dir, "NOTEX_TEMPLATES_DIR")
+	str(&cfg.Search.Dir, "NOTEX_SEARCH_DIR")
+
+	num("NOTEX_WS_MAX_MESSAGE_SIZE", func(n int64) { cfg.WebSocket.MaxMessageSize = n })
+
+	return errors.Join(errs...)
+}
+
+// Validate reports every invalid setting at once
+func (cfg *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(cfg.Server.Port > 0 && cfg.Server.Port <= 65535, "server.port must be between 1 and 65535, got %d", cfg.Server.Port)
+	check(cfg.Server.Mode == "debug" || cfg.Server.Mode == "release" || cfg.Server.Mode == "test",
+		"server.mode must be debug, release or test, got %q", cfg.Server.Mode)
+	check(len(cfg.Server.CORSOrigins) > 0, "server.corsOrigins must list at least one origin")
+	for _, origin := range cfg.Server.CORSOrigins {
+		u, err := url.Parse(origin)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && (u.Path == "" || u.Path == "/"),
+			"server.corsOrigins: %q is not an http(s) origin", origin)
+	}
+	check(cfg.Server.ShutdownTimeout > 0, "server.shutdownTimeout must be positive")
+
+	switch cfg.Storage.Backend {
+	case BackendMongo:
+		check(strings.HasPrefix(cfg.Mongo.URI, "mongodb://") || strings.HasPrefix(cfg.Mongo.URI, "mongodb+srv://"),
+			"mongo.uri must start with mongodb:// or mongodb+srv://")
+		check(cfg.Mongo.Database != "", "mongo.database is required")
+		check(cfg.Storage.ChunkSweepInterval > 0, "storage.chunkSweepInterval must be positive")
+	case BackendBolt:
+		check(cfg.Storage.BoltPath != "", "storage.boltPath is required for the bolt backend")
+		check(cfg.Storage.SweepInterval > 0, "storage.sweepInterval must be positive")
+	case BackendMemory:
+	default:
+		check(false, "storage.backend must be mongo, bolt or memory, got %q", cfg.Storage.Backend)
+	}
+	check(cfg.Storage.Compression == CompressionZstd || cfg.Storage.Compression == CompressionSnappy || cfg.Storage.Compression == CompressionNone,
+		"storage.compression must be zstd, snappy or none, got %q", cfg.Storage.Compression)
+
+	check(cfg.Uploads.Dir != "", "uploads.dir is required")
+	check(cfg.Uploads.MaxFileSize > 0, "uploads.maxFileSize must be positive")
+
+	check(cfg.Rooms.EmptyTTL > 0, "rooms.emptyTTL must be positive")
+	check(cfg.Rooms.ContentTTL > 0, "rooms.contentTTL must be positive")
+	check(cfg.Rooms.ExpiryCheckInterval > 0, "rooms.expiryCheckInterval must be positive")
+	check(cfg.Rooms.MinTTL > 0, "rooms.minTTL must be positive")
+	check(cfg.Rooms.MaxTTL >= cfg.Rooms.MinTTL, "rooms.maxTTL must not be less than rooms.minTTL")
+	check(cfg.Rooms.Refresh == RefreshActivity || cfg.Rooms.Refresh == RefreshSave || cfg.Rooms.Refresh == RefreshOff,
+		"rooms.refresh must be activity, save or off, got %q", cfg.Rooms.Refresh)
+	check(cfg.Rooms.RefreshThrottle >= 0, "rooms.refreshThrottle must not be negative")
+	check(cfg.Rooms.MaxContentSize > 0, "rooms.maxContentSize must be positive")
+
+	check(len(cfg.Slugs.Strategies) > 0, "slugs.strategies must list at least one strategy")
+	check(cfg.Slugs.AttemptsPerStrategy > 0, "slugs.attemptsPerStrategy must be positive")
+	check(cfg.Slugs.Rules == "v1" || cfg.Slugs.Rules == "v2", "slugs.rules must be v1 or v2, got %q", cfg.Slugs.Rules)
+	check(cfg.Slugs.MaxWords >= 0, "slugs.maxWords must not be negative")
+	check(cfg.Slugs.MaxLength >= 0, "slugs.maxLength must not be negative")
+
+	check(cfg.Notify.WarnBefore >= 0, "notify.warnBefore must not be negative")
+	check(cfg.Notify.WarnBefore == 0 || cfg.Notify.CheckInterval > 0, "notify.checkInterval must be positive")
+	check(isHTTPURL(cfg.Notify.PublicURL), "notify.publicURL: %q is not an http(s) URL", cfg.Notify.PublicURL)
+	check(cfg.Notify.Webhook == "" || isHTTPURL(cfg.Notify.Webhook), "notify.webhook: %q is not an http(s) URL", cfg.Notify.Webhook)
+	if cfg.Notify.SMTP.Host != "" {
+		check(cfg.Notify.SMTP.Port > 0 && cfg.Notify.SMTP.Port <= 65535, "notify.smtp.port must be between 1 and 65535, got %d", cfg.Notify.SMTP.Port)
+		check(cfg.Notify.SMTP.From != "", "notify.smtp.from is required when notify.smtp.host is set")
+	}
+
+	check(cfg.WebSocket.MaxMessageSize > 0, "websocket.maxMessageSize must be positive")
+	check(cfg.WebSocket.WriteWait > 0, "websocket.writeWait must be positive")
+	check(cfg.WebSocket.PongWait > 0, "websocket.pongWait must be positive")
+	check(cfg.WebSocket.SendBuffer > 0, "websocket.sendBuffer must be positive")
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDefaultIsValid(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Fatalf("Default config should be valid: %v", err)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notex.yaml")
+	file := `
+server:
+  port: 9000
+  mode: release
+  corsOrigins: [https://a.example.com]
+mongo:
+  database: fromfile
+rooms:
+  emptyTTL: 2h
+`
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("NOTEX_MONGO_DB", "fromenv")
+	t.Setenv("CLIENT_ORIGIN", "https://b.example.com, https://c.example.com")
+
+	cfg, err := Load([]string{"-config", path, "-port", "9100"})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if cfg.Server.Port != 9100 {
+		t.Errorf("flag should override file port, got %d", cfg.Server.Port)
+	}
+	if cfg.Server.Mode != "release" {
+		t.Errorf("file mode not applied, got %q", cfg.Server.Mode)
+	}
+	if cfg.Mongo.Database != "fromenv" {
+		t.Errorf("env should override file database, got %q", cfg.Mongo.Database)
+	}
+	if len(cfg.Server.CORSOrigins) != 2 || cfg.Server.CORSOrigins[1] != "https://c.example.com" {
+		t.Errorf("expected two CORS origins from env, got %v", cfg.Server.CORSOrigins)
+	}
+	if cfg.Rooms.EmptyTTL != 2*time.Hour {
+		t.Errorf("file TTL not applied, got %v", cfg.Rooms.EmptyTTL)
+	}
+	if cfg.Rooms.ContentTTL != Default().Rooms.ContentTTL {
+		t.Errorf("unset keys should keep defaults, got %v", cfg.Rooms.ContentTTL)
+	}
+}
+
+func TestLoadRejectsUnknownKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notex.yaml")
+	if err := os.WriteFile(path, []byte("server:\n  prot: 80\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load([]string{"-config", path}); err == nil {
+		t.Error("expected error for unknown key")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(*Config)
+	}{
+		{"port out of range", func(c *Config) { c.Server.Port = 70000 }},
+		{"unknown mode", func(c *Config) { c.Server.Mode = "prod" }},
+		{"no origins", func(c *Config) { c.Server.CORSOrigins = nil }},
+		{"origin with path", func(c *Config) { c.Server.CORSOrigins = []string{"https://a.example.com/app"} }},
+		{"bad mongo uri", func(c *Config) { c.Mongo.URI = "localhost:27017" }},
+		{"zero file size", func(c *Config) { c.Uploads.MaxFileSize = 0 }},
+		{"unknown compression", func(c *Config) { c.Storage.Compression = "gzip" }},
+		{"zero chunk sweep", func(c *Config) { c.Storage.ChunkSweepInterval = 0 }},
+		{"zero content size", func(c *Config) { c.Rooms.MaxContentSize = 0 }},
+		{"negative ttl", func(c *Config) { c.Rooms.EmptyTTL = -time.Hour }},
+		{"bad webhook", func(c *Config) { c.Notify.Webhook = "hooks.example.com" }},
+		{"smtp without from", func(c *Config) { c.Notify.SMTP.Host = "localhost" }},
+		{"zero send buffer", func(c *Config) { c.WebSocket.SendBuffer = 0 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.mutate(cfg)
+			if err := cfg.Validate(); err == nil {
+				t.Errorf("expected validation error")
+			}
+		})
+	}
+}
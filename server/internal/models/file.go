@@ -5,10 +5,13 @@ import "time"
 type File struct {
 	ID        string    `bson:"_id,omitempty" json:"id"`
 	RoomID    string    `bson:"room_id" json:"roomId"`
-	UploaderID string   `bson:"uploader_id" json:"uploaderId"`
+	UploaderID string   `bson:"uploader_id" json:"-"` // Never sent, like room owners
 	Name      string    `bson:"name" json:"name"`
+	Description string  `bson:"description,omitempty" json:"description,omitempty"`
+	Pinned    bool      `bson:"pinned" json:"pinned"`
 	Size      int64     `bson:"size" json:"size"`
 	Path      string    `bson:"path" json:"-"`
 	URL       string    `bson:"-" json:"url"` // Computed field
+	CanEdit   bool      `bson:"-" json:"canEdit,omitempty"` // Whether the requestor uploaded the file or owns its room; computed per request
 	CreatedAt time.Time `bson:"created_at" json:"createdAt"`
 }
@@ -5,8 +5,58 @@ import "time"
 type Room struct {
 	ID        string    `bson:"_id,omitempty" json:"id"`
 	Slug      string    `bson:"slug" json:"slug"`
-	Owner     string    `bson:"owner" json:"owner"`       // Ideally a session ID or similar for v1
-	Content   interface{} `bson:"content,omitempty" json:"content,omitempty"`
+	// Client-generated user ID. Knowing it proves ownership, so it is never
+	// sent to clients; they get IsOwner instead.
+	Owner     string    `bson:"owner" json:"-"`
+	IsOwner   bool      `bson:"-" json:"isOwner,omitempty"` // Whether the requestor owns the room
+	Content   []byte      `bson:"content,omitempty" json:"content,omitempty"` // Yjs update; base64 in JSON
+	Codec     string      `bson:"codec,omitempty" json:"codec,omitempty"`     // Compression of the content at rest; empty when stored raw
 	CreatedAt time.Time   `bson:"created_at" json:"createdAt"`
-	ExpireAt  time.Time   `bson:"expire_at" json:"expireAt"`
+	ExpireAt  time.Time   `bson:"expire_at,omitempty" json:"expireAt"` // Zero for pinned rooms
+	TTL       int64       `bson:"ttl,omitempty" json:"ttl,omitempty"`    // Seconds; owner-chosen lifetime replacing the server defaults
+	Pinned    bool        `bson:"pinned,omitempty" json:"pinned,omitempty"` // Never expires
+	Version   int64       `bson:"version,omitempty" json:"version"`         // Bumped on every content save; saves check it with If-Match
+	Revision  int64       `bson:"revision,omitempty" json:"revision"`       // Bumped on every change to the room; part of its ETag
+	ActiveAt  time.Time   `bson:"active_at,omitempty" json:"activeAt,omitempty"` // Creation, the last content save or websocket edit
+
+	// Bytes of the content, and the bytes it takes at rest. Recorded on
+	// save so content can be streamed without reading it first.
+	ContentSize int64 `bson:"content_size,omitempty" json:"contentSize,omitempty"`
+	StoredSize  int64 `bson:"stored_size,omitempty" json:"storedSize,omitempty"`
+
+	// Where content too large for one Mongo document is kept; never sent
+	// to clients, who always get the content whole
+	ContentID string `bson:"content_id,omitempty" json:"-"`
+	Chunks    int    `bson:"chunks,omitempty" json:"-"`
+
+	ForkedFrom string `bson:"forked_from,omitempty" json:"forkedFrom,omitempty"` // ID of the room this one was copied from
+
+	// Owner email for expiry warnings; never sent to clients
+	Contact string `bson:"contact,omitempty" json:"-"`
+	// The expire_at a warning was last sent for, so each expiry warns once
+	WarnedFor time.Time `bson:"warned_for,omitempty" json:"-"`
+
+	// Burn after reading: the content is destroyed once the room has been
+	// opened BurnAfterReads times, or when its first viewer disconnects
+	BurnAfterReads   int       `bson:"burn_after_reads,omitempty" json:"burnAfterReads,omitempty"`
+	BurnOnDisconnect bool      `bson:"burn_on_disconnect,omitempty" json:"burnOnDisconnect,omitempty"`
+	Reads            int       `bson:"reads,omitempty" json:"reads,omitempty"`
+	BurnedAt         time.Time `bson:"burned_at,omitempty" json:"burnedAt,omitempty"` // Set once the room is a tombstone
+}
+
+// Burned reports whether the room has self-destructed. Only a tombstone is
+// left, holding the slug so later visitors learn it was already read.
+func (r *Room) Burned() bool {
+	return !r.BurnedAt.IsZero()
+}
+
+// Burns reports whether the room is in burn-after-reading mode
+func (r *Room) Burns() bool {
+	return r.BurnAfterReads > 0 || r.BurnOnDisconnect
+}
+
+// Expired reports whether the room is past its expiry. Pinned rooms have no
+// expiry and never expire.
+func (r *Room) Expired(now time.Time) bool {
+	return !r.ExpireAt.IsZero() && !r.ExpireAt.After(now)
 }
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Template is a document new rooms can start from. Built-in templates ship
+// with the server; users save their own from a room.
+type Template struct {
+	ID          string    `bson:"_id" json:"id"`
+	Name        string    `bson:"name" json:"name"`
+	Description string    `bson:"description,omitempty" json:"description,omitempty"`
+	Owner       string    `bson:"owner,omitempty" json:"owner,omitempty"` // Empty for built-ins
+	BuiltIn     bool      `bson:"-" json:"builtIn,omitempty"`
+	Doc         DocNode   `bson:"doc" json:"doc"` // May contain placeholders like {{date}}
+	CreatedAt   time.Time `bson:"created_at" json:"createdAt"`
+}
+
+// DocNode is a node of an editor document in ProseMirror's JSON form, as
+// returned by the editor's getJSON()
+type DocNode struct {
+	Type    string                 `bson:"type" json:"type"`
+	Attrs   map[string]interface{} `bson:"attrs,omitempty" json:"attrs,omitempty"`
+	Content []DocNode              `bson:"content,omitempty" json:"content,omitempty"`
+	Text    string                 `bson:"text,omitempty" json:"text,omitempty"`
+	Marks   []DocMark              `bson:"marks,omitempty" json:"marks,omitempty"`
+}
+
+type DocMark struct {
+	Type  string                 `bson:"type" json:"type"`
+	Attrs map[string]interface{} `bson:"attrs,omitempty" json:"attrs,omitempty"`
+}
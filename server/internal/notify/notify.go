@@ -0,0 +1,168 @@
+// Package notify delivers warnings about rooms that are about to expire to
+// places outside the websocket: a webhook and the owner's email.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pranavdhawale/notex/server/internal/config"
+)
+
+// Warning describes a room about to expire
+type Warning struct {
+	RoomID    string    `json:"roomId"`
+	Slug      string    `json:"slug"`
+	ExpireAt  time.Time `json:"expireAt"`
+	Contact   string    `json:"contact,omitempty"` // Owner email, if they left one
+	RoomURL   string    `json:"roomUrl"`
+	ExtendURL string    `json:"extendUrl"` // Link to a page that pushes the expiry back
+}
+
+// Sender delivers a warning somewhere
+type Sender interface {
+	Send(ctx context.Context, w Warning) error
+}
+
+// Senders returns the senders enabled in cfg
+func Senders(cfg config.NotifyConfig) []Sender {
+	var senders []Sender
+	if cfg.Webhook != "" {
+		senders = append(senders, NewWebhook(cfg.Webhook))
+	}
+	if cfg.SMTP.Host != "" {
+		senders = append(senders, NewMailer(cfg.SMTP))
+	}
+	return senders
+}
+
+// Webhook POSTs every warning as JSON
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhook(url string) *Webhook {
+	return &Webhook{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (wh *Webhook) Send(ctx context.Context, w Warning) error {
+	body, err := json.Marshal(w)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := wh.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+// Mailer emails warnings to owners who left a contact address
+type Mailer struct {
+	host    string
+	addr    string
+	auth    smtp.Auth
+	from    string
+	timeout time.Duration // Bounds a send whose context has no deadline
+}
+
+func NewMailer(cfg config.SMTPConfig) *Mailer {
+	m := &Mailer{host: cfg.Host, addr: cfg.Host + ":" + strconv.Itoa(cfg.Port), from: cfg.From, timeout: 10 * time.Second}
+	if cfg.Username != "" {
+		m.auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	return m
+}
+
+// Send delivers w like smtp.SendMail, upgrading to TLS when the server
+// offers it, but gives up when ctx is done: a server that stops
+// responding fails the send rather than hanging it.
+func (m *Mailer) Send(ctx context.Context, w Warning) error {
+	if w.Contact == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	// Cancelling ctx before its deadline interrupts the exchange too
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return err
+		}
+	}
+	if m.auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := c.Auth(m.auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(m.from); err != nil {
+		return err
+	}
+	if err := c.Rcpt(w.Contact); err != nil {
+		return err
+	}
+	wc, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := wc.Write(m.message(w)); err != nil {
+		return err
+	}
+	if err := wc.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func (m *Mailer) message(w Warning) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", m.from)
+	fmt.Fprintf(&b, "To: %s\r\n", w.Contact)
+	fmt.Fprintf(&b, "Subject: Your notex room %s is about to expire\r\n", w.Slug)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&b, "Your room %s expires at %s and will be deleted with everything in it.\r\n\r\n", w.Slug, w.ExpireAt.UTC().Format(time.RFC1123))
+	fmt.Fprintf(&b, "Open it: %s\r\n", w.RoomURL)
+	fmt.Fprintf(&b, "Keep it longer: %s\r\n", w.ExtendURL)
+	return []byte(b.String())
+}
@@ -0,0 +1,170 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pranavdhawale/notex/server/internal/config"
+)
+
+var testWarning = Warning{
+	RoomID:    "room-1",
+	Slug:      "cosmic-whale",
+	ExpireAt:  time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC),
+	Contact:   "owner@example.com",
+	RoomURL:   "https://notex.example.com/cosmic-whale",
+	ExtendURL: "https://notex.example.com/api/rooms/cosmic-whale/extend?token=abc",
+}
+
+func TestWebhook(t *testing.T) {
+	var got Warning
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	if err := NewWebhook(srv.URL).Send(context.Background(), testWarning); err != nil {
+		t.Fatal(err)
+	}
+	if got != testWarning {
+		t.Errorf("webhook got %+v", got)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	if err := NewWebhook(failing.URL).Send(context.Background(), testWarning); err == nil {
+		t.Error("expected error for a failing webhook")
+	}
+}
+
+// smtpSink accepts mail on a local port and hands over each message body
+func smtpSink(t *testing.T) (int, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	messages := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+		reply("220 sink ready")
+		var data strings.Builder
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					messages <- data.String()
+					reply("250 queued")
+					continue
+				}
+				data.WriteString(line)
+				continue
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 sink")
+			case cmd == "DATA":
+				inData = true
+				reply("354 go ahead")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+
+	return ln.Addr().(*net.TCPAddr).Port, messages
+}
+
+func TestMailer(t *testing.T) {
+	port, messages := smtpSink(t)
+	m := NewMailer(config.SMTPConfig{Host: "127.0.0.1", Port: port, From: "notex@example.com"})
+
+	// Nothing to do without a contact address
+	noContact := testWarning
+	noContact.Contact = ""
+	if err := m.Send(context.Background(), noContact); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Send(context.Background(), testWarning); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-messages:
+		for _, want := range []string{"To: owner@example.com", "Subject: Your notex room cosmic-whale", testWarning.ExtendURL} {
+			if !strings.Contains(msg, want) {
+				t.Errorf("message missing %q:\n%s", want, msg)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message reached the sink")
+	}
+}
+
+func TestMailerGivesUpOnSilentServer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		// Accept and never greet
+		conn, err := ln.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	m := NewMailer(config.SMTPConfig{Host: "127.0.0.1", Port: ln.Addr().(*net.TCPAddr).Port, From: "notex@example.com"})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := m.Send(ctx, testWarning); err == nil {
+		t.Fatal("expected the send to fail")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the send to give up with its context, took %v", elapsed)
+	}
+}
+
+func TestSenders(t *testing.T) {
+	cfg := config.Default().Notify
+	if n := len(Senders(cfg)); n != 0 {
+		t.Errorf("expected no senders by default, got %d", n)
+	}
+	cfg.Webhook = "https://hooks.example.com"
+	cfg.SMTP = config.SMTPConfig{Host: "localhost", Port: 25, From: "notex@example.com"}
+	if n := len(Senders(cfg)); n != 2 {
+		t.Errorf("expected 2 senders, got %d", n)
+	}
+}
@@ -0,0 +1,157 @@
+// Package search keeps a full-text index of rooms: the plain text of their
+// content and the names of their files.
+package search
+
+import (
+	"errors"
+	"log"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/v2/analysis/lang/en"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/search/query"
+	"github.com/pranavdhawale/notex/server/internal/models"
+)
+
+// Index is an embedded bleve index with one document per room
+type Index struct {
+	idx bleve.Index
+}
+
+// filledKey marks an index that holds every room, see Filled
+var filledKey = []byte("filled")
+
+// roomDoc is what is indexed of a room
+type roomDoc struct {
+	Slug  string   `json:"slug"`
+	Owner string   `json:"owner"`
+	Text  string   `json:"text"`
+	Files []string `json:"files"`
+}
+
+// Hit is a room matching a search
+type Hit struct {
+	RoomID   string   `json:"roomId"`
+	Slug     string   `json:"slug"`
+	Score    float64  `json:"score"`
+	Snippets []string `json:"snippets,omitempty"` // Matching passages, HTML-escaped with matches in <mark>
+	Files    []string `json:"files,omitempty"`    // Matching file names, marked up the same way
+}
+
+// Open opens the index in dir, creating it if needed. An empty dir keeps
+// the index in memory.
+func Open(dir string) (*Index, error) {
+	if dir == "" {
+		idx, err := bleve.NewMemOnly(newMapping())
+		return &Index{idx: idx}, err
+	}
+
+	idx, err := bleve.Open(dir)
+	if errors.Is(err, bleve.ErrorIndexPathDoesNotExist) {
+		idx, err = bleve.New(dir, newMapping())
+		if err == nil {
+			log.Printf("Created search index %s", dir)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Index{idx: idx}, nil
+}
+
+func newMapping() mapping.IndexMapping {
+	text := bleve.NewTextFieldMapping()
+	text.Analyzer = en.AnalyzerName
+	text.IncludeTermVectors = true // For highlighting
+
+	exact := bleve.NewTextFieldMapping()
+	exact.Analyzer = keyword.Name
+	exact.IncludeInAll = false
+
+	room := bleve.NewDocumentStaticMapping()
+	room.AddFieldMappingsAt("text", text)
+	room.AddFieldMappingsAt("files", text)
+	room.AddFieldMappingsAt("slug", exact)
+	room.AddFieldMappingsAt("owner", exact)
+
+	m := bleve.NewIndexMapping()
+	m.DefaultMapping = room
+	return m
+}
+
+// Put indexes a room, replacing what was indexed of it before
+func (i *Index) Put(room *models.Room, text string, files []string) error {
+	return i.idx.Index(room.ID, roomDoc{Slug: room.Slug, Owner: room.Owner, Text: text, Files: files})
+}
+
+// Delete removes a room from the index
+func (i *Index) Delete(roomID string) error {
+	return i.idx.Delete(roomID)
+}
+
+// Search finds up to limit rooms matching every word of q, among the rooms
+// of owner and the given room IDs, best matches first
+func (i *Index) Search(q, owner string, roomIDs []string, limit int) ([]Hit, error) {
+	words := make([]query.Query, 0, 2)
+	for _, field := range []string{"text", "files"} {
+		match := bleve.NewMatchQuery(q)
+		match.SetField(field)
+		match.SetOperator(query.MatchQueryOperatorAnd)
+		words = append(words, match)
+	}
+
+	scopes := make([]query.Query, 0, 2)
+	if owner != "" {
+		ownerQuery := bleve.NewTermQuery(owner)
+		ownerQuery.SetField("owner")
+		scopes = append(scopes, ownerQuery)
+	}
+	if len(roomIDs) > 0 {
+		scopes = append(scopes, bleve.NewDocIDQuery(roomIDs))
+	}
+	if len(scopes) == 0 {
+		return []Hit{}, nil
+	}
+
+	req := bleve.NewSearchRequestOptions(
+		bleve.NewConjunctionQuery(bleve.NewDisjunctionQuery(words...), bleve.NewDisjunctionQuery(scopes...)),
+		limit, 0, false)
+	req.Fields = []string{"slug"}
+	req.Highlight = bleve.NewHighlightWithStyle("html")
+	req.Highlight.AddField("text")
+	req.Highlight.AddField("files")
+
+	result, err := i.idx.Search(req)
+	if err != nil {
+		return nil, err
+	}
+	hits := make([]Hit, 0, len(result.Hits))
+	for _, match := range result.Hits {
+		slug, _ := match.Fields["slug"].(string)
+		hits = append(hits, Hit{
+			RoomID:   match.ID,
+			Slug:     slug,
+			Score:    match.Score,
+			Snippets: match.Fragments["text"],
+			Files:    match.Fragments["files"],
+		})
+	}
+	return hits, nil
+}
+
+// Filled reports whether every room has been indexed. A new index starts
+// empty; whoever fills it calls MarkFilled once done, so an interrupted
+// fill is picked up again on the next start.
+func (i *Index) Filled() bool {
+	v, err := i.idx.GetInternal(filledKey)
+	return err == nil && v != nil
+}
+
+func (i *Index) MarkFilled() error {
+	return i.idx.SetInternal(filledKey, []byte{1})
+}
+
+func (i *Index) Close() error {
+	return i.idx.Close()
+}
@@ -0,0 +1,54 @@
+package search
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pranavdhawale/notex/server/internal/models"
+)
+
+func TestSearchScopesAndHighlights(t *testing.T) {
+	idx, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+
+	idx.Put(&models.Room{ID: "r1", Slug: "plans", Owner: "alice"}, "Roadmap\nWe ship the <b>search</b> feature in March", nil)
+	idx.Put(&models.Room{ID: "r2", Slug: "shared", Owner: "bob"}, "Search ideas from bob", []string{"search-notes.pdf"})
+	idx.Put(&models.Room{ID: "r3", Slug: "private", Owner: "carol"}, "Carol's search secrets", nil)
+
+	hits, err := idx.Search("search", "alice", []string{"r2"}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]Hit{}
+	for _, hit := range hits {
+		found[hit.Slug] = hit
+	}
+	if len(found) != 2 || found["plans"].RoomID != "r1" || found["shared"].RoomID != "r2" {
+		t.Fatalf("expected alice's room and the shared one, got %+v", hits)
+	}
+	if snippets := found["plans"].Snippets; len(snippets) == 0 || !strings.Contains(snippets[0], "&lt;b&gt;<mark>search</mark>&lt;/b&gt;") {
+		t.Errorf("expected an escaped, highlighted snippet, got %q", snippets)
+	}
+	if files := found["shared"].Files; len(files) != 1 || !strings.Contains(files[0], "<mark>search</mark>") {
+		t.Errorf("expected the matching file name, got %q", files)
+	}
+
+	// Every word has to match, stemmed
+	if hits, _ := idx.Search("shipping march", "alice", nil, 10); len(hits) != 1 {
+		t.Errorf("expected a stemmed match, got %+v", hits)
+	}
+	if hits, _ := idx.Search("search march", "", []string{"r2"}, 10); len(hits) != 0 {
+		t.Errorf("expected no match without every word, got %+v", hits)
+	}
+
+	idx.Delete("r1")
+	if hits, _ := idx.Search("roadmap", "alice", nil, 10); len(hits) != 0 {
+		t.Errorf("expected deleted room to be gone, got %+v", hits)
+	}
+	if hits, _ := idx.Search("search", "", nil, 10); len(hits) != 0 {
+		t.Errorf("expected nothing without a scope, got %+v", hits)
+	}
+}
@@ -0,0 +1,954 @@
+package state
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/pranavdhawale/notex/server/internal/models"
+	"go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var (
+	roomsBucket      = []byte("rooms")        // room ID -> BSON room
+	slugsBucket      = []byte("slugs")        // current slug -> room ID
+	aliasesBucket    = []byte("room_aliases") // old slug -> room ID
+	filesBucket      = []byte("files")        // file ID -> BSON file
+	roomFilesBucket  = []byte("room_files")   // room ID -> bucket of file IDs
+	templatesBucket  = []byte("templates")    // template ID -> BSON template
+	ownerRoomsBucket = []byte("owner_rooms")  // owner -> bucket of room IDs
+)
+
+// BoltDB is an embedded single-file database for deployments without Mongo.
+// Records are stored as BSON so the models keep a single set of tags.
+type BoltDB struct {
+	db    *bbolt.DB
+	codec Codec // Compression of saved room content
+
+	// Clock, replaceable in tests
+	now func() time.Time
+}
+
+// OpenBolt opens (or creates) the database file at path. Room content is
+// compressed with codec as it is saved.
+func OpenBolt(path string, codec Codec) (*BoltDB, error) {
+	db, err := bbolt.Open(path, 0o600, &bbolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		indexOwners := tx.Bucket(ownerRoomsBucket) == nil
+		for _, name := range [][]byte{roomsBucket, slugsBucket, aliasesBucket, filesBucket, roomFilesBucket, templatesBucket, ownerRoomsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		if err := migrateBoltContent(tx); err != nil {
+			return err
+		}
+		if err := migrateBolt(tx); err != nil {
+			return err
+		}
+		if err := migrateBoltActivity(tx); err != nil {
+			return err
+		}
+		if indexOwners {
+			return migrateBoltOwners(tx)
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	log.Printf("Opened embedded database %s", path)
+	return &BoltDB{db: db, codec: codec, now: time.Now}, nil
+}
+
+// migrateBolt re-keys rooms stored by slug, from before rooms had IDs,
+// along with their files
+func migrateBolt(tx *bbolt.Tx) error {
+	rooms := tx.Bucket(roomsBucket)
+
+	var legacy []models.Room
+	err := rooms.ForEach(func(k, v []byte) error {
+		var room models.Room
+		if err := bson.Unmarshal(v, &room); err != nil {
+			return err
+		}
+		if room.ID == "" {
+			legacy = append(legacy, room)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, room := range legacy {
+		room.ID = newRoomID()
+		if err := rooms.Delete([]byte(room.Slug)); err != nil {
+			return err
+		}
+		if err := putRoom(tx, &room); err != nil {
+			return err
+		}
+		if err := tx.Bucket(slugsBucket).Put([]byte(room.Slug), []byte(room.ID)); err != nil {
+			return err
+		}
+		if err := moveRoomFiles(tx, room.Slug, room.ID); err != nil {
+			return err
+		}
+	}
+
+	if len(legacy) > 0 {
+		log.Printf("Migrated %d rooms to room IDs", len(legacy))
+	}
+	return nil
+}
+
+// migrateBoltActivity sets the last activity of rooms created before
+// activity was recorded to their creation time
+func migrateBoltActivity(tx *bbolt.Tx) error {
+	var stale []models.Room
+	err := tx.Bucket(roomsBucket).ForEach(func(k, v []byte) error {
+		var room models.Room
+		if err := bson.Unmarshal(v, &room); err != nil {
+			return err
+		}
+		if room.ActiveAt.IsZero() && !room.CreatedAt.IsZero() && room.ID != "" {
+			stale = append(stale, room)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := range stale {
+		stale[i].ActiveAt = stale[i].CreatedAt
+		if err := putRoom(tx, &stale[i]); err != nil {
+			return err
+		}
+	}
+	if len(stale) > 0 {
+		log.Printf("Backfilled activity of %d rooms", len(stale))
+	}
+	return nil
+}
+
+// migrateBoltOwners indexes the rooms stored before rooms were indexed by owner
+func migrateBoltOwners(tx *bbolt.Tx) error {
+	indexed := 0
+	err := tx.Bucket(roomsBucket).ForEach(func(k, v []byte) error {
+		var room models.Room
+		if err := bson.Unmarshal(v, &room); err != nil {
+			return err
+		}
+		if room.Owner == "" {
+			return nil
+		}
+		indexed++
+		return indexRoomOwner(tx, &room)
+	})
+	if err != nil {
+		return err
+	}
+	if indexed > 0 {
+		log.Printf("Indexed %d rooms by owner", indexed)
+	}
+	return nil
+}
+
+// migrateBoltContent converts content stored as a base64 string to binary.
+// It runs before anything decodes rooms, which fails on string content.
+func migrateBoltContent(tx *bbolt.Tx) error {
+	rooms := tx.Bucket(roomsBucket)
+
+	migrated := map[string][]byte{}
+	err := rooms.ForEach(func(k, v []byte) error {
+		content, err := bson.Raw(v).LookupErr("content")
+		if err != nil || content.Type == bson.TypeBinary {
+			return nil
+		}
+
+		var doc bson.D
+		if err := bson.Unmarshal(v, &doc); err != nil {
+			return err
+		}
+		for i, e := range doc {
+			if e.Key != "content" {
+				continue
+			}
+			if data, ok := legacyContent(content); ok {
+				doc[i].Value = data
+			} else {
+				doc[i].Key = "legacy_content"
+			}
+		}
+		data, err := bson.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		migrated[string(k)] = data
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for k, data := range migrated {
+		if err := rooms.Put([]byte(k), data); err != nil {
+			return err
+		}
+	}
+	if len(migrated) > 0 {
+		log.Printf("Migrated content of %d rooms to binary", len(migrated))
+	}
+	return nil
+}
+
+// moveRoomFiles re-keys the files of a room
+func moveRoomFiles(tx *bbolt.Tx, from, to string) error {
+	index := tx.Bucket(roomFilesBucket).Bucket([]byte(from))
+	if index == nil {
+		return nil
+	}
+
+	var ids [][]byte
+	err := index.ForEach(func(k, _ []byte) error {
+		ids = append(ids, k)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		file, err := getFile(tx, from, string(id))
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		file.RoomID = to
+		if err := putFile(tx, file); err != nil {
+			return err
+		}
+		if err := indexFile(tx, file); err != nil {
+			return err
+		}
+	}
+	return tx.Bucket(roomFilesBucket).DeleteBucket([]byte(from))
+}
+
+func (b *BoltDB) Close() error {
+	return b.db.Close()
+}
+
+// SweepExpired deletes expired rooms every interval until ctx is done,
+// standing in for Mongo's TTL index.
+func (b *BoltDB) SweepExpired(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := b.sweep()
+			if err != nil {
+				log.Printf("Failed to sweep expired rooms: %v", err)
+			} else if n > 0 {
+				log.Printf("Swept %d expired rooms", n)
+			}
+		}
+	}
+}
+
+func (b *BoltDB) sweep() (int, error) {
+	swept := 0
+	err := b.db.Update(func(tx *bbolt.Tx) error {
+		var expired []models.Room
+		err := tx.Bucket(roomsBucket).ForEach(func(k, v []byte) error {
+			var room models.Room
+			if err := bson.Unmarshal(v, &room); err != nil {
+				return err
+			}
+			if b.expired(&room) {
+				expired = append(expired, room)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for i := range expired {
+			if err := deleteRoom(tx, &expired[i]); err != nil {
+				return err
+			}
+		}
+		swept = len(expired)
+		return nil
+	})
+	return swept, err
+}
+
+func (b *BoltDB) expired(room *models.Room) bool {
+	return room.Expired(b.now())
+}
+
+// Rooms returns the room store backed by this database
+func (b *BoltDB) Rooms() *BoltRoomStore {
+	return &BoltRoomStore{b: b}
+}
+
+// Files returns the file store backed by this database
+func (b *BoltDB) Files() *BoltFileStore {
+	return &BoltFileStore{b: b}
+}
+
+// Templates returns the template store backed by this database
+func (b *BoltDB) Templates() *BoltTemplateStore {
+	return &BoltTemplateStore{b: b}
+}
+
+// BoltRoomStore stores rooms keyed by ID, with indexes from current and
+// old slugs. Expired rooms are invisible even before the sweeper removes them.
+type BoltRoomStore struct {
+	b *BoltDB
+}
+
+// getRoom loads a live room inside a transaction
+func (s *BoltRoomStore) getRoom(tx *bbolt.Tx, id string) (*models.Room, error) {
+	data := tx.Bucket(roomsBucket).Get([]byte(id))
+	if data == nil {
+		return nil, ErrNotFound
+	}
+	var room models.Room
+	if err := bson.Unmarshal(data, &room); err != nil {
+		return nil, err
+	}
+	if s.b.expired(&room) {
+		return nil, ErrNotFound
+	}
+	return &room, nil
+}
+
+// resolve loads a live room by current slug or alias inside a transaction
+func (s *BoltRoomStore) resolve(tx *bbolt.Tx, slug string) (*models.Room, bool, error) {
+	if id := tx.Bucket(slugsBucket).Get([]byte(slug)); id != nil {
+		room, err := s.getRoom(tx, string(id))
+		if err != ErrNotFound {
+			return room, false, err
+		}
+	}
+	if id := tx.Bucket(aliasesBucket).Get([]byte(slug)); id != nil {
+		room, err := s.getRoom(tx, string(id))
+		if err != ErrNotFound {
+			return room, true, err
+		}
+	}
+	return nil, false, ErrNotFound
+}
+
+func putRoom(tx *bbolt.Tx, room *models.Room) error {
+	room.Revision++ // Every write is a new revision
+	data, err := bson.Marshal(room)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(roomsBucket).Put([]byte(room.ID), data)
+}
+
+// indexRoomOwner adds a room to its owner's index. Rooms without an owner
+// are not indexed.
+func indexRoomOwner(tx *bbolt.Tx, room *models.Room) error {
+	if room.Owner == "" {
+		return nil
+	}
+	index, err := tx.Bucket(ownerRoomsBucket).CreateBucketIfNotExists([]byte(room.Owner))
+	if err != nil {
+		return err
+	}
+	return index.Put([]byte(room.ID), nil)
+}
+
+// deleteRoom removes a room with its slug, aliases and owner index entry, leaving slug
+// entries that were since taken over by another room alone
+func deleteRoom(tx *bbolt.Tx, room *models.Room) error {
+	if err := tx.Bucket(roomsBucket).Delete([]byte(room.ID)); err != nil {
+		return err
+	}
+	if index := tx.Bucket(ownerRoomsBucket).Bucket([]byte(room.Owner)); room.Owner != "" && index != nil {
+		if err := index.Delete([]byte(room.ID)); err != nil {
+			return err
+		}
+	}
+
+	slugs := tx.Bucket(slugsBucket)
+	if string(slugs.Get([]byte(room.Slug))) == room.ID {
+		if err := slugs.Delete([]byte(room.Slug)); err != nil {
+			return err
+		}
+	}
+
+	aliases := tx.Bucket(aliasesBucket)
+	var stale [][]byte
+	err := aliases.ForEach(func(k, v []byte) error {
+		if string(v) == room.ID {
+			stale = append(stale, k)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range stale {
+		if err := aliases.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateRoom applies fn to a live room and writes it back
+func (s *BoltRoomStore) updateRoom(id string, fn func(*models.Room)) error {
+	return s.b.db.Update(func(tx *bbolt.Tx) error {
+		room, err := s.getRoom(tx, id)
+		if err != nil {
+			return err
+		}
+		fn(room)
+		return putRoom(tx, room)
+	})
+}
+
+func (s *BoltRoomStore) Create(ctx context.Context, room *models.Room) error {
+	return s.b.db.Update(func(tx *bbolt.Tx) error {
+		if _, _, err := s.resolve(tx, room.Slug); err == nil {
+			return ErrSlugTaken
+		} else if err != ErrNotFound {
+			return err
+		}
+
+		if room.ID == "" {
+			room.ID = newRoomID()
+		}
+		// A leftover alias of an expired room no longer holds the slug
+		if err := tx.Bucket(aliasesBucket).Delete([]byte(room.Slug)); err != nil {
+			return err
+		}
+		if err := tx.Bucket(slugsBucket).Put([]byte(room.Slug), []byte(room.ID)); err != nil {
+			return err
+		}
+		if err := indexRoomOwner(tx, room); err != nil {
+			return err
+		}
+		stored := *room
+		packContent(&stored, room.Content, s.b.codec)
+		return putRoom(tx, &stored)
+	})
+}
+
+func (s *BoltRoomStore) Get(ctx context.Context, id string) (*models.Room, error) {
+	var room *models.Room
+	err := s.b.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		if room, err = s.getRoom(tx, id); err != nil {
+			return err
+		}
+		return unpackContent(room)
+	})
+	return room, err
+}
+
+func (s *BoltRoomStore) Resolve(ctx context.Context, slug string) (*models.Room, bool, error) {
+	var room *models.Room
+	var aliased bool
+	err := s.b.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		if room, aliased, err = s.resolve(tx, slug); err != nil {
+			return err
+		}
+		return unpackContent(room)
+	})
+	return room, aliased, err
+}
+
+func (s *BoltRoomStore) ResolveContent(ctx context.Context, slug string) (*models.Room, bool, *Content, error) {
+	var room *models.Room
+	var aliased bool
+	var content *Content
+	err := s.b.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		if room, aliased, err = s.resolve(tx, slug); err != nil {
+			return err
+		}
+		content = splitContent(room)
+		return nil
+	})
+	return room, aliased, content, err
+}
+
+// Exists looks up the slug and alias buckets, decoding only the expiry of
+// the room they point to
+func (s *BoltRoomStore) Exists(ctx context.Context, slug string) (bool, error) {
+	exists := false
+	err := s.b.db.View(func(tx *bbolt.Tx) error {
+		for _, bucket := range [][]byte{slugsBucket, aliasesBucket} {
+			id := tx.Bucket(bucket).Get([]byte(slug))
+			if id == nil {
+				continue
+			}
+			data := tx.Bucket(roomsBucket).Get(id)
+			if data == nil {
+				continue
+			}
+			var expiry struct {
+				ExpireAt time.Time `bson:"expire_at"`
+			}
+			if err := bson.Unmarshal(data, &expiry); err != nil {
+				return err
+			}
+			if !s.b.expired(&models.Room{ExpireAt: expiry.ExpireAt}) {
+				exists = true
+				return nil
+			}
+		}
+		return nil
+	})
+	return exists, err
+}
+
+func (s *BoltRoomStore) SaveContent(ctx context.Context, id string, content []byte, expireAt time.Time, version int64) (int64, error) {
+	var saved int64
+	err := s.b.db.Update(func(tx *bbolt.Tx) error {
+		room, err := s.getRoom(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := saveContent(room, content, s.b.now(), expireAt, version, s.b.codec); err != nil {
+			return err
+		}
+		saved = room.Version
+		return putRoom(tx, room)
+	})
+	return saved, err
+}
+
+func (s *BoltRoomStore) SetExpiry(ctx context.Context, id string, expireAt time.Time) error {
+	return s.updateRoom(id, func(room *models.Room) {
+		room.ExpireAt = expireAt
+	})
+}
+
+func (s *BoltRoomStore) Update(ctx context.Context, id string, update RoomUpdate) (*models.Room, error) {
+	var updated *models.Room
+	err := s.updateRoom(id, func(room *models.Room) {
+		update.apply(room)
+		updated = room
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, unpackContent(updated)
+}
+
+func (s *BoltRoomStore) RecordRead(ctx context.Context, id string) (*models.Room, error) {
+	var read *models.Room
+	err := s.b.db.Update(func(tx *bbolt.Tx) error {
+		room, err := s.getRoom(tx, id)
+		if err != nil {
+			return err
+		}
+		if room.Burned() {
+			return ErrBurned
+		}
+		room.Reads++
+		read = room
+		return putRoom(tx, room)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return read, unpackContent(read)
+}
+
+func (s *BoltRoomStore) Burn(ctx context.Context, id string, expireAt time.Time) error {
+	return s.b.db.Update(func(tx *bbolt.Tx) error {
+		room, err := s.getRoom(tx, id)
+		if err != nil {
+			return err
+		}
+		if room.Burned() {
+			return ErrBurned
+		}
+		burn(room, s.b.now(), expireAt)
+		return putRoom(tx, room)
+	})
+}
+
+func (s *BoltRoomStore) Expiring(ctx context.Context, before time.Time) ([]models.Room, error) {
+	rooms := []models.Room{}
+	err := s.b.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(roomsBucket).ForEach(func(k, v []byte) error {
+			var room models.Room
+			if err := bson.Unmarshal(v, &room); err != nil {
+				return err
+			}
+			if !s.b.expired(&room) && expiring(&room, before) {
+				if err := unpackContent(&room); err != nil {
+					return err
+				}
+				rooms = append(rooms, room)
+			}
+			return nil
+		})
+	})
+	return rooms, err
+}
+
+func (s *BoltRoomStore) ClaimWarning(ctx context.Context, id string, expireAt time.Time) (bool, error) {
+	claimed := false
+	err := s.b.db.Update(func(tx *bbolt.Tx) error {
+		room, err := s.getRoom(tx, id)
+		if err == ErrNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !room.ExpireAt.Equal(expireAt) || room.WarnedFor.Equal(expireAt) {
+			return nil
+		}
+		room.WarnedFor = expireAt
+		claimed = true
+		return putRoom(tx, room)
+	})
+	return claimed, err
+}
+
+func (s *BoltRoomStore) Rename(ctx context.Context, id, slug string) error {
+	return s.b.db.Update(func(tx *bbolt.Tx) error {
+		room, err := s.getRoom(tx, id)
+		if err != nil {
+			return err
+		}
+		if room.Slug == slug {
+			return nil
+		}
+		if other, _, err := s.resolve(tx, slug); err == nil && other.ID != id {
+			return ErrSlugTaken
+		} else if err != nil && err != ErrNotFound {
+			return err
+		}
+
+		// Renaming back to an old slug turns that alias into the slug again
+		aliases, slugs := tx.Bucket(aliasesBucket), tx.Bucket(slugsBucket)
+		if err := aliases.Delete([]byte(slug)); err != nil {
+			return err
+		}
+		if err := slugs.Delete([]byte(room.Slug)); err != nil {
+			return err
+		}
+		if err := aliases.Put([]byte(room.Slug), []byte(id)); err != nil {
+			return err
+		}
+		if err := slugs.Put([]byte(slug), []byte(id)); err != nil {
+			return err
+		}
+		room.Slug = slug
+		return putRoom(tx, room)
+	})
+}
+
+func (s *BoltRoomStore) Delete(ctx context.Context, id string) error {
+	return s.b.db.Update(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(roomsBucket).Get([]byte(id))
+		if data == nil {
+			return nil
+		}
+		var room models.Room
+		if err := bson.Unmarshal(data, &room); err != nil {
+			return err
+		}
+		return deleteRoom(tx, &room)
+	})
+}
+
+func (s *BoltRoomStore) Touch(ctx context.Context, id string, at time.Time) error {
+	return s.updateRoom(id, func(room *models.Room) {
+		if at.After(room.ActiveAt) {
+			room.ActiveAt = at
+		}
+	})
+}
+
+func (s *BoltRoomStore) IDs(ctx context.Context) ([]string, error) {
+	ids := []string{}
+	err := s.b.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(roomsBucket).ForEach(func(k, v []byte) error {
+			var room models.Room
+			if err := bson.Unmarshal(v, &room); err != nil {
+				return err
+			}
+			if !s.b.expired(&room) {
+				ids = append(ids, room.ID)
+			}
+			return nil
+		})
+	})
+	return ids, err
+}
+
+func (s *BoltRoomStore) Live(ctx context.Context, ids []string) (map[string]bool, error) {
+	alive := make(map[string]bool, len(ids))
+	err := s.b.db.View(func(tx *bbolt.Tx) error {
+		for _, id := range ids {
+			_, err := s.getRoom(tx, id)
+			if err == ErrNotFound {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			alive[id] = true
+		}
+		return nil
+	})
+	return alive, err
+}
+
+func (s *BoltRoomStore) ListByOwner(ctx context.Context, owner string, opts ListRoomsOptions) ([]models.Room, int64, error) {
+	rooms := []models.Room{}
+	err := s.b.db.View(func(tx *bbolt.Tx) error {
+		index := tx.Bucket(ownerRoomsBucket).Bucket([]byte(owner))
+		if owner == "" || index == nil {
+			return nil
+		}
+		return index.ForEach(func(k, _ []byte) error {
+			room, err := s.getRoom(tx, string(k))
+			if err == ErrNotFound {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			room.Content = nil
+			rooms = append(rooms, *room)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rooms, total := sortAndPageRooms(rooms, opts)
+	return rooms, total, nil
+}
+
+// BoltFileStore stores file metadata, indexed by room
+type BoltFileStore struct {
+	b *BoltDB
+}
+
+func getFile(tx *bbolt.Tx, roomID, fileID string) (*models.File, error) {
+	data := tx.Bucket(filesBucket).Get([]byte(fileID))
+	if data == nil {
+		return nil, ErrNotFound
+	}
+	var file models.File
+	if err := bson.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if file.RoomID != roomID {
+		return nil, ErrNotFound
+	}
+	return &file, nil
+}
+
+func putFile(tx *bbolt.Tx, file *models.File) error {
+	data, err := bson.Marshal(file)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(filesBucket).Put([]byte(file.ID), data)
+}
+
+// indexFile adds a file to its room's index
+func indexFile(tx *bbolt.Tx, file *models.File) error {
+	index, err := tx.Bucket(roomFilesBucket).CreateBucketIfNotExists([]byte(file.RoomID))
+	if err != nil {
+		return err
+	}
+	return index.Put([]byte(file.ID), nil)
+}
+
+func (s *BoltFileStore) Create(ctx context.Context, file *models.File) error {
+	return s.b.db.Update(func(tx *bbolt.Tx) error {
+		if err := putFile(tx, file); err != nil {
+			return err
+		}
+		return indexFile(tx, file)
+	})
+}
+
+func (s *BoltFileStore) Get(ctx context.Context, roomID, fileID string) (*models.File, error) {
+	var file *models.File
+	err := s.b.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		file, err = getFile(tx, roomID, fileID)
+		return err
+	})
+	return file, err
+}
+
+func (s *BoltFileStore) List(ctx context.Context, roomID string, opts ListFilesOptions) ([]models.File, int64, error) {
+	files := []models.File{}
+	err := s.b.db.View(func(tx *bbolt.Tx) error {
+		index := tx.Bucket(roomFilesBucket).Bucket([]byte(roomID))
+		if index == nil {
+			return nil
+		}
+		return index.ForEach(func(k, _ []byte) error {
+			file, err := getFile(tx, roomID, string(k))
+			if err == ErrNotFound {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			files = append(files, *file)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	files, total := sortAndPageFiles(files, opts)
+	return files, total, nil
+}
+
+func (s *BoltFileStore) Update(ctx context.Context, roomID, fileID string, update FileUpdate) (*models.File, error) {
+	var file *models.File
+	err := s.b.db.Update(func(tx *bbolt.Tx) error {
+		var err error
+		file, err = getFile(tx, roomID, fileID)
+		if err != nil {
+			return err
+		}
+		if update.Name != nil {
+			file.Name = *update.Name
+		}
+		if update.Description != nil {
+			file.Description = *update.Description
+		}
+		if update.Pinned != nil {
+			file.Pinned = *update.Pinned
+		}
+		return putFile(tx, file)
+	})
+	return file, err
+}
+
+func (s *BoltFileStore) Delete(ctx context.Context, roomID, fileID string) error {
+	return s.b.db.Update(func(tx *bbolt.Tx) error {
+		if _, err := getFile(tx, roomID, fileID); err == ErrNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := tx.Bucket(filesBucket).Delete([]byte(fileID)); err != nil {
+			return err
+		}
+		if index := tx.Bucket(roomFilesBucket).Bucket([]byte(roomID)); index != nil {
+			return index.Delete([]byte(fileID))
+		}
+		return nil
+	})
+}
+
+func (s *BoltFileStore) DeleteByRoom(ctx context.Context, roomID string) error {
+	return s.b.db.Update(func(tx *bbolt.Tx) error {
+		index := tx.Bucket(roomFilesBucket).Bucket([]byte(roomID))
+		if index == nil {
+			return nil
+		}
+		files := tx.Bucket(filesBucket)
+		err := index.ForEach(func(k, _ []byte) error {
+			return files.Delete(k)
+		})
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(roomFilesBucket).DeleteBucket([]byte(roomID))
+	})
+}
+
+// BoltTemplateStore stores user templates keyed by ID. Owners have few
+// templates, so listing scans the bucket instead of keeping an index.
+type BoltTemplateStore struct {
+	b *BoltDB
+}
+
+func (s *BoltTemplateStore) Create(ctx context.Context, t *models.Template) error {
+	if t.ID == "" {
+		t.ID = newRoomID()
+	}
+	data, err := bson.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return s.b.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(templatesBucket).Put([]byte(t.ID), data)
+	})
+}
+
+func (s *BoltTemplateStore) Get(ctx context.Context, id string) (*models.Template, error) {
+	var t models.Template
+	err := s.b.db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(templatesBucket).Get([]byte(id))
+		if data == nil {
+			return ErrNotFound
+		}
+		return bson.Unmarshal(data, &t)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (s *BoltTemplateStore) List(ctx context.Context, owner string) ([]models.Template, error) {
+	templates := []models.Template{}
+	err := s.b.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(templatesBucket).ForEach(func(_, data []byte) error {
+			var t models.Template
+			if err := bson.Unmarshal(data, &t); err != nil {
+				return err
+			}
+			if t.Owner == owner {
+				templates = append(templates, t)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortTemplates(templates)
+	return templates, nil
+}
+
+func (s *BoltTemplateStore) Delete(ctx context.Context, id string) error {
+	return s.b.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(templatesBucket).Delete([]byte(id))
+	})
+}
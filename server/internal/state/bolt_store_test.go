@@ -0,0 +1,399 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pranavdhawale/notex/server/internal/models"
+	"go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func openTestBolt(t *testing.T) *BoltDB {
+	t.Helper()
+	b, err := OpenBolt(filepath.Join(t.TempDir(), "notex.db"), CodecZstd)
+	if err != nil {
+		t.Fatalf("OpenBolt failed: %v", err)
+	}
+	t.Cleanup(func() { b.Close() })
+	return b
+}
+
+func TestBoltRoomStoreExpiryAndSweep(t *testing.T) {
+	ctx := context.Background()
+	b := openTestBolt(t)
+	now := time.Now()
+	b.now = func() time.Time { return now }
+	rooms := b.Rooms()
+
+	short := &models.Room{Slug: "short", Owner: "alice", ExpireAt: now.Add(time.Minute)}
+	rooms.Create(ctx, short)
+	rooms.Create(ctx, &models.Room{Slug: "long", ExpireAt: now.Add(time.Hour)})
+	if short.ID == "" {
+		t.Fatal("Create should assign an ID")
+	}
+
+	if err := rooms.Create(ctx, &models.Room{Slug: "short"}); !errors.Is(err, ErrSlugTaken) {
+		t.Errorf("expected ErrSlugTaken, got %v", err)
+	}
+
+	if v, err := rooms.SaveContent(ctx, short.ID, []byte("v1"), now.Add(2*time.Minute), 0); err != nil || v != 1 {
+		t.Fatalf("SaveContent failed: %v (version %d)", err, v)
+	}
+	if _, err := rooms.SaveContent(ctx, short.ID, []byte("v2"), now.Add(2*time.Minute), 0); !errors.Is(err, ErrVersionMismatch) {
+		t.Fatalf("expected ErrVersionMismatch for a stale save, got %v", err)
+	}
+	room, _, err := rooms.Resolve(ctx, "short")
+	if err != nil || room.Owner != "alice" || string(room.Content) != "v1" {
+		t.Fatalf("unexpected room %+v (err %v)", room, err)
+	}
+
+	now = now.Add(5 * time.Minute)
+
+	if _, err := rooms.Get(ctx, short.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected expired room to be hidden, got %v", err)
+	}
+	if ok, _ := rooms.Exists(ctx, "short"); ok {
+		t.Error("expired room should not exist")
+	}
+
+	swept, err := b.sweep()
+	if err != nil || swept != 1 {
+		t.Errorf("expected 1 swept room, got %d (err %v)", swept, err)
+	}
+	if ok, _ := rooms.Exists(ctx, "long"); !ok {
+		t.Error("live room should survive the sweep")
+	}
+}
+
+func TestBoltRoomStoreRename(t *testing.T) {
+	ctx := context.Background()
+	rooms := openTestBolt(t).Rooms()
+	expireAt := time.Now().Add(time.Hour)
+
+	room := &models.Room{Slug: "old-name", ExpireAt: expireAt}
+	rooms.Create(ctx, room)
+	rooms.Create(ctx, &models.Room{Slug: "taken", ExpireAt: expireAt})
+
+	if err := rooms.Rename(ctx, room.ID, "taken"); !errors.Is(err, ErrSlugTaken) {
+		t.Errorf("expected ErrSlugTaken, got %v", err)
+	}
+	before, _ := rooms.Get(ctx, room.ID)
+	if err := rooms.Rename(ctx, room.ID, "new-name"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+
+	got, aliased, err := rooms.Resolve(ctx, "old-name")
+	if err != nil || !aliased || got.ID != room.ID || got.Slug != "new-name" {
+		t.Errorf("expected old slug to resolve as alias, got %+v aliased=%v (err %v)", got, aliased, err)
+	}
+	if got != nil && got.Revision <= before.Revision {
+		t.Errorf("expected the rename to bump the revision past %d, got %d", before.Revision, got.Revision)
+	}
+	if ok, err := rooms.Exists(ctx, "old-name"); !ok || err != nil {
+		t.Errorf("alias should exist, got %v (err %v)", ok, err)
+	}
+	if err := rooms.Create(ctx, &models.Room{Slug: "old-name", ExpireAt: expireAt}); !errors.Is(err, ErrSlugTaken) {
+		t.Errorf("alias should hold the old slug, got %v", err)
+	}
+
+	// Renaming back reclaims the alias
+	if err := rooms.Rename(ctx, room.ID, "old-name"); err != nil {
+		t.Fatalf("Rename back failed: %v", err)
+	}
+	if _, aliased, _ := rooms.Resolve(ctx, "old-name"); aliased {
+		t.Error("old-name should be the current slug again")
+	}
+
+	rooms.Delete(ctx, room.ID)
+	for _, slug := range []string{"old-name", "new-name"} {
+		if ok, _ := rooms.Exists(ctx, slug); ok {
+			t.Errorf("%s should be free after delete", slug)
+		}
+	}
+}
+
+func TestBoltRoomStoreBurn(t *testing.T) {
+	ctx := context.Background()
+	rooms := openTestBolt(t).Rooms()
+
+	room := &models.Room{Slug: "secret", Content: []byte("secret"), BurnAfterReads: 2, ExpireAt: time.Now().Add(time.Hour)}
+	rooms.Create(ctx, room)
+
+	for want := 1; want <= 2; want++ {
+		read, err := rooms.RecordRead(ctx, room.ID)
+		if err != nil || read.Reads != want {
+			t.Fatalf("read %d: got %+v (err %v)", want, read, err)
+		}
+	}
+
+	if err := rooms.Burn(ctx, room.ID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Burn failed: %v", err)
+	}
+	if err := rooms.Burn(ctx, room.ID, time.Now().Add(time.Hour)); !errors.Is(err, ErrBurned) {
+		t.Errorf("second burn: expected ErrBurned, got %v", err)
+	}
+	if _, err := rooms.RecordRead(ctx, room.ID); !errors.Is(err, ErrBurned) {
+		t.Errorf("read after burn: expected ErrBurned, got %v", err)
+	}
+
+	// The tombstone keeps the slug but not the content
+	tomb, _, err := rooms.Resolve(ctx, "secret")
+	if err != nil || !tomb.Burned() || tomb.Content != nil {
+		t.Errorf("unexpected tombstone %+v (err %v)", tomb, err)
+	}
+}
+
+func TestBoltListByOwner(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "notex.db")
+	b, err := OpenBolt(path, CodecZstd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	rooms := b.Rooms()
+	rooms.Create(ctx, &models.Room{ID: "r1", Slug: "later", Owner: "alice", ExpireAt: now.Add(2 * time.Hour)})
+	rooms.Create(ctx, &models.Room{ID: "r2", Slug: "pinned", Owner: "alice", Pinned: true})
+	rooms.Create(ctx, &models.Room{ID: "r3", Slug: "sooner", Owner: "alice", ExpireAt: now.Add(time.Hour)})
+	rooms.Create(ctx, &models.Room{ID: "r4", Slug: "other", Owner: "bob", ExpireAt: now.Add(time.Hour)})
+	rooms.SaveContent(ctx, "r3", []byte("content"), now.Add(time.Hour), AnyVersion)
+	rooms.Delete(ctx, "r1")
+
+	ids := func(opts ListRoomsOptions) []string {
+		t.Helper()
+		list, total, err := b.Rooms().ListByOwner(ctx, "alice", opts)
+		if err != nil || total != 2 {
+			t.Fatalf("expected 2 rooms, got %d (err %v)", total, err)
+		}
+		var out []string
+		for _, room := range list {
+			if room.Content != nil {
+				t.Errorf("room %s listed with content", room.ID)
+			}
+			out = append(out, room.ID)
+		}
+		return out
+	}
+	if got := ids(ListRoomsOptions{Sort: RoomSortExpiry}); len(got) != 2 || got[0] != "r3" || got[1] != "r2" {
+		t.Errorf("by expiry: expected [r3 r2], got %v", got)
+	}
+	if got := ids(ListRoomsOptions{Sort: RoomSortActivity, Desc: true, Limit: 1}); len(got) != 1 || got[0] != "r3" {
+		t.Errorf("by activity: expected [r3], got %v", got)
+	}
+
+	// Databases from before the owner index get it built on open, and rooms
+	// from before activity was recorded count as active when created
+	err = b.db.Update(func(tx *bbolt.Tx) error {
+		if err := putRoom(tx, &models.Room{ID: "r5", Slug: "old", Owner: "carol", CreatedAt: now.Add(-time.Hour)}); err != nil {
+			return err
+		}
+		return tx.DeleteBucket(ownerRoomsBucket)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Close()
+	if b, err = OpenBolt(path, CodecZstd); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if got := ids(ListRoomsOptions{}); len(got) != 2 {
+		t.Errorf("after migration: expected 2 rooms, got %v", got)
+	}
+	old, err := b.Rooms().Get(ctx, "r5")
+	if err != nil || !old.ActiveAt.Equal(old.CreatedAt) {
+		t.Errorf("expected activity backfilled from creation, got %+v (err %v)", old, err)
+	}
+}
+
+func TestBoltMigratesLegacyRooms(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "notex.db")
+	b, err := OpenBolt(path, CodecZstd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rooms and files keyed by slug, as written before rooms had IDs
+	err = b.db.Update(func(tx *bbolt.Tx) error {
+		data, _ := bson.Marshal(models.Room{Slug: "legacy", ExpireAt: time.Now().Add(time.Hour)})
+		return tx.Bucket(roomsBucket).Put([]byte("legacy"), data)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Files().Create(ctx, &models.File{ID: "f1", RoomID: "legacy"})
+	b.Close()
+
+	b, err = OpenBolt(path, CodecZstd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	room, _, err := b.Rooms().Resolve(ctx, "legacy")
+	if err != nil || room.ID == "" || room.ID == "legacy" {
+		t.Fatalf("expected migrated room with an ID, got %+v (err %v)", room, err)
+	}
+	if _, err := b.Files().Get(ctx, room.ID, "f1"); err != nil {
+		t.Errorf("expected file to move to the room ID, got %v", err)
+	}
+}
+
+func TestBoltMigratesStringContent(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "notex.db")
+	b, err := OpenBolt(path, CodecZstd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Content as stored before it was binary: the client's base64 string,
+	// or JSON from early clients
+	expireAt := time.Now().Add(time.Hour)
+	err = b.db.Update(func(tx *bbolt.Tx) error {
+		for id, content := range map[string]interface{}{
+			"base64": "AAA=",
+			"json":   bson.M{"type": "doc"},
+		} {
+			data, _ := bson.Marshal(bson.M{"_id": id, "slug": id, "content": content, "expire_at": expireAt})
+			if err := tx.Bucket(roomsBucket).Put([]byte(id), data); err != nil {
+				return err
+			}
+			if err := tx.Bucket(slugsBucket).Put([]byte(id), []byte(id)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Close()
+
+	b, err = OpenBolt(path, CodecZstd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	room, err := b.Rooms().Get(ctx, "base64")
+	if err != nil || string(room.Content) != "\x00\x00" {
+		t.Errorf("expected decoded content, got %+v (err %v)", room, err)
+	}
+	room, err = b.Rooms().Get(ctx, "json")
+	if err != nil || room.Content != nil {
+		t.Errorf("expected JSON content moved aside, got %+v (err %v)", room, err)
+	}
+	b.db.View(func(tx *bbolt.Tx) error {
+		if _, err := bson.Raw(tx.Bucket(roomsBucket).Get([]byte("json"))).LookupErr("legacy_content"); err != nil {
+			t.Errorf("expected legacy_content to be kept: %v", err)
+		}
+		return nil
+	})
+}
+
+func TestBoltCompressesContent(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "notex.db")
+	b, err := OpenBolt(path, CodecZstd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := bytes.Repeat([]byte("table row "), 1000)
+	room := &models.Room{Slug: "tables", Content: []byte{0, 0}, ExpireAt: time.Now().Add(time.Hour)}
+	if err := b.Rooms().Create(ctx, room); err != nil {
+		t.Fatal(err)
+	}
+	if string(room.Content) != "\x00\x00" || room.Codec != "" {
+		t.Errorf("Create should leave the caller's room alone, got %+v", room)
+	}
+	if _, err := b.Rooms().SaveContent(ctx, room.ID, content, room.ExpireAt, AnyVersion); err != nil {
+		t.Fatal(err)
+	}
+	b.db.View(func(tx *bbolt.Tx) error {
+		var stored models.Room
+		bson.Unmarshal(tx.Bucket(roomsBucket).Get([]byte(room.ID)), &stored)
+		if stored.Codec != "zstd" || len(stored.Content) >= len(content) {
+			t.Errorf("expected zstd content at rest, got codec %q and %d bytes", stored.Codec, len(stored.Content))
+		}
+		return nil
+	})
+	b.Close()
+
+	// Content saved with another codec still reads after switching
+	b, err = OpenBolt(path, CodecSnappy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	got, err := b.Rooms().Get(ctx, room.ID)
+	if err != nil || !bytes.Equal(got.Content, content) {
+		t.Fatalf("expected decompressed content, got %d bytes (err %v)", len(got.Content), err)
+	}
+	if got.ContentSize != int64(len(content)) || got.StoredSize == 0 || got.StoredSize >= got.ContentSize {
+		t.Errorf("unexpected sizes: content %d, stored %d", got.ContentSize, got.StoredSize)
+	}
+
+	b.Rooms().SaveContent(ctx, room.ID, content, room.ExpireAt, AnyVersion)
+	got, _ = b.Rooms().Get(ctx, room.ID)
+	if got.Codec != string(CodecSnappy) || !bytes.Equal(got.Content, content) {
+		t.Errorf("expected snappy content, got codec %q", got.Codec)
+	}
+
+	// Streamed reads decompress as they go
+	resolved, _, stream, err := b.Rooms().ResolveContent(ctx, "tables")
+	if err != nil || resolved.Content != nil || stream.Size != int64(len(content)) {
+		t.Fatalf("expected the room without its content, got %+v (err %v)", resolved, err)
+	}
+	if data, err := stream.ReadAll(ctx); err != nil || !bytes.Equal(data, content) {
+		t.Errorf("expected streamed content, got %d bytes (err %v)", len(data), err)
+	}
+
+	// Content that does not shrink is stored raw
+	b.Rooms().SaveContent(ctx, room.ID, []byte{0, 0}, room.ExpireAt, AnyVersion)
+	got, _ = b.Rooms().Get(ctx, room.ID)
+	if got.Codec != "" || got.StoredSize != 2 || got.ContentSize != 2 {
+		t.Errorf("expected raw content, got codec %q and sizes %d/%d", got.Codec, got.ContentSize, got.StoredSize)
+	}
+}
+
+func TestBoltFileStore(t *testing.T) {
+	ctx := context.Background()
+	files := openTestBolt(t).Files()
+
+	files.Create(ctx, &models.File{ID: "a", RoomID: "docs", Name: "b.txt"})
+	files.Create(ctx, &models.File{ID: "b", RoomID: "docs", Name: "a.txt"})
+	files.Create(ctx, &models.File{ID: "c", RoomID: "other", Name: "c.txt"})
+
+	if _, err := files.Get(ctx, "other", "a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("file should not be visible from another room, got %v", err)
+	}
+
+	pinned := true
+	if _, err := files.Update(ctx, "docs", "a", FileUpdate{Pinned: &pinned}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	list, total, err := files.List(ctx, "docs", ListFilesOptions{Sort: FileSortName})
+	if err != nil || total != 2 || len(list) != 2 || list[0].ID != "a" {
+		t.Errorf("expected pinned file first of 2, got %+v (total %d, err %v)", list, total, err)
+	}
+
+	if err := files.DeleteByRoom(ctx, "docs"); err != nil {
+		t.Fatalf("DeleteByRoom failed: %v", err)
+	}
+	if list, _, _ := files.List(ctx, "docs", ListFilesOptions{}); len(list) != 0 {
+		t.Errorf("expected no files after DeleteByRoom, got %d", len(list))
+	}
+	if _, err := files.Get(ctx, "other", "c"); err != nil {
+		t.Errorf("other room's files should survive, got %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pranavdhawale/notex/server/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// legacyContent converts room content from before it was stored as binary,
+// when the base64 the client sent was stored as is. ok is false for content
+// that is not a base64 string, such as JSON from early clients; migrations
+// keep that under legacy_content rather than dropping it.
+func legacyContent(v bson.RawValue) (content []byte, ok bool) {
+	if v.Type != bsontype.String {
+		return nil, false
+	}
+	content, err := base64.StdEncoding.DecodeString(v.StringValue())
+	return content, err == nil
+}
+
+// Codec names how room content is compressed at rest
+type Codec string
+
+const (
+	CodecNone   Codec = "none"
+	CodecZstd   Codec = "zstd"
+	CodecSnappy Codec = "snappy" // In the framing format, so it can be decompressed as it is read
+)
+
+// The zstd coders are safe for concurrent EncodeAll and DecodeAll calls,
+// so one of each is shared
+var (
+	zstdEncoder = sync.OnceValue(func() *zstd.Encoder {
+		enc, _ := zstd.NewWriter(nil)
+		return enc
+	})
+	zstdDecoder = sync.OnceValue(func() *zstd.Decoder {
+		dec, _ := zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+		return dec
+	})
+)
+
+// packContent sets the content of a room as it is stored, compressed with
+// codec, and its sizes. Content that compression does not shrink is stored
+// raw, without a codec tag.
+func packContent(room *models.Room, content []byte, codec Codec) {
+	room.Content, room.Codec = content, ""
+	room.ContentSize, room.StoredSize = int64(len(content)), int64(len(content))
+	if len(content) == 0 {
+		return
+	}
+
+	var packed []byte
+	switch codec {
+	case CodecZstd:
+		packed = zstdEncoder().EncodeAll(content, nil)
+	case CodecSnappy:
+		var buf bytes.Buffer
+		w := snappy.NewBufferedWriter(&buf)
+		w.Write(content)
+		w.Close()
+		packed = buf.Bytes()
+	default:
+		return
+	}
+	if len(packed) < len(content) {
+		room.Content, room.Codec = packed, string(codec)
+		room.StoredSize = int64(len(packed))
+	}
+}
+
+// unpackContent decompresses the content of a room loaded from storage and
+// records its raw and stored sizes
+func unpackContent(room *models.Room) error {
+	room.StoredSize = int64(len(room.Content))
+	var err error
+	switch Codec(room.Codec) {
+	case "":
+	case CodecZstd:
+		room.Content, err = zstdDecoder().DecodeAll(room.Content, nil)
+	case CodecSnappy:
+		room.Content, err = io.ReadAll(snappy.NewReader(bytes.NewReader(room.Content)))
+	default:
+		err = fmt.Errorf("unknown codec %q", room.Codec)
+	}
+	if err != nil {
+		return fmt.Errorf("decompress content of room %s: %w", room.ID, err)
+	}
+	room.ContentSize = int64(len(room.Content))
+	return nil
+}
+
+// Content is the content of a room as stored, read on demand so that
+// large content can be streamed rather than held in memory
+type Content struct {
+	Size int64 // Bytes once decompressed
+
+	codec Codec
+	open  func(ctx context.Context) (io.ReadCloser, error) // Reads the stored bytes
+}
+
+// ContentOf wraps content already in memory
+func ContentOf(content []byte) *Content {
+	return storedContent(content, "", int64(len(content)))
+}
+
+// storedContent wraps stored bytes already in memory
+func storedContent(stored []byte, codec Codec, size int64) *Content {
+	return &Content{Size: size, codec: codec, open: func(context.Context) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(stored)), nil
+	}}
+}
+
+// splitContent takes the content out of a room as stored, for reading as
+// a stream
+func splitContent(room *models.Room) *Content {
+	if room.Codec == "" && room.ContentID == "" {
+		// Raw content is its own size
+		room.ContentSize = int64(len(room.Content))
+		room.StoredSize = room.ContentSize
+	}
+	content := storedContent(room.Content, Codec(room.Codec), room.ContentSize)
+	room.Content = nil
+	return content
+}
+
+// Open returns a reader of the content, decompressing it as it is read.
+// Callers close it.
+func (c *Content) Open(ctx context.Context) (io.ReadCloser, error) {
+	r, err := c.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch c.codec {
+	case "":
+		return r, nil
+	case CodecZstd:
+		dec, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		return &decompressor{Reader: dec, close: func() error { dec.Close(); return r.Close() }}, nil
+	case CodecSnappy:
+		return &decompressor{Reader: snappy.NewReader(r), close: r.Close}, nil
+	}
+	r.Close()
+	return nil, fmt.Errorf("unknown codec %q", c.codec)
+}
+
+// ReadAll reads the whole content into memory
+func (c *Content) ReadAll(ctx context.Context) ([]byte, error) {
+	r, err := c.Open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+type decompressor struct {
+	io.Reader
+	close func() error
+}
+
+func (d *decompressor) Close() error {
+	return d.close()
+}
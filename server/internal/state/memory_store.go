@@ -0,0 +1,475 @@
+package state
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pranavdhawale/notex/server/internal/models"
+)
+
+// MemoryRoomStore keeps rooms in memory. Rooms past expire_at behave as if
+// the TTL index had already deleted them. Nothing survives a restart.
+type MemoryRoomStore struct {
+	mu      sync.Mutex
+	rooms   map[string]*models.Room // ID -> room
+	slugs   map[string]string       // current slug -> ID
+	aliases map[string]string       // old slug -> ID
+
+	// Clock, replaceable in tests
+	now func() time.Time
+}
+
+func NewMemoryRoomStore() *MemoryRoomStore {
+	return &MemoryRoomStore{
+		rooms:   make(map[string]*models.Room),
+		slugs:   make(map[string]string),
+		aliases: make(map[string]string),
+		now:     time.Now,
+	}
+}
+
+// lookup returns a live room, purging it if it has expired. Callers hold mu.
+func (s *MemoryRoomStore) lookup(id string) (*models.Room, bool) {
+	room, ok := s.rooms[id]
+	if !ok {
+		return nil, false
+	}
+	if room.Expired(s.now()) {
+		s.remove(id)
+		return nil, false
+	}
+	return room, true
+}
+
+// loaded returns a copy of a room as the other stores load it, with its
+// sizes filled in. Content is kept raw, so this cannot fail.
+func loaded(room *models.Room) *models.Room {
+	copied := *room
+	_ = unpackContent(&copied)
+	return &copied
+}
+
+// remove deletes a room with its slug and aliases. Callers hold mu.
+func (s *MemoryRoomStore) remove(id string) {
+	if room, ok := s.rooms[id]; ok {
+		delete(s.slugs, room.Slug)
+	}
+	delete(s.rooms, id)
+	for alias, roomID := range s.aliases {
+		if roomID == id {
+			delete(s.aliases, alias)
+		}
+	}
+}
+
+// resolve finds the live room using a slug or alias. Callers hold mu.
+func (s *MemoryRoomStore) resolve(slug string) (*models.Room, bool, bool) {
+	if id, ok := s.slugs[slug]; ok {
+		if room, ok := s.lookup(id); ok {
+			return room, false, true
+		}
+	}
+	if id, ok := s.aliases[slug]; ok {
+		if room, ok := s.lookup(id); ok {
+			return room, true, true
+		}
+	}
+	return nil, false, false
+}
+
+func (s *MemoryRoomStore) Create(ctx context.Context, room *models.Room) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, _, ok := s.resolve(room.Slug); ok {
+		return ErrSlugTaken
+	}
+	if room.ID == "" {
+		room.ID = newRoomID()
+	}
+	stored := *room
+	packContent(&stored, room.Content, CodecNone)
+	s.rooms[room.ID] = &stored
+	s.slugs[room.Slug] = room.ID
+	return nil
+}
+
+func (s *MemoryRoomStore) Get(ctx context.Context, id string) (*models.Room, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room, ok := s.lookup(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return loaded(room), nil
+}
+
+func (s *MemoryRoomStore) Resolve(ctx context.Context, slug string) (*models.Room, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room, aliased, ok := s.resolve(slug)
+	if !ok {
+		return nil, false, ErrNotFound
+	}
+	return loaded(room), aliased, nil
+}
+
+func (s *MemoryRoomStore) ResolveContent(ctx context.Context, slug string) (*models.Room, bool, *Content, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room, aliased, ok := s.resolve(slug)
+	if !ok {
+		return nil, false, nil, ErrNotFound
+	}
+	copied := *room
+	content := splitContent(&copied)
+	return &copied, aliased, content, nil
+}
+
+func (s *MemoryRoomStore) Exists(ctx context.Context, slug string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, _, ok := s.resolve(slug)
+	return ok, nil
+}
+
+func (s *MemoryRoomStore) SaveContent(ctx context.Context, id string, content []byte, expireAt time.Time, version int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room, ok := s.lookup(id)
+	if !ok {
+		return 0, ErrNotFound
+	}
+	if err := saveContent(room, content, s.now(), expireAt, version, CodecNone); err != nil {
+		return 0, err
+	}
+	room.Revision++
+	return room.Version, nil
+}
+
+func (s *MemoryRoomStore) SetExpiry(ctx context.Context, id string, expireAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room, ok := s.lookup(id)
+	if !ok {
+		return ErrNotFound
+	}
+	room.ExpireAt = expireAt
+	room.Revision++
+	return nil
+}
+
+func (s *MemoryRoomStore) Update(ctx context.Context, id string, update RoomUpdate) (*models.Room, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room, ok := s.lookup(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	update.apply(room)
+	room.Revision++
+	return loaded(room), nil
+}
+
+func (s *MemoryRoomStore) RecordRead(ctx context.Context, id string) (*models.Room, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room, ok := s.lookup(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if room.Burned() {
+		return nil, ErrBurned
+	}
+	room.Reads++
+	room.Revision++
+	return loaded(room), nil
+}
+
+func (s *MemoryRoomStore) Burn(ctx context.Context, id string, expireAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room, ok := s.lookup(id)
+	if !ok {
+		return ErrNotFound
+	}
+	if room.Burned() {
+		return ErrBurned
+	}
+	burn(room, s.now(), expireAt)
+	room.Revision++
+	return nil
+}
+
+func (s *MemoryRoomStore) Expiring(ctx context.Context, before time.Time) ([]models.Room, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rooms := []models.Room{}
+	for id := range s.rooms {
+		if room, ok := s.lookup(id); ok && expiring(room, before) {
+			rooms = append(rooms, *loaded(room))
+		}
+	}
+	return rooms, nil
+}
+
+func (s *MemoryRoomStore) ClaimWarning(ctx context.Context, id string, expireAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room, ok := s.lookup(id)
+	if !ok || !room.ExpireAt.Equal(expireAt) || room.WarnedFor.Equal(expireAt) {
+		return false, nil
+	}
+	room.WarnedFor = expireAt
+	room.Revision++
+	return true, nil
+}
+
+func (s *MemoryRoomStore) Rename(ctx context.Context, id, slug string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room, ok := s.lookup(id)
+	if !ok {
+		return ErrNotFound
+	}
+	if room.Slug == slug {
+		return nil
+	}
+	if other, _, ok := s.resolve(slug); ok && other.ID != id {
+		return ErrSlugTaken
+	}
+
+	// Renaming back to an old slug turns that alias into the slug again
+	delete(s.aliases, slug)
+	delete(s.slugs, room.Slug)
+	s.aliases[room.Slug] = id
+	s.slugs[slug] = id
+	room.Slug = slug
+	room.Revision++
+	return nil
+}
+
+func (s *MemoryRoomStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.remove(id)
+	return nil
+}
+
+func (s *MemoryRoomStore) Touch(ctx context.Context, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room, ok := s.lookup(id)
+	if !ok {
+		return ErrNotFound
+	}
+	if at.After(room.ActiveAt) {
+		room.ActiveAt = at
+		room.Revision++
+	}
+	return nil
+}
+
+func (s *MemoryRoomStore) IDs(ctx context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := []string{}
+	for id := range s.rooms {
+		if _, ok := s.lookup(id); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (s *MemoryRoomStore) Live(ctx context.Context, ids []string) (map[string]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	alive := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if _, ok := s.lookup(id); ok {
+			alive[id] = true
+		}
+	}
+	return alive, nil
+}
+
+func (s *MemoryRoomStore) ListByOwner(ctx context.Context, owner string, opts ListRoomsOptions) ([]models.Room, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rooms := []models.Room{}
+	for id, room := range s.rooms {
+		if room.Owner != owner {
+			continue
+		}
+		if room, ok := s.lookup(id); ok {
+			listed := *room
+			listed.Content = nil
+			rooms = append(rooms, listed)
+		}
+	}
+	rooms, total := sortAndPageRooms(rooms, opts)
+	return rooms, total, nil
+}
+
+// MemoryFileStore keeps file metadata in memory
+type MemoryFileStore struct {
+	mu    sync.Mutex
+	files map[string]*models.File // file ID -> file
+}
+
+func NewMemoryFileStore() *MemoryFileStore {
+	return &MemoryFileStore{files: make(map[string]*models.File)}
+}
+
+func (s *MemoryFileStore) Create(ctx context.Context, file *models.File) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *file
+	s.files[file.ID] = &stored
+	return nil
+}
+
+func (s *MemoryFileStore) Get(ctx context.Context, roomID, fileID string) (*models.File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, ok := s.files[fileID]
+	if !ok || file.RoomID != roomID {
+		return nil, ErrNotFound
+	}
+	copied := *file
+	return &copied, nil
+}
+
+func (s *MemoryFileStore) List(ctx context.Context, roomID string, opts ListFilesOptions) ([]models.File, int64, error) {
+	s.mu.Lock()
+	files := []models.File{}
+	for _, file := range s.files {
+		if file.RoomID == roomID {
+			files = append(files, *file)
+		}
+	}
+	s.mu.Unlock()
+
+	files, total := sortAndPageFiles(files, opts)
+	return files, total, nil
+}
+
+func (s *MemoryFileStore) Update(ctx context.Context, roomID, fileID string, update FileUpdate) (*models.File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, ok := s.files[fileID]
+	if !ok || file.RoomID != roomID {
+		return nil, ErrNotFound
+	}
+	if update.Name != nil {
+		file.Name = *update.Name
+	}
+	if update.Description != nil {
+		file.Description = *update.Description
+	}
+	if update.Pinned != nil {
+		file.Pinned = *update.Pinned
+	}
+	copied := *file
+	return &copied, nil
+}
+
+func (s *MemoryFileStore) Delete(ctx context.Context, roomID, fileID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if file, ok := s.files[fileID]; ok && file.RoomID == roomID {
+		delete(s.files, fileID)
+	}
+	return nil
+}
+
+func (s *MemoryFileStore) DeleteByRoom(ctx context.Context, roomID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, file := range s.files {
+		if file.RoomID == roomID {
+			delete(s.files, id)
+		}
+	}
+	return nil
+}
+
+// MemoryTemplateStore keeps templates in memory
+type MemoryTemplateStore struct {
+	mu        sync.Mutex
+	templates map[string]*models.Template
+}
+
+func NewMemoryTemplateStore() *MemoryTemplateStore {
+	return &MemoryTemplateStore{templates: make(map[string]*models.Template)}
+}
+
+func (s *MemoryTemplateStore) Create(ctx context.Context, t *models.Template) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t.ID == "" {
+		t.ID = newRoomID()
+	}
+	stored := *t
+	s.templates[t.ID] = &stored
+	return nil
+}
+
+func (s *MemoryTemplateStore) Get(ctx context.Context, id string) (*models.Template, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.templates[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *t
+	return &copied, nil
+}
+
+func (s *MemoryTemplateStore) List(ctx context.Context, owner string) ([]models.Template, error) {
+	s.mu.Lock()
+	templates := []models.Template{}
+	for _, t := range s.templates {
+		if t.Owner == owner {
+			templates = append(templates, *t)
+		}
+	}
+	s.mu.Unlock()
+
+	sortTemplates(templates)
+	return templates, nil
+}
+
+func (s *MemoryTemplateStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.templates, id)
+	return nil
+}
@@ -0,0 +1,68 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pranavdhawale/notex/server/internal/models"
+)
+
+func TestMemoryRoomStoreExpiry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	s := NewMemoryRoomStore()
+	s.now = func() time.Time { return now }
+
+	short := &models.Room{Slug: "short", ExpireAt: now.Add(time.Minute)}
+	long := &models.Room{Slug: "long", ExpireAt: now.Add(time.Hour)}
+	s.Create(ctx, short)
+	s.Create(ctx, long)
+
+	if err := s.Create(ctx, &models.Room{Slug: "short", ExpireAt: now.Add(time.Hour)}); !errors.Is(err, ErrSlugTaken) {
+		t.Errorf("expected ErrSlugTaken for live slug, got %v", err)
+	}
+
+	now = now.Add(2 * time.Minute)
+
+	if _, _, err := s.Resolve(ctx, "short"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected expired room to be gone, got %v", err)
+	}
+	if _, err := s.SaveContent(ctx, short.ID, []byte("x"), now.Add(time.Hour), AnyVersion); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected save to expired room to fail, got %v", err)
+	}
+
+	live, _ := s.Live(ctx, []string{short.ID, long.ID})
+	if live[short.ID] || !live[long.ID] {
+		t.Errorf("unexpected live set: %v", live)
+	}
+
+	// Expired slugs are free again
+	if err := s.Create(ctx, &models.Room{Slug: "short", ExpireAt: now.Add(time.Hour)}); err != nil {
+		t.Errorf("expected expired slug to be reusable, got %v", err)
+	}
+}
+
+func TestMemoryRoomStoreRename(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryRoomStore()
+	expireAt := time.Now().Add(time.Hour)
+
+	room := &models.Room{Slug: "old-name", ExpireAt: expireAt}
+	s.Create(ctx, room)
+
+	if err := s.Rename(ctx, room.ID, "new-name"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	got, aliased, err := s.Resolve(ctx, "old-name")
+	if err != nil || !aliased || got.Slug != "new-name" {
+		t.Errorf("expected old slug to resolve as alias, got %+v aliased=%v (err %v)", got, aliased, err)
+	}
+
+	other := &models.Room{Slug: "other", ExpireAt: expireAt}
+	s.Create(ctx, other)
+	if err := s.Rename(ctx, other.ID, "old-name"); !errors.Is(err, ErrSlugTaken) {
+		t.Errorf("expected alias to block rename, got %v", err)
+	}
+}
@@ -2,18 +2,19 @@ package state
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"time"
 
+	"github.com/pranavdhawale/notex/server/internal/config"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var MongoClient *mongo.Client
-var MongoDatabase *mongo.Database
-
-func InitMongo(uri string, dbName string) {
+// InitMongo connects to MongoDB, retrying while it starts up, and prepares indexes
+func InitMongo(cfg config.MongoConfig) *mongo.Database {
 	maxRetries := 30
 	retryDelay := 2 * time.Second
 
@@ -23,7 +24,7 @@ func InitMongo(uri string, dbName string) {
 	for attempt := 1; attempt <= maxRetries; attempt++ {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		
-		client, err = mongo.Connect(ctx, options.Client().ApplyURI(uri))
+		client, err = mongo.Connect(ctx, options.Client().ApplyURI(cfg.URI))
 		if err != nil {
 			cancel()
 			log.Printf("Failed to create Mongo client (attempt %d/%d): %v", attempt, maxRetries, err)
@@ -41,11 +42,10 @@ func InitMongo(uri string, dbName string) {
 		}
 
 		// Success!
-		MongoClient = client
-		MongoDatabase = client.Database(dbName)
+		db := client.Database(cfg.Database)
 		
 		// Create TTL Index for Dynamic Expiration
-		roomsCollection := MongoDatabase.Collection("rooms")
+		roomsCollection := db.Collection("rooms")
 		indexModel := mongo.IndexModel{
 			Keys: bson.M{"expire_at": 1},
 			Options: options.Index().SetExpireAfterSeconds(0), // Expire exactly at the time specified in expire_at
@@ -60,11 +60,213 @@ func InitMongo(uri string, dbName string) {
 		} else {
 			log.Println("TTL Index created on rooms.expire_at")
 		}
+
+		// Unique Index on slug, so concurrent room creation cannot race.
+		// Rooms may share a slug from before the index, so they are renamed
+		// apart first. Without the index Create would silently hand out
+		// taken slugs, so the server must not start.
+		slugIndex := mongo.IndexModel{
+			Keys:    bson.M{"slug": 1},
+			Options: options.Index().SetUnique(true),
+		}
+
+		indexCtx, indexCancel = context.WithTimeout(context.Background(), time.Minute)
+		err = dedupeSlugs(indexCtx, roomsCollection)
+		if err == nil {
+			_, err = roomsCollection.Indexes().CreateOne(indexCtx, slugIndex)
+		}
+		indexCancel()
+
+		if err != nil {
+			log.Fatalf("Failed to create unique slug index: %v", err)
+		}
+		log.Println("Unique Index created on rooms.slug")
+
+		// Index on room_id, so deleting a room can drop its aliases
+		indexCtx, indexCancel = context.WithTimeout(context.Background(), 10*time.Second)
+		_, err = db.Collection("room_aliases").Indexes().CreateOne(indexCtx, mongo.IndexModel{Keys: bson.M{"room_id": 1}})
+		indexCancel()
+
+		if err != nil {
+			log.Printf("Failed to create room_aliases index: %v", err)
+		}
+
+		// Indexes for reading the chunks of large content in order, and
+		// for finding chunks no room refers to
+		indexCtx, indexCancel = context.WithTimeout(context.Background(), 10*time.Second)
+		_, err = db.Collection("room_chunks").Indexes().CreateOne(indexCtx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "content_id", Value: 1}, {Key: "n", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+		if err == nil {
+			_, err = roomsCollection.Indexes().CreateOne(indexCtx, mongo.IndexModel{Keys: bson.M{"content_id": 1}})
+		}
+		indexCancel()
+
+		if err != nil {
+			log.Printf("Failed to create content chunk indexes: %v", err)
+		}
+
+		// Indexes on owner, for listing a user's rooms by activity or expiry
+		indexCtx, indexCancel = context.WithTimeout(context.Background(), 10*time.Second)
+		_, err = roomsCollection.Indexes().CreateMany(indexCtx, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "owner", Value: 1}, {Key: "active_at", Value: 1}, {Key: "created_at", Value: 1}}},
+			{Keys: bson.D{{Key: "owner", Value: 1}, {Key: "pinned", Value: 1}, {Key: "expire_at", Value: 1}}},
+		})
+		indexCancel()
+
+		if err != nil {
+			log.Printf("Failed to create rooms owner indexes: %v", err)
+		}
+
+		// Index on owner, for listing a user's templates
+		indexCtx, indexCancel = context.WithTimeout(context.Background(), 10*time.Second)
+		_, err = db.Collection("templates").Indexes().CreateOne(indexCtx, mongo.IndexModel{Keys: bson.M{"owner": 1}})
+		indexCancel()
+
+		if err != nil {
+			log.Printf("Failed to create templates index: %v", err)
+		}
+
+		migrateCtx, migrateCancel := context.WithTimeout(context.Background(), time.Minute)
+		if err := migrateLegacyFiles(migrateCtx, db); err != nil {
+			log.Printf("Failed to migrate files of legacy rooms: %v", err)
+		}
+		if err := migrateContent(migrateCtx, db); err != nil {
+			log.Printf("Failed to migrate room content to binary: %v", err)
+		}
+		if err := migrateActivity(migrateCtx, db); err != nil {
+			log.Printf("Failed to backfill room activity: %v", err)
+		}
+		migrateCancel()
 		
 		log.Println("Connected to MongoDB")
-		return
+		return db
 	}
 
 	// All retries exhausted
 	log.Fatalf("Failed to connect to MongoDB after %d attempts: %v", maxRetries, err)
+	return nil
+}
+
+// migrateLegacyFiles re-keys files of rooms created before the server
+// assigned room IDs. Those rooms have an ObjectID and their files are
+// keyed by slug; afterwards they are keyed by the ObjectID's hex string,
+// which is the room's ID. Safe to run on every start.
+func migrateLegacyFiles(ctx context.Context, db *mongo.Database) error {
+	cursor, err := db.Collection("rooms").Find(ctx,
+		bson.M{"_id": bson.M{"$type": "objectId"}},
+		options.Find().SetProjection(bson.M{"slug": 1}))
+	if err != nil {
+		return err
+	}
+
+	var rooms []struct {
+		ID   primitive.ObjectID `bson:"_id"`
+		Slug string             `bson:"slug"`
+	}
+	if err := cursor.All(ctx, &rooms); err != nil {
+		return err
+	}
+
+	var migrated int64
+	for _, room := range rooms {
+		result, err := db.Collection("files").UpdateMany(ctx,
+			bson.M{"room_id": room.Slug},
+			bson.M{"$set": bson.M{"room_id": room.ID.Hex()}})
+		if err != nil {
+			return err
+		}
+		migrated += result.ModifiedCount
+	}
+
+	if migrated > 0 {
+		log.Printf("Migrated %d files of legacy rooms to room IDs", migrated)
+	}
+	return nil
+}
+
+// migrateContent converts room content stored as a base64 string, from
+// before content was binary, and moves content that is not base64 aside to
+// legacy_content. Safe to run on every start.
+func migrateContent(ctx context.Context, db *mongo.Database) error {
+	rooms := db.Collection("rooms")
+	cursor, err := rooms.Find(ctx,
+		bson.M{"content": bson.M{"$exists": true, "$not": bson.M{"$type": "binData"}}},
+		options.Find().SetProjection(bson.M{"content": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var migrated int
+	for cursor.Next(ctx) {
+		update := bson.M{"$rename": bson.M{"content": "legacy_content"}}
+		if content, ok := legacyContent(cursor.Current.Lookup("content")); ok {
+			update = bson.M{"$set": bson.M{"content": content}}
+		}
+		if _, err := rooms.UpdateByID(ctx, cursor.Current.Lookup("_id"), update); err != nil {
+			return err
+		}
+		migrated++
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	if migrated > 0 {
+		log.Printf("Migrated content of %d rooms to binary", migrated)
+	}
+	return nil
+}
+
+// dedupeSlugs renames rooms that share a slug, which only rooms created
+// before the unique slug index can. The room created first keeps the slug;
+// the others get their ID appended, and are logged so their owners can be
+// told.
+func dedupeSlugs(ctx context.Context, rooms *mongo.Collection) error {
+	cursor, err := rooms.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$sort", Value: bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$slug", "ids": bson.M{"$push": "$_id"}, "n": bson.M{"$sum": 1}}}},
+		{{Key: "$match", Value: bson.M{"n": bson.M{"$gt": 1}}}},
+	}, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return err
+	}
+	var dupes []struct {
+		Slug string        `bson:"_id"`
+		IDs  []interface{} `bson:"ids"`
+	}
+	if err := cursor.All(ctx, &dupes); err != nil {
+		return err
+	}
+
+	for _, d := range dupes {
+		for _, id := range d.IDs[1:] {
+			slug := fmt.Sprintf("%s-%v", d.Slug, id)
+			if oid, ok := id.(primitive.ObjectID); ok {
+				slug = d.Slug + "-" + oid.Hex()
+			}
+			if _, err := rooms.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"slug": slug}}); err != nil {
+				return err
+			}
+			log.Printf("Renamed room %v from duplicate slug %s to %s", id, d.Slug, slug)
+		}
+	}
+	return nil
+}
+
+// migrateActivity sets the last activity of rooms created before activity
+// was recorded to their creation time. Safe to run on every start.
+func migrateActivity(ctx context.Context, db *mongo.Database) error {
+	result, err := db.Collection("rooms").UpdateMany(ctx,
+		bson.M{"active_at": bson.M{"$exists": false}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{"active_at": "$created_at"}}}})
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount > 0 {
+		log.Printf("Backfilled activity of %d rooms", result.ModifiedCount)
+	}
+	return nil
 }
@@ -0,0 +1,214 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pranavdhawale/notex/server/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Content too large to keep in the room document, which Mongo caps at
+// 16MB, is split across documents in "room_chunks", as GridFS does. The
+// room names its chunks with a content ID that changes on every save, so a
+// save never overwrites chunks somebody may be reading.
+const (
+	maxInlineContent = 12 << 20 // Leaves room for the rest of the document
+	contentChunkSize = 1 << 20
+
+	// How long unreferenced chunks are kept, so the sweeper does not
+	// delete chunks whose room has yet to be written
+	orphanChunkAge = time.Hour
+)
+
+// errChunksMissing means the chunks of a room were replaced while they
+// were being read
+var errChunksMissing = errors.New("content chunks missing")
+
+type contentChunk struct {
+	ContentID string    `bson:"content_id"`
+	N         int       `bson:"n"`
+	Data      []byte    `bson:"data"`
+	CreatedAt time.Time `bson:"created_at"`
+}
+
+// writeChunks moves content of room too large for its document to chunks
+func (s *MongoRoomStore) writeChunks(ctx context.Context, room *models.Room) error {
+	room.ContentID, room.Chunks = "", 0
+	if len(room.Content) <= maxInlineContent {
+		return nil
+	}
+
+	id := uuid.NewString()
+	chunks := splitChunks(id, room.Content, time.Now())
+	// Unordered inserts are sent in batches under the message size limit
+	if _, err := s.chunks.InsertMany(ctx, chunks, options.InsertMany().SetOrdered(false)); err != nil {
+		s.deleteChunks(ctx, id)
+		return err
+	}
+	room.Content, room.ContentID, room.Chunks = nil, id, len(chunks)
+	return nil
+}
+
+// splitChunks cuts content into the chunks that store it under id
+func splitChunks(id string, content []byte, now time.Time) []interface{} {
+	var chunks []interface{}
+	for len(content) > 0 {
+		n := min(len(content), contentChunkSize)
+		chunks = append(chunks, contentChunk{ContentID: id, N: len(chunks), Data: content[:n], CreatedAt: now})
+		content = content[n:]
+	}
+	return chunks
+}
+
+// chunkReader reads content back from a cursor over its chunks in order.
+// It fails with errChunksMissing if they were replaced since the room was
+// read.
+type chunkReader struct {
+	ctx    context.Context
+	cursor *mongo.Cursor
+	chunks int // How many there should be
+	n      int // How many were read
+	data   []byte
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.data) == 0 {
+		if !r.cursor.Next(r.ctx) {
+			if err := r.cursor.Err(); err != nil {
+				return 0, err
+			}
+			if r.n != r.chunks {
+				return 0, errChunksMissing
+			}
+			return 0, io.EOF
+		}
+		var chunk contentChunk
+		if err := r.cursor.Decode(&chunk); err != nil {
+			return 0, err
+		}
+		if chunk.N != r.n {
+			return 0, errChunksMissing
+		}
+		r.data = chunk.Data
+		r.n++
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func (r *chunkReader) Close() error {
+	return r.cursor.Close(r.ctx)
+}
+
+// openChunks starts reading the chunks of a room. The first chunk is
+// fetched right away, so content replaced in the meantime fails here
+// rather than partway through a response.
+func (s *MongoRoomStore) openChunks(ctx context.Context, room *models.Room) (*chunkReader, error) {
+	cursor, err := s.chunks.Find(ctx, bson.M{"content_id": room.ContentID}, options.Find().SetSort(bson.M{"n": 1}))
+	if err != nil {
+		return nil, err
+	}
+	r := &chunkReader{ctx: ctx, cursor: cursor, chunks: room.Chunks}
+	if _, err := r.Read(nil); err != nil {
+		r.Close()
+		if err == io.EOF {
+			err = errChunksMissing // A room only has chunks if they hold content
+		}
+		return nil, err
+	}
+	return r, nil
+}
+
+// readChunks reads the chunks of a room back into its content
+func (s *MongoRoomStore) readChunks(ctx context.Context, room *models.Room) error {
+	if room.ContentID == "" {
+		return nil
+	}
+	r, err := s.openChunks(ctx, room)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	var content bytes.Buffer
+	content.Grow(room.Chunks * contentChunkSize)
+	if _, err := content.ReadFrom(r); err != nil {
+		return err
+	}
+	room.Content = content.Bytes()
+	return nil
+}
+
+// load turns a room as stored into what callers see: chunks joined and
+// content decompressed
+func (s *MongoRoomStore) load(ctx context.Context, room *models.Room) error {
+	if err := s.readChunks(ctx, room); err != nil {
+		return fmt.Errorf("read content of room %s: %w", room.ID, err)
+	}
+	return unpackContent(room)
+}
+
+// deleteChunks removes chunks that are no longer referenced. Failures only
+// leave orphans behind for the sweeper.
+func (s *MongoRoomStore) deleteChunks(ctx context.Context, contentID string) {
+	if contentID == "" {
+		return
+	}
+	if _, err := s.chunks.DeleteMany(ctx, bson.M{"content_id": contentID}); err != nil {
+		log.Printf("Failed to delete content chunks %s: %v", contentID, err)
+	}
+}
+
+// SweepChunks periodically deletes chunks left behind by rooms that
+// expired through the TTL index, or by saves that failed halfway.
+// Blocks until ctx is cancelled.
+func (s *MongoRoomStore) SweepChunks(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n, err := s.sweepChunks(ctx); err != nil {
+				log.Printf("Failed to sweep content chunks: %v", err)
+			} else if n > 0 {
+				log.Printf("Swept %d orphaned content chunks", n)
+			}
+		}
+	}
+}
+
+func (s *MongoRoomStore) sweepChunks(ctx context.Context) (int64, error) {
+	ids, err := s.chunks.Distinct(ctx, "content_id", bson.M{"created_at": bson.M{"$lt": time.Now().Add(-orphanChunkAge)}})
+	if err != nil {
+		return 0, err
+	}
+
+	var swept int64
+	for _, id := range ids {
+		err := s.rooms.FindOne(ctx, bson.M{"content_id": id}, options.FindOne().SetProjection(bson.M{"_id": 1})).Err()
+		if err == nil {
+			continue
+		}
+		if err != mongo.ErrNoDocuments {
+			return swept, err
+		}
+		result, err := s.chunks.DeleteMany(ctx, bson.M{"content_id": id})
+		if err != nil {
+			return swept, err
+		}
+		swept += result.DeletedCount
+	}
+	return swept, nil
+}
@@ -0,0 +1,93 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/pranavdhawale/notex/server/internal/models"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// chunkCursor returns a cursor over chunks as Mongo would return them
+func chunkCursor(t *testing.T, chunks []interface{}) *mongo.Cursor {
+	t.Helper()
+	cursor, err := mongo.NewCursorFromDocuments(chunks, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cursor
+}
+
+func TestChunksSplitAndReassemble(t *testing.T) {
+	ctx := context.Background()
+	content := make([]byte, 2*contentChunkSize+contentChunkSize/2)
+	rand.New(rand.NewSource(1)).Read(content)
+
+	chunks := splitChunks("c1", content, time.Now())
+	if len(chunks) != 3 {
+		t.Fatalf("expected 3 chunks, got %d", len(chunks))
+	}
+	for i, chunk := range chunks {
+		c := chunk.(contentChunk)
+		if c.ContentID != "c1" || c.N != i {
+			t.Errorf("unexpected chunk %d: %s/%d", i, c.ContentID, c.N)
+		}
+	}
+	if last := chunks[2].(contentChunk); len(last.Data) != contentChunkSize/2 {
+		t.Errorf("expected a short last chunk, got %d bytes", len(last.Data))
+	}
+	if len(splitChunks("c2", nil, time.Now())) != 0 {
+		t.Error("expected no chunks for no content")
+	}
+
+	r := &chunkReader{ctx: ctx, cursor: chunkCursor(t, chunks), chunks: len(chunks)}
+	got, err := io.ReadAll(r)
+	if err != nil || !bytes.Equal(got, content) {
+		t.Errorf("expected the content back, got %d bytes (err %v)", len(got), err)
+	}
+
+	// Chunks replaced while being read fail rather than return a mix
+	missing := []interface{}{chunks[0], chunks[2]}
+	r = &chunkReader{ctx: ctx, cursor: chunkCursor(t, missing), chunks: len(chunks)}
+	if _, err := io.ReadAll(r); !errors.Is(err, errChunksMissing) {
+		t.Errorf("expected errChunksMissing for a gap, got %v", err)
+	}
+	r = &chunkReader{ctx: ctx, cursor: chunkCursor(t, chunks[:2]), chunks: len(chunks)}
+	if _, err := io.ReadAll(r); !errors.Is(err, errChunksMissing) {
+		t.Errorf("expected errChunksMissing for a short read, got %v", err)
+	}
+}
+
+func TestChunkedContentStreams(t *testing.T) {
+	ctx := context.Background()
+	content := bytes.Repeat([]byte("paragraph "), contentChunkSize/4)
+
+	for _, codec := range []Codec{CodecNone, CodecZstd, CodecSnappy} {
+		var room models.Room
+		packContent(&room, content, codec)
+		// Split the stored bytes finer than usual, so reads span chunks
+		var chunks []interface{}
+		for data := room.Content; len(data) > 0; {
+			n := min(len(data), 1000)
+			chunks = append(chunks, contentChunk{ContentID: "c", N: len(chunks), Data: data[:n]})
+			data = data[n:]
+		}
+
+		stream := storedContent(nil, Codec(room.Codec), room.ContentSize)
+		stream.open = func(ctx context.Context) (io.ReadCloser, error) {
+			return &chunkReader{ctx: ctx, cursor: chunkCursor(t, chunks), chunks: len(chunks)}, nil
+		}
+		if stream.Size != int64(len(content)) {
+			t.Errorf("%s: expected size %d, got %d", codec, len(content), stream.Size)
+		}
+		got, err := stream.ReadAll(ctx)
+		if err != nil || !bytes.Equal(got, content) {
+			t.Errorf("%s: expected the content back, got %d bytes (err %v)", codec, len(got), err)
+		}
+	}
+}
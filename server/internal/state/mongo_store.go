@@ -0,0 +1,763 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pranavdhawale/notex/server/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoRoomStore stores rooms in the "rooms" collection, the old slugs of
+// renamed rooms in "room_aliases" and content too large for a document in
+// "room_chunks".
+// Expiry is enforced by the TTL index created in InitMongo. Aliases of
+// expired rooms are left behind and cleaned up when next resolved.
+type MongoRoomStore struct {
+	rooms   *mongo.Collection
+	aliases *mongo.Collection
+	chunks  *mongo.Collection
+	codec   Codec // Compression of saved room content
+}
+
+func NewMongoRoomStore(db *mongo.Database, codec Codec) *MongoRoomStore {
+	return &MongoRoomStore{
+		rooms:   db.Collection("rooms"),
+		aliases: db.Collection("room_aliases"),
+		chunks:  db.Collection("room_chunks"),
+		codec:   codec,
+	}
+}
+
+// roomAlias maps an old slug to the room that used to have it
+type roomAlias struct {
+	Slug      string    `bson:"_id"`
+	RoomID    string    `bson:"room_id"`
+	CreatedAt time.Time `bson:"created_at"`
+}
+
+// idFilter matches a room by ID. Rooms created before the server assigned
+// IDs have an ObjectID, which decodes to its hex string.
+func idFilter(id string) bson.M {
+	if oid, err := primitive.ObjectIDFromHex(id); err == nil {
+		return bson.M{"_id": bson.M{"$in": bson.A{oid, id}}}
+	}
+	return bson.M{"_id": id}
+}
+
+// Create inserts the room, relying on the unique slug index to detect
+// collisions atomically. Aliases are checked first; they only ever appear
+// for slugs that are still held by the renamed room, so this cannot race.
+func (s *MongoRoomStore) Create(ctx context.Context, room *models.Room) error {
+	if _, _, err := s.Resolve(ctx, room.Slug); err == nil {
+		return ErrSlugTaken
+	} else if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	if room.ID == "" {
+		room.ID = newRoomID()
+	}
+	stored := *room
+	packContent(&stored, room.Content, s.codec)
+	if err := s.writeChunks(ctx, &stored); err != nil {
+		return err
+	}
+	_, err := s.rooms.InsertOne(ctx, &stored)
+	if err != nil {
+		s.deleteChunks(ctx, stored.ContentID)
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrSlugTaken
+	}
+	return err
+}
+
+// findOne loads a room, reading it again if a save replaced its content
+// chunks before they were read
+func (s *MongoRoomStore) findOne(ctx context.Context, filter bson.M) (*models.Room, error) {
+	for attempt := 1; ; attempt++ {
+		var room models.Room
+		err := s.rooms.FindOne(ctx, filter).Decode(&room)
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = s.load(ctx, &room)
+		if errors.Is(err, errChunksMissing) && attempt < 3 {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &room, nil
+	}
+}
+
+func (s *MongoRoomStore) Get(ctx context.Context, id string) (*models.Room, error) {
+	return s.findOne(ctx, idFilter(id))
+}
+
+func (s *MongoRoomStore) Resolve(ctx context.Context, slug string) (*models.Room, bool, error) {
+	return s.resolve(ctx, slug, s.findOne)
+}
+
+func (s *MongoRoomStore) ResolveContent(ctx context.Context, slug string) (*models.Room, bool, *Content, error) {
+	var content *Content
+	room, aliased, err := s.resolve(ctx, slug, func(ctx context.Context, filter bson.M) (*models.Room, error) {
+		var room *models.Room
+		var err error
+		room, content, err = s.findContent(ctx, filter)
+		return room, err
+	})
+	return room, aliased, content, err
+}
+
+// findContent loads a room as stored and takes out its content, leaving
+// chunks to be read as the content is
+func (s *MongoRoomStore) findContent(ctx context.Context, filter bson.M) (*models.Room, *Content, error) {
+	var room models.Room
+	err := s.rooms.FindOne(ctx, filter).Decode(&room)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if room.ContentID == "" {
+		return &room, splitContent(&room), nil
+	}
+
+	chunked := room
+	content := storedContent(nil, Codec(room.Codec), room.ContentSize)
+	content.open = func(ctx context.Context) (io.ReadCloser, error) {
+		r, err := s.openChunks(ctx, &chunked)
+		if err != nil {
+			return nil, fmt.Errorf("read content of room %s: %w", chunked.ID, err)
+		}
+		return r, nil
+	}
+	return &room, content, nil
+}
+
+// resolve finds a room by slug or alias, loading it with find
+func (s *MongoRoomStore) resolve(ctx context.Context, slug string, find func(ctx context.Context, filter bson.M) (*models.Room, error)) (*models.Room, bool, error) {
+	room, err := find(ctx, bson.M{"slug": slug})
+	if err == nil || !errors.Is(err, ErrNotFound) {
+		return room, false, err
+	}
+
+	var alias roomAlias
+	err = s.aliases.FindOne(ctx, bson.M{"_id": slug}).Decode(&alias)
+	if err == mongo.ErrNoDocuments {
+		return nil, false, ErrNotFound
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	room, err = find(ctx, idFilter(alias.RoomID))
+	if errors.Is(err, ErrNotFound) {
+		// The room expired; free the slug
+		_, _ = s.aliases.DeleteOne(ctx, bson.M{"_id": slug, "room_id": alias.RoomID})
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return room, true, nil
+}
+
+// Exists counts the rooms with the slug, then those an alias of it points
+// to, without loading either
+func (s *MongoRoomStore) Exists(ctx context.Context, slug string) (bool, error) {
+	one := options.Count().SetLimit(1)
+	n, err := s.rooms.CountDocuments(ctx, bson.M{"slug": slug}, one)
+	if err != nil || n > 0 {
+		return n > 0, err
+	}
+
+	var alias roomAlias
+	err = s.aliases.FindOne(ctx, bson.M{"_id": slug}, options.FindOne().SetProjection(bson.M{"room_id": 1})).Decode(&alias)
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	n, err = s.rooms.CountDocuments(ctx, idFilter(alias.RoomID), one)
+	return n > 0, err
+}
+
+// revised adds bumping the revision of the room to an update
+func revised(update bson.M) bson.M {
+	inc, ok := update["$inc"].(bson.M)
+	if !ok {
+		inc = bson.M{}
+		update["$inc"] = inc
+	}
+	inc["revision"] = 1
+	return update
+}
+
+// update applies an update to one room, returning ErrNotFound if it is gone
+func (s *MongoRoomStore) update(ctx context.Context, id string, update bson.M) error {
+	// Use Upsert: false to prevent creating rooms on save if they don't exist
+	opts := options.Update().SetUpsert(false)
+	result, err := s.rooms.UpdateOne(ctx, idFilter(id), revised(update), opts)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// withExpiry adds expire_at to an update. A zero time must be unset rather
+// than stored, or the TTL index would delete the room right away.
+func withExpiry(set bson.M, expireAt time.Time) bson.M {
+	update := bson.M{"$set": set}
+	if expireAt.IsZero() {
+		update["$unset"] = bson.M{"expire_at": ""}
+	} else {
+		set["expire_at"] = expireAt
+	}
+	return update
+}
+
+func (s *MongoRoomStore) SaveContent(ctx context.Context, id string, content []byte, expireAt time.Time, version int64) (int64, error) {
+	filter := idFilter(id)
+	if version == 0 {
+		filter["version"] = bson.M{"$in": bson.A{0, nil}} // Rooms saved before versioning have none
+	} else if version != AnyVersion {
+		filter["version"] = version
+	}
+	var packed models.Room
+	packContent(&packed, content, s.codec)
+	if err := s.writeChunks(ctx, &packed); err != nil {
+		return 0, err
+	}
+	update := withExpiry(bson.M{
+		"content":      packed.Content,
+		"codec":        packed.Codec,
+		"content_id":   packed.ContentID,
+		"chunks":       packed.Chunks,
+		"content_size": packed.ContentSize,
+		"stored_size":  packed.StoredSize,
+		"active_at":    time.Now(),
+	}, expireAt)
+	update["$inc"] = bson.M{"version": 1, "revision": 1}
+
+	// The document before the update names the chunks it no longer uses
+	var previous struct {
+		Version   int64  `bson:"version"`
+		ContentID string `bson:"content_id"`
+	}
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.Before).
+		SetProjection(bson.M{"version": 1, "content_id": 1})
+	err := s.rooms.FindOneAndUpdate(ctx, filter, update, opts).Decode(&previous)
+	if err != nil {
+		s.deleteChunks(ctx, packed.ContentID)
+	}
+	if err == mongo.ErrNoDocuments {
+		if version == AnyVersion {
+			return 0, ErrNotFound
+		}
+		if _, err := s.Get(ctx, id); err != nil {
+			return 0, err
+		}
+		return 0, ErrVersionMismatch
+	}
+	if err != nil {
+		return 0, err
+	}
+	s.deleteChunks(ctx, previous.ContentID)
+	return previous.Version + 1, nil
+}
+
+func (s *MongoRoomStore) SetExpiry(ctx context.Context, id string, expireAt time.Time) error {
+	update := withExpiry(bson.M{}, expireAt)
+	if len(update["$set"].(bson.M)) == 0 {
+		delete(update, "$set") // An empty $set is rejected
+	}
+	return s.update(ctx, id, update)
+}
+
+func (s *MongoRoomStore) Update(ctx context.Context, id string, update RoomUpdate) (*models.Room, error) {
+	set, unset := bson.M{}, bson.M{}
+	if update.TTL != nil {
+		set["ttl"] = *update.TTL
+	}
+	if update.Pinned != nil {
+		set["pinned"] = *update.Pinned
+	}
+	if update.ExpireAt != nil {
+		if update.ExpireAt.IsZero() {
+			unset["expire_at"] = ""
+		} else {
+			set["expire_at"] = *update.ExpireAt
+		}
+	}
+	if update.Contact != nil {
+		if *update.Contact == "" {
+			unset["contact"] = ""
+		} else {
+			set["contact"] = *update.Contact
+		}
+	}
+
+	ops := bson.M{"$inc": bson.M{"revision": 1}}
+	if len(set) > 0 {
+		ops["$set"] = set
+	}
+	if len(unset) > 0 {
+		ops["$unset"] = unset
+	}
+	if len(set) == 0 && len(unset) == 0 {
+		return s.Get(ctx, id)
+	}
+
+	var room models.Room
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := s.rooms.FindOneAndUpdate(ctx, idFilter(id), ops, opts).Decode(&room)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.loadUpdated(ctx, &room)
+}
+
+// loadUpdated loads a room returned by an update, reading it again if its
+// content was saved over in the meantime
+func (s *MongoRoomStore) loadUpdated(ctx context.Context, room *models.Room) (*models.Room, error) {
+	err := s.load(ctx, room)
+	if errors.Is(err, errChunksMissing) {
+		return s.Get(ctx, room.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return room, nil
+}
+
+// unburned matches the room with the given ID unless it is a tombstone
+func unburned(id string) bson.M {
+	filter := idFilter(id)
+	filter["burned_at"] = bson.M{"$exists": false}
+	return filter
+}
+
+// burnedOrMissing tells apart the two reasons an unburned update matched nothing
+func (s *MongoRoomStore) burnedOrMissing(ctx context.Context, id string) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	return ErrBurned
+}
+
+func (s *MongoRoomStore) RecordRead(ctx context.Context, id string) (*models.Room, error) {
+	var room models.Room
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := s.rooms.FindOneAndUpdate(ctx, unburned(id), bson.M{"$inc": bson.M{"reads": 1, "revision": 1}}, opts).Decode(&room)
+	if err == mongo.ErrNoDocuments {
+		return nil, s.burnedOrMissing(ctx, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.loadUpdated(ctx, &room)
+}
+
+func (s *MongoRoomStore) Burn(ctx context.Context, id string, expireAt time.Time) error {
+	update := bson.M{
+		"$set":   bson.M{"burned_at": time.Now(), "expire_at": expireAt},
+		"$unset": bson.M{"content": "", "codec": "", "content_id": "", "chunks": "", "content_size": "", "stored_size": "", "pinned": ""},
+		"$inc":   bson.M{"revision": 1},
+	}
+	var burned struct {
+		ContentID string `bson:"content_id"`
+	}
+	opts := options.FindOneAndUpdate().SetProjection(bson.M{"content_id": 1})
+	err := s.rooms.FindOneAndUpdate(ctx, unburned(id), update, opts).Decode(&burned)
+	if err == mongo.ErrNoDocuments {
+		return s.burnedOrMissing(ctx, id)
+	}
+	if err != nil {
+		return err
+	}
+	s.deleteChunks(ctx, burned.ContentID)
+	return nil
+}
+
+func (s *MongoRoomStore) Expiring(ctx context.Context, before time.Time) ([]models.Room, error) {
+	filter := bson.M{
+		"expire_at": bson.M{"$gt": time.Now(), "$lte": before},
+		"burned_at": bson.M{"$exists": false},
+		"$expr":     bson.M{"$ne": bson.A{"$warned_for", "$expire_at"}},
+	}
+	cursor, err := s.rooms.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	rooms := []models.Room{}
+	if err := cursor.All(ctx, &rooms); err != nil {
+		return nil, err
+	}
+	for i := range rooms {
+		room, err := s.loadUpdated(ctx, &rooms[i])
+		if err != nil {
+			return nil, err
+		}
+		rooms[i] = *room
+	}
+	return rooms, nil
+}
+
+func (s *MongoRoomStore) ClaimWarning(ctx context.Context, id string, expireAt time.Time) (bool, error) {
+	filter := idFilter(id)
+	filter["expire_at"] = expireAt
+	filter["warned_for"] = bson.M{"$ne": expireAt}
+	result, err := s.rooms.UpdateOne(ctx, filter, revised(bson.M{"$set": bson.M{"warned_for": expireAt}}))
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+func (s *MongoRoomStore) Rename(ctx context.Context, id, slug string) error {
+	room, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if room.Slug == slug {
+		return nil
+	}
+
+	other, _, err := s.Resolve(ctx, slug)
+	switch {
+	case err == nil && other.ID != id:
+		return ErrSlugTaken
+	case err == nil:
+		// Renaming back to an old slug turns that alias into the slug again
+		if _, err := s.aliases.DeleteOne(ctx, bson.M{"_id": slug}); err != nil {
+			return err
+		}
+	case !errors.Is(err, ErrNotFound):
+		return err
+	}
+
+	// Record the alias before the old slug is released, so there is no
+	// moment where another room could claim it
+	_, err = s.aliases.UpdateOne(ctx,
+		bson.M{"_id": room.Slug},
+		bson.M{"$set": bson.M{"room_id": room.ID, "created_at": time.Now()}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return err
+	}
+
+	err = s.update(ctx, id, bson.M{"$set": bson.M{"slug": slug}})
+	if err != nil {
+		_, _ = s.aliases.DeleteOne(ctx, bson.M{"_id": room.Slug})
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrSlugTaken
+		}
+	}
+	return err
+}
+
+func (s *MongoRoomStore) Delete(ctx context.Context, id string) error {
+	var deleted struct {
+		ContentID string `bson:"content_id"`
+	}
+	opts := options.FindOneAndDelete().SetProjection(bson.M{"content_id": 1})
+	err := s.rooms.FindOneAndDelete(ctx, idFilter(id), opts).Decode(&deleted)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+	s.deleteChunks(ctx, deleted.ContentID)
+	_, err = s.aliases.DeleteMany(ctx, bson.M{"room_id": id})
+	return err
+}
+
+func (s *MongoRoomStore) Touch(ctx context.Context, id string, at time.Time) error {
+	return s.update(ctx, id, bson.M{"$max": bson.M{"active_at": at}})
+}
+
+func (s *MongoRoomStore) IDs(ctx context.Context) ([]string, error) {
+	// The TTL monitor only runs once a minute, so also filter on expire_at
+	filter := bson.M{"$or": bson.A{
+		bson.M{"expire_at": bson.M{"$gt": time.Now()}},
+		bson.M{"expire_at": bson.M{"$exists": false}},
+	}}
+	cursor, err := s.rooms.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+
+	var rooms []struct {
+		ID string `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &rooms); err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(rooms))
+	for i, r := range rooms {
+		ids[i] = r.ID
+	}
+	return ids, nil
+}
+
+func (s *MongoRoomStore) Live(ctx context.Context, ids []string) (map[string]bool, error) {
+	in := bson.A{}
+	for _, id := range ids {
+		in = append(in, id)
+		if oid, err := primitive.ObjectIDFromHex(id); err == nil {
+			in = append(in, oid)
+		}
+	}
+
+	// The TTL monitor only runs once a minute, so also filter on expire_at.
+	// Pinned rooms have none.
+	filter := bson.M{"_id": bson.M{"$in": in}, "$or": bson.A{
+		bson.M{"expire_at": bson.M{"$gt": time.Now()}},
+		bson.M{"expire_at": bson.M{"$exists": false}},
+	}}
+	cursor, err := s.rooms.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+
+	var live []struct {
+		ID string `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &live); err != nil {
+		return nil, err
+	}
+
+	alive := make(map[string]bool, len(live))
+	for _, r := range live {
+		alive[r.ID] = true
+	}
+	return alive, nil
+}
+
+// roomSortKeys orders rooms as sortAndPageRooms does. Rooms saved before
+// activity was recorded have no active_at and fall back to creation.
+var roomSortKeys = map[RoomSort][]string{
+	RoomSortActivity: {"active_at", "created_at"},
+	RoomSortExpiry:   {"pinned", "expire_at"},
+}
+
+func (s *MongoRoomStore) ListByOwner(ctx context.Context, owner string, opts ListRoomsOptions) ([]models.Room, int64, error) {
+	// The TTL monitor only runs once a minute, so also filter on expire_at
+	filter := bson.M{"owner": owner, "$or": bson.A{
+		bson.M{"expire_at": bson.M{"$gt": time.Now()}},
+		bson.M{"expire_at": bson.M{"$exists": false}},
+	}}
+
+	total, err := s.rooms.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	keys, ok := roomSortKeys[opts.Sort]
+	if !ok {
+		keys = roomSortKeys[RoomSortActivity]
+	}
+	sortDir := 1
+	if opts.Desc {
+		sortDir = -1
+	}
+	sort := bson.D{}
+	for _, key := range keys {
+		sort = append(sort, bson.E{Key: key, Value: sortDir})
+	}
+	// _id as a tie-breaker keeps pages stable when sort values are equal
+	sort = append(sort, bson.E{Key: "_id", Value: 1})
+
+	findOpts := options.Find().
+		SetSort(sort).
+		SetSkip(int64(opts.Skip)).
+		SetProjection(bson.M{"content": 0})
+	if opts.Limit > 0 {
+		findOpts.SetLimit(int64(opts.Limit))
+	}
+
+	cursor, err := s.rooms.Find(ctx, filter, findOpts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	rooms := []models.Room{}
+	if err := cursor.All(ctx, &rooms); err != nil {
+		return nil, 0, err
+	}
+	return rooms, total, nil
+}
+
+// MongoFileStore stores file metadata in the "files" collection
+type MongoFileStore struct {
+	files *mongo.Collection
+}
+
+func NewMongoFileStore(db *mongo.Database) *MongoFileStore {
+	return &MongoFileStore{files: db.Collection("files")}
+}
+
+var fileSortFields = map[FileSort]string{
+	FileSortName: "name",
+	FileSortSize: "size",
+	FileSortDate: "created_at",
+}
+
+func (s *MongoFileStore) Create(ctx context.Context, file *models.File) error {
+	_, err := s.files.InsertOne(ctx, file)
+	return err
+}
+
+func (s *MongoFileStore) Get(ctx context.Context, roomID, fileID string) (*models.File, error) {
+	var file models.File
+	err := s.files.FindOne(ctx, bson.M{"_id": fileID, "room_id": roomID}).Decode(&file)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+func (s *MongoFileStore) List(ctx context.Context, roomID string, opts ListFilesOptions) ([]models.File, int64, error) {
+	filter := bson.M{"room_id": roomID}
+
+	total, err := s.files.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sortField, ok := fileSortFields[opts.Sort]
+	if !ok {
+		sortField = fileSortFields[FileSortDate]
+	}
+	sortDir := 1
+	if opts.Desc {
+		sortDir = -1
+	}
+
+	// _id as a tie-breaker keeps pages stable when sort values are equal
+	findOpts := options.Find().
+		SetSort(bson.D{{Key: "pinned", Value: -1}, {Key: sortField, Value: sortDir}, {Key: "_id", Value: 1}}).
+		SetSkip(int64(opts.Skip))
+	if opts.Limit > 0 {
+		findOpts.SetLimit(int64(opts.Limit))
+	}
+
+	cursor, err := s.files.Find(ctx, filter, findOpts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	files := []models.File{}
+	if err := cursor.All(ctx, &files); err != nil {
+		return nil, 0, err
+	}
+	return files, total, nil
+}
+
+func (s *MongoFileStore) Update(ctx context.Context, roomID, fileID string, update FileUpdate) (*models.File, error) {
+	set := bson.M{}
+	if update.Name != nil {
+		set["name"] = *update.Name
+	}
+	if update.Description != nil {
+		set["description"] = *update.Description
+	}
+	if update.Pinned != nil {
+		set["pinned"] = *update.Pinned
+	}
+	if len(set) == 0 {
+		return s.Get(ctx, roomID, fileID)
+	}
+
+	var file models.File
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := s.files.FindOneAndUpdate(ctx, bson.M{"_id": fileID, "room_id": roomID}, bson.M{"$set": set}, opts).Decode(&file)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+func (s *MongoFileStore) Delete(ctx context.Context, roomID, fileID string) error {
+	_, err := s.files.DeleteOne(ctx, bson.M{"_id": fileID, "room_id": roomID})
+	return err
+}
+
+func (s *MongoFileStore) DeleteByRoom(ctx context.Context, roomID string) error {
+	_, err := s.files.DeleteMany(ctx, bson.M{"room_id": roomID})
+	return err
+}
+
+// MongoTemplateStore stores user templates in the "templates" collection
+type MongoTemplateStore struct {
+	templates *mongo.Collection
+}
+
+func NewMongoTemplateStore(db *mongo.Database) *MongoTemplateStore {
+	return &MongoTemplateStore{templates: db.Collection("templates")}
+}
+
+func (s *MongoTemplateStore) Create(ctx context.Context, t *models.Template) error {
+	if t.ID == "" {
+		t.ID = newRoomID()
+	}
+	_, err := s.templates.InsertOne(ctx, t)
+	return err
+}
+
+func (s *MongoTemplateStore) Get(ctx context.Context, id string) (*models.Template, error) {
+	var t models.Template
+	err := s.templates.FindOne(ctx, bson.M{"_id": id}).Decode(&t)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (s *MongoTemplateStore) List(ctx context.Context, owner string) ([]models.Template, error) {
+	cursor, err := s.templates.Find(ctx, bson.M{"owner": owner},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	templates := []models.Template{}
+	if err := cursor.All(ctx, &templates); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+func (s *MongoTemplateStore) Delete(ctx context.Context, id string) error {
+	_, err := s.templates.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
@@ -0,0 +1,305 @@
+package state
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pranavdhawale/notex/server/internal/models"
+)
+
+var (
+	ErrNotFound  = errors.New("not found")
+	ErrSlugTaken = errors.New("slug already taken")
+	ErrBurned    = errors.New("room already burned")
+	// ErrVersionMismatch is returned by conditional saves when the room
+	// content changed since the version the caller expected
+	ErrVersionMismatch = errors.New("room content changed")
+)
+
+// AnyVersion makes SaveContent overwrite the content whatever its version
+const AnyVersion int64 = -1
+
+// RoomStore persists rooms. A room has a stable ID and a public slug; after
+// a rename the old slug stays behind as an alias of the room.
+type RoomStore interface {
+	// Create inserts a room, assigning an ID if it has none. Returns
+	// ErrSlugTaken if the slug is in use by a room or an alias.
+	Create(ctx context.Context, room *models.Room) error
+	// Get returns the room with the given ID
+	Get(ctx context.Context, id string) (*models.Room, error)
+	// Resolve finds a room by its current slug or an alias, reporting
+	// whether the slug was an alias
+	Resolve(ctx context.Context, slug string) (*models.Room, bool, error)
+	// ResolveContent is Resolve for reading the content as a stream. The
+	// room is returned without its content, which is read from the store
+	// as the returned Content is.
+	ResolveContent(ctx context.Context, slug string) (*models.Room, bool, *Content, error)
+	// Exists reports whether a slug is used by a room or an alias
+	Exists(ctx context.Context, slug string) (bool, error)
+	// SaveContent replaces the room content and pushes back its expiry,
+	// returning the new content version. Unless version is AnyVersion it
+	// only saves over that version, and returns ErrVersionMismatch otherwise.
+	SaveContent(ctx context.Context, id string, content []byte, expireAt time.Time, version int64) (int64, error)
+	// SetExpiry moves the expiry of a room; a zero time removes it
+	SetExpiry(ctx context.Context, id string, expireAt time.Time) error
+	// Update changes the expiry settings of a room and returns it
+	Update(ctx context.Context, id string, update RoomUpdate) (*models.Room, error)
+	// RecordRead counts a read of a burn-after-reading room and returns it
+	// with the new count. Returns ErrBurned if the room is a tombstone.
+	RecordRead(ctx context.Context, id string) (*models.Room, error)
+	// Burn destroys the content of a room, leaving a tombstone that expires
+	// at expireAt. Returns ErrBurned if it already burned, so only one
+	// caller gets to clean up after it.
+	Burn(ctx context.Context, id string, expireAt time.Time) error
+	// Expiring lists live rooms that expire by before and have not been
+	// warned about their current expiry
+	Expiring(ctx context.Context, before time.Time) ([]models.Room, error)
+	// ClaimWarning records that the room is being warned about expiring at
+	// expireAt. It reports false if the expiry has moved or somebody
+	// already claimed the warning, so each expiry is warned about once.
+	ClaimWarning(ctx context.Context, id string, expireAt time.Time) (bool, error)
+	// Rename changes the slug of a room, keeping the old slug as an alias.
+	// Returns ErrSlugTaken if the new slug is used by another room.
+	Rename(ctx context.Context, id, slug string) error
+	// Delete removes a room together with its aliases
+	Delete(ctx context.Context, id string) error
+	// Touch records activity in a room at at. Activity never moves back.
+	Touch(ctx context.Context, id string, at time.Time) error
+	// IDs returns the IDs of all live rooms
+	IDs(ctx context.Context) ([]string, error)
+	// Live returns which of the given room IDs still exist and have not expired
+	Live(ctx context.Context, ids []string) (map[string]bool, error)
+	// ListByOwner returns a page of the live rooms of owner, tombstones
+	// included, and their total number. Content is left out.
+	ListByOwner(ctx context.Context, owner string, opts ListRoomsOptions) ([]models.Room, int64, error)
+}
+
+// TemplateStore persists user templates. They live apart from rooms and
+// never expire; built-in templates are loaded from disk instead.
+type TemplateStore interface {
+	// Create inserts a template, assigning an ID if it has none
+	Create(ctx context.Context, t *models.Template) error
+	Get(ctx context.Context, id string) (*models.Template, error)
+	// List returns the templates of an owner, newest first
+	List(ctx context.Context, owner string) ([]models.Template, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// RoomUpdate holds the room settings to change; nil fields are left as is
+type RoomUpdate struct {
+	TTL      *int64 // Seconds, 0 restores the server defaults
+	Pinned   *bool
+	ExpireAt *time.Time // Zero removes the expiry
+	Contact  *string    // Empty removes the contact address
+}
+
+// apply changes room in place, for the stores that keep whole records
+func (u RoomUpdate) apply(room *models.Room) {
+	if u.TTL != nil {
+		room.TTL = *u.TTL
+	}
+	if u.Pinned != nil {
+		room.Pinned = *u.Pinned
+	}
+	if u.ExpireAt != nil {
+		room.ExpireAt = *u.ExpireAt
+	}
+	if u.Contact != nil {
+		room.Contact = *u.Contact
+	}
+}
+
+// saveContent applies a conditional save to room in place, for the stores
+// that keep whole records
+func saveContent(room *models.Room, content []byte, now, expireAt time.Time, version int64, codec Codec) error {
+	if version != AnyVersion && version != room.Version {
+		return ErrVersionMismatch
+	}
+	packContent(room, content, codec)
+	room.ExpireAt = expireAt
+	room.ActiveAt = now
+	room.Version++
+	return nil
+}
+
+// expiring reports whether room should be warned about expiring by before,
+// for the stores that keep whole records
+func expiring(room *models.Room, before time.Time) bool {
+	return !room.ExpireAt.IsZero() && !room.ExpireAt.After(before) &&
+		!room.Burned() && !room.WarnedFor.Equal(room.ExpireAt)
+}
+
+// burn turns room into a tombstone, for the stores that keep whole records
+func burn(room *models.Room, now, expireAt time.Time) {
+	room.Content, room.Codec = nil, ""
+	room.ContentSize, room.StoredSize = 0, 0
+	room.BurnedAt = now
+	room.ExpireAt = expireAt
+	room.Pinned = false
+}
+
+// sortTemplates orders templates newest first
+func sortTemplates(templates []models.Template) {
+	sort.SliceStable(templates, func(i, j int) bool {
+		return templates[i].CreatedAt.After(templates[j].CreatedAt)
+	})
+}
+
+// newRoomID returns an ID for a room created without one
+func newRoomID() string {
+	return uuid.NewString()
+}
+
+type RoomSort string
+
+const (
+	RoomSortActivity RoomSort = "activity"
+	RoomSortExpiry   RoomSort = "expiry"
+)
+
+// ParseRoomSort validates a sort key from a query string
+func ParseRoomSort(s string) (RoomSort, bool) {
+	switch sort := RoomSort(s); sort {
+	case RoomSortActivity, RoomSortExpiry:
+		return sort, true
+	}
+	return "", false
+}
+
+// ListRoomsOptions controls ordering and paging of an owner's rooms.
+// Pinned rooms never expire, so they sort after every expiry.
+type ListRoomsOptions struct {
+	Sort  RoomSort
+	Desc  bool
+	Skip  int
+	Limit int // 0 means no limit
+}
+
+// sortAndPageRooms applies ListRoomsOptions in memory, matching the Mongo
+// ordering: by activity then creation, or by pinned then expiry, then ID.
+// Returns the page and the total number of rooms.
+func sortAndPageRooms(rooms []models.Room, opts ListRoomsOptions) ([]models.Room, int64) {
+	compare := func(a, b models.Room) int {
+		if opts.Sort == RoomSortExpiry {
+			if a.Pinned != b.Pinned {
+				if a.Pinned {
+					return 1
+				}
+				return -1
+			}
+			return a.ExpireAt.Compare(b.ExpireAt)
+		}
+		if c := a.ActiveAt.Compare(b.ActiveAt); c != 0 {
+			return c
+		}
+		return a.CreatedAt.Compare(b.CreatedAt)
+	}
+	sort.Slice(rooms, func(i, j int) bool {
+		a, b := rooms[i], rooms[j]
+		c := compare(a, b)
+		if opts.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c < 0
+		}
+		return a.ID < b.ID
+	})
+
+	total := int64(len(rooms))
+	if opts.Skip >= len(rooms) {
+		return []models.Room{}, total
+	}
+	rooms = rooms[opts.Skip:]
+	if opts.Limit > 0 && len(rooms) > opts.Limit {
+		rooms = rooms[:opts.Limit]
+	}
+	return rooms, total
+}
+
+// FileStore persists metadata of uploaded files
+type FileStore interface {
+	Create(ctx context.Context, file *models.File) error
+	Get(ctx context.Context, roomID, fileID string) (*models.File, error)
+	List(ctx context.Context, roomID string, opts ListFilesOptions) ([]models.File, int64, error)
+	Update(ctx context.Context, roomID, fileID string, update FileUpdate) (*models.File, error)
+	Delete(ctx context.Context, roomID, fileID string) error
+	DeleteByRoom(ctx context.Context, roomID string) error
+}
+
+type FileSort string
+
+const (
+	FileSortName FileSort = "name"
+	FileSortSize FileSort = "size"
+	FileSortDate FileSort = "date"
+)
+
+// ParseFileSort validates a sort key from a query string
+func ParseFileSort(s string) (FileSort, bool) {
+	switch sort := FileSort(s); sort {
+	case FileSortName, FileSortSize, FileSortDate:
+		return sort, true
+	}
+	return "", false
+}
+
+// ListFilesOptions controls ordering and paging. Pinned files always come first.
+type ListFilesOptions struct {
+	Sort  FileSort
+	Desc  bool
+	Skip  int
+	Limit int // 0 means no limit
+}
+
+// FileUpdate holds the metadata fields to change; nil fields are left as is
+type FileUpdate struct {
+	Name        *string
+	Description *string
+	Pinned      *bool
+}
+
+// sortAndPageFiles applies ListFilesOptions in memory, matching the Mongo
+// ordering: pinned first, then the sort key, then ID. Returns the page and
+// the total number of files.
+func sortAndPageFiles(files []models.File, opts ListFilesOptions) ([]models.File, int64) {
+	compare := func(a, b models.File) int {
+		switch opts.Sort {
+		case FileSortName:
+			return strings.Compare(a.Name, b.Name)
+		case FileSortSize:
+			return cmp.Compare(a.Size, b.Size)
+		default:
+			return a.CreatedAt.Compare(b.CreatedAt)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+		if a.Pinned != b.Pinned {
+			return a.Pinned
+		}
+		c := compare(a, b)
+		if opts.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c < 0
+		}
+		return a.ID < b.ID
+	})
+
+	total := int64(len(files))
+	if opts.Skip >= len(files) {
+		return []models.File{}, total
+	}
+	files = files[opts.Skip:]
+	if opts.Limit > 0 && len(files) > opts.Limit {
+		files = files[:opts.Limit]
+	}
+	return files, total
+}
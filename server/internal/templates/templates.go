@@ -0,0 +1,111 @@
+// Package templates loads the built-in room templates and renders
+// templates into room content.
+package templates
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pranavdhawale/notex/server/internal/models"
+	"github.com/pranavdhawale/notex/server/internal/yjs"
+)
+
+//go:embed builtin/*.json
+var embedded embed.FS
+
+// Load reads the built-in templates, one JSON file per template named after
+// its ID, from dir on disk when set, otherwise from the copies embedded in
+// the binary
+func Load(dir string) ([]models.Template, error) {
+	var files fs.FS
+	if dir != "" {
+		files = os.DirFS(dir)
+	} else {
+		sub, err := fs.Sub(embedded, "builtin")
+		if err != nil {
+			return nil, err
+		}
+		files = sub
+	}
+
+	names, err := fs.Glob(files, "*.json")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	templates := make([]models.Template, 0, len(names))
+	for _, name := range names {
+		data, err := fs.ReadFile(files, name)
+		if err != nil {
+			return nil, err
+		}
+		var t models.Template
+		if err := json.Unmarshal(data, &t); err != nil {
+			return nil, fmt.Errorf("template %s: %w", name, err)
+		}
+		t.ID = strings.TrimSuffix(path.Base(name), ".json")
+		t.BuiltIn = true
+		t.Owner = ""
+		if t.Name == "" || t.Doc.Type != "doc" {
+			return nil, fmt.Errorf("template %s: needs a name and a doc", name)
+		}
+		templates = append(templates, t)
+	}
+	return templates, nil
+}
+
+// Vars returns the placeholder values for a room created now
+func Vars(slug string, now time.Time) map[string]string {
+	return map[string]string{
+		"date": now.Format("2006-01-02"),
+		"time": now.Format("15:04"),
+		"slug": slug,
+	}
+}
+
+// Render fills in the placeholders of a template and encodes it as room
+// content: a Yjs update, the same form the editor saves
+func Render(t *models.Template, vars map[string]string) ([]byte, error) {
+	doc := substitute(t.Doc, placeholders(vars))
+	return yjs.EncodeProseMirror(doc, yjs.DefaultFragment, rand.Uint32())
+}
+
+func placeholders(vars map[string]string) *strings.Replacer {
+	pairs := make([]string, 0, 2*len(vars))
+	for k, v := range vars {
+		pairs = append(pairs, "{{"+k+"}}", v)
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// substitute returns a copy of node with placeholders replaced in text and
+// string attributes
+func substitute(node models.DocNode, r *strings.Replacer) models.DocNode {
+	out := node
+	out.Text = r.Replace(node.Text)
+	if node.Attrs != nil {
+		out.Attrs = make(map[string]interface{}, len(node.Attrs))
+		for k, v := range node.Attrs {
+			if s, ok := v.(string); ok {
+				v = r.Replace(s)
+			}
+			out.Attrs[k] = v
+		}
+	}
+	if node.Content != nil {
+		out.Content = make([]models.DocNode, len(node.Content))
+		for i, child := range node.Content {
+			out.Content[i] = substitute(child, r)
+		}
+	}
+	return out
+}
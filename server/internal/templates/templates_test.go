@@ -0,0 +1,78 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pranavdhawale/notex/server/internal/models"
+	"github.com/pranavdhawale/notex/server/internal/yjs"
+)
+
+func TestLoadEmbedded(t *testing.T) {
+	list, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := map[string]bool{}
+	for _, tmpl := range list {
+		if !tmpl.BuiltIn || tmpl.Name == "" {
+			t.Errorf("unexpected template %+v", tmpl)
+		}
+		ids[tmpl.ID] = true
+	}
+	for _, id := range []string{"meeting-notes", "retro", "checklist"} {
+		if !ids[id] {
+			t.Errorf("missing built-in template %s", id)
+		}
+	}
+
+	for _, tmpl := range list {
+		update, err := Render(&tmpl, Vars("cosmic-whale", time.Now()))
+		if err != nil {
+			t.Errorf("render %s: %v", tmpl.ID, err)
+		} else if err := yjs.ValidateUpdate(update); err != nil {
+			t.Errorf("render %s: %v", tmpl.ID, err)
+		}
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "todo.json"), []byte(`{"name":"To do","doc":{"type":"doc"}}`), 0o644)
+	list, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].ID != "todo" {
+		t.Errorf("unexpected templates %+v", list)
+	}
+
+	os.WriteFile(filepath.Join(dir, "broken.json"), []byte(`{"doc":{"type":"doc"}}`), 0o644)
+	if _, err := Load(dir); err == nil {
+		t.Error("expected an error for a template without a name")
+	}
+}
+
+func TestSubstitute(t *testing.T) {
+	doc := models.DocNode{Type: "doc", Content: []models.DocNode{
+		{Type: "heading", Attrs: map[string]interface{}{"level": 1.0, "id": "{{slug}}"}, Content: []models.DocNode{
+			{Type: "text", Text: "Notes {{date}} for {{slug}} {{unknown}}"},
+		}},
+	}}
+	vars := Vars("cosmic-whale", time.Date(2026, 3, 4, 9, 5, 0, 0, time.UTC))
+	out := substitute(doc, placeholders(vars))
+
+	heading := out.Content[0]
+	if got := heading.Content[0].Text; got != "Notes 2026-03-04 for cosmic-whale {{unknown}}" {
+		t.Errorf("text: got %q", got)
+	}
+	if heading.Attrs["id"] != "cosmic-whale" || heading.Attrs["level"] != 1.0 {
+		t.Errorf("attrs: got %v", heading.Attrs)
+	}
+	if !strings.Contains(doc.Content[0].Content[0].Text, "{{date}}") {
+		t.Error("substitute modified its input")
+	}
+}
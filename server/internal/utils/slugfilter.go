@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrSlugReserved = errors.New("slug is reserved")
+	ErrSlugBlocked  = errors.New("slug contains a blocked word")
+)
+
+// ReservedSlugs are names that collide with server and client routes
+// (/api, /ws, /uploads, /health, Vite's /assets) or would be confusing
+// as room names
+var ReservedSlugs = []string{
+	"api", "ws", "uploads", "health", "assets", "static", "index", "favicon",
+	"robots", "manifest", "sitemap", "admin", "root", "system", "notex",
+	"login", "logout", "signup", "settings", "help", "about", "new", "null",
+	"undefined",
+}
+
+// DefaultBlockedWords are rejected in custom and generated slugs, after
+// leetspeak normalization. Words are matched exactly, so "spices" and
+// "cockers" pass; inflections to block are listed in DefaultBlockedVariants.
+var DefaultBlockedWords = []string{
+	"anal", "anus", "bitch", "bastard", "cock", "cunt", "dick", "dildo",
+	"fag", "faggot", "fuck", "hitler", "jizz", "kike", "nazi", "nigga",
+	"nigger", "penis", "porn", "pussy", "rape", "rapist", "retard", "shit",
+	"slut", "spic", "tits", "twat", "vagina", "wank", "whore",
+}
+
+// DefaultBlockedVariants are the inflections of DefaultBlockedWords that
+// are blocked too
+var DefaultBlockedVariants = []string{
+	"bastards", "bitches", "bitching", "bitchy", "cocks", "cunts", "dicks",
+	"dildos", "fags", "faggots", "fucked", "fucker", "fuckers", "fucking",
+	"fucks", "nazis", "niggas", "niggers", "porno", "pussies", "raped",
+	"rapes", "raping", "rapists", "retarded", "retards", "shits", "shitty",
+	"sluts", "slutty", "twats", "wanker", "wankers", "wanking", "whores",
+}
+
+// leetReplacer maps common digit substitutions back to letters
+var leetReplacer = strings.NewReplacer(
+	"0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "8", "b", "9", "g",
+)
+
+// SlugFilter rejects reserved and blocked slugs
+type SlugFilter struct {
+	reserved map[string]bool
+	blocked  map[string]bool
+}
+
+// NewSlugFilter builds a filter from the built-in lists plus extra words.
+// Extra blocked words are matched exactly too.
+func NewSlugFilter(extraReserved, extraBlocked []string) *SlugFilter {
+	f := &SlugFilter{reserved: make(map[string]bool), blocked: make(map[string]bool)}
+	for _, w := range append(append([]string{}, ReservedSlugs...), extraReserved...) {
+		f.reserved[strings.ToLower(strings.TrimSpace(w))] = true
+	}
+	for _, list := range [][]string{DefaultBlockedWords, DefaultBlockedVariants, extraBlocked} {
+		for _, w := range list {
+			if w = normalizeSlugWord(w); w != "" {
+				f.blocked[w] = true
+			}
+		}
+	}
+	return f
+}
+
+// Check returns ErrSlugReserved or ErrSlugBlocked if the slug may not be used
+func (f *SlugFilter) Check(slug string) error {
+	if f.reserved[slug] {
+		return ErrSlugReserved
+	}
+
+	words := strings.Split(slug, "-")
+	for i, w := range words {
+		words[i] = normalizeSlugWord(w)
+	}
+
+	// Whole words plus runs of adjacent words, catching "sh-it"
+	for i := range words {
+		joined := ""
+		for _, w := range words[i:] {
+			joined += w
+			if f.blocked[joined] {
+				return ErrSlugBlocked
+			}
+		}
+	}
+	return nil
+}
+
+// normalizeSlugWord undoes leetspeak and squeezes letters repeated three or
+// more times, so "sh1iiit" and "shit" compare equal
+func normalizeSlugWord(w string) string {
+	w = leetReplacer.Replace(strings.ToLower(strings.TrimSpace(w)))
+
+	var letters []rune
+	for _, r := range w {
+		if r >= 'a' && r <= 'z' {
+			letters = append(letters, r)
+		}
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(letters); {
+		j := i
+		for j < len(letters) && letters[j] == letters[i] {
+			j++
+		}
+		if j-i >= 3 {
+			b.WriteRune(letters[i])
+		} else {
+			b.WriteString(string(letters[i:j]))
+		}
+		i = j
+	}
+	return b.String()
+}
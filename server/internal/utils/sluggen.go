@@ -3,14 +3,14 @@ package utils
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log"
 	"math/rand"
 	"regexp"
 	"strings"
 	"time"
 
 	petname "github.com/dustinkirkland/golang-petname"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 func init() {
@@ -25,64 +25,214 @@ func GenerateSlug() string {
 	return petname.Generate(2, "-")
 }
 
-// GenerateUniqueSlug generates a unique slug with collision detection
-// Retries up to maxAttempts times before returning an error
-func GenerateUniqueSlug(ctx context.Context, collection *mongo.Collection) (string, error) {
-	maxAttempts := 10
+// ErrSlugSpaceExhausted is returned when every generated slug was taken
+var ErrSlugSpaceExhausted = errors.New("no free slug found")
 
-	for i := 0; i < maxAttempts; i++ {
-		slug := GenerateSlug()
+// ClaimFunc tries to take a slug (e.g. by inserting a room with it),
+// reporting false if another room already has it
+type ClaimFunc func(ctx context.Context, slug string) (bool, error)
 
-		// Check if slug already exists
-		count, err := collection.CountDocuments(ctx, bson.M{"slug": slug})
+// SlugStrategy produces candidate slugs of one shape
+type SlugStrategy struct {
+	Name     string
+	Generate func() string
+}
+
+// Built-in strategy names. Themed word lists are selected with
+// "theme:<name>", e.g. "theme:space".
+const (
+	StrategyPetname2    = "petname2"    // cosmic-whale
+	StrategyPetname3    = "petname3"    // quickly-cosmic-whale
+	StrategyWordNumber  = "word-number" // cosmic-whale482
+	strategyThemePrefix = "theme:"
+)
+
+// SlugStrategyByName looks up a built-in strategy
+func SlugStrategyByName(name string) (SlugStrategy, error) {
+	switch name {
+	case StrategyPetname2:
+		return SlugStrategy{Name: name, Generate: GenerateSlug}, nil
+	case StrategyPetname3:
+		return SlugStrategy{Name: name, Generate: func() string { return petname.Generate(3, "-") }}, nil
+	case StrategyWordNumber:
+		return SlugStrategy{Name: name, Generate: func() string {
+			return fmt.Sprintf("%s%d", petname.Generate(2, "-"), 100+rand.Intn(900))
+		}}, nil
+	}
+
+	if theme, ok := strings.CutPrefix(name, strategyThemePrefix); ok {
+		nouns, ok := themeWords[theme]
+		if !ok {
+			return SlugStrategy{}, fmt.Errorf("unknown slug theme %q", theme)
+		}
+		return SlugStrategy{Name: name, Generate: func() string {
+			return petname.Adjective() + "-" + nouns[rand.Intn(len(nouns))]
+		}}, nil
+	}
+
+	return SlugStrategy{}, fmt.Errorf("unknown slug strategy %q", name)
+}
+
+// SlugGenerator claims generated slugs, moving on to the next strategy
+// after attemptsPerStrategy collisions in a row.
+// Custom slugs may take any generated shape (v1 accepts the two-word ones,
+// v2 all of them), so generated and custom slugs share one namespace.
+// They cannot collide because both are claimed by inserting the room,
+// which the store refuses for a slug already in use.
+type SlugGenerator struct {
+	strategies          []SlugStrategy
+	attemptsPerStrategy int
+	filter              *SlugFilter
+}
+
+// NewSlugGenerator builds a generator from strategy names, tried in order.
+// Candidates rejected by filter count as collisions; filter may be nil.
+func NewSlugGenerator(names []string, attemptsPerStrategy int, filter *SlugFilter) (*SlugGenerator, error) {
+	if len(names) == 0 {
+		return nil, errors.New("at least one slug strategy is required")
+	}
+	if attemptsPerStrategy <= 0 {
+		return nil, errors.New("attempts per slug strategy must be positive")
+	}
+
+	g := &SlugGenerator{attemptsPerStrategy: attemptsPerStrategy, filter: filter}
+	for _, name := range names {
+		strategy, err := SlugStrategyByName(name)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
+		g.strategies = append(g.strategies, strategy)
+	}
+	return g, nil
+}
 
-		// If slug is unique, return it
-		if count == 0 {
-			return slug, nil
+// Generate claims the first free slug, escalating through the strategies.
+// Returns ErrSlugSpaceExhausted if every attempt collided.
+func (g *SlugGenerator) Generate(ctx context.Context, claim ClaimFunc) (string, error) {
+	for _, strategy := range g.strategies {
+		for i := 0; i < g.attemptsPerStrategy; i++ {
+			slug := strategy.Generate()
+			if g.filter != nil && g.filter.Check(slug) != nil {
+				continue
+			}
+
+			claimed, err := claim(ctx, slug)
+			if err != nil {
+				return "", err
+			}
+
+			if claimed {
+				return slug, nil
+			}
 		}
+		log.Printf("Slug strategy %s collided %d times, escalating", strategy.Name, g.attemptsPerStrategy)
 	}
 
-	// If we've exhausted all attempts, return error
-	// This should be extremely rare with ~10,000 combinations
-	return "", mongo.ErrNoDocuments
+	return "", fmt.Errorf("%w after %d attempts", ErrSlugSpaceExhausted, len(g.strategies)*g.attemptsPerStrategy)
+}
+
+// SlugRules describes which custom slugs are accepted. Rules are versioned
+// so a deployment can opt into looser rules without changing the defaults.
+type SlugRules struct {
+	Version       string
+	MaxLength     int
+	MaxWords      int
+	MinWordLength int
+}
+
+var (
+	// SlugRulesV1 accepts one or two words, as notex always has
+	SlugRulesV1 = SlugRules{Version: "v1", MaxLength: 50, MaxWords: 2, MinWordLength: 2}
+	// SlugRulesV2 accepts up to four words, e.g. team-alpha-weekly-sync
+	SlugRulesV2 = SlugRules{Version: "v2", MaxLength: 63, MaxWords: 4, MinWordLength: 2}
+)
+
+// SlugRulesFor returns the rules for a version name
+func SlugRulesFor(version string) (SlugRules, error) {
+	switch version {
+	case SlugRulesV1.Version:
+		return SlugRulesV1, nil
+	case SlugRulesV2.Version:
+		return SlugRulesV2, nil
+	}
+	return SlugRules{}, fmt.Errorf("unknown slug rules version %q", version)
 }
 
-// ValidateCustomSlug validates user-provided slug
-// Returns error if invalid
-func ValidateCustomSlug(slug string) error {
+var slugPattern = regexp.MustCompile("^[a-z0-9]+(-[a-z0-9]+)*$")
+
+// Validate checks a user-provided slug against the rules
+func (r SlugRules) Validate(slug string) error {
 	// Check empty
 	if slug == "" {
 		return errors.New("slug cannot be empty")
 	}
 
 	// Check length
-	if len(slug) > 50 {
-		return errors.New("slug too long (max 50 characters)")
+	if len(slug) > r.MaxLength {
+		return fmt.Errorf("slug too long (max %d characters)", r.MaxLength)
 	}
 
-	// Check format: lowercase alphanumeric + hyphens
-	// Pattern: word or word-word (no leading/trailing hyphens, no consecutive hyphens)
-	matched, _ := regexp.MatchString("^[a-z0-9]+(-[a-z0-9]+)?$", slug)
-	if !matched {
-		return errors.New("slug must be lowercase alphanumeric with optional single hyphen")
+	// Check format: lowercase alphanumeric words joined by single hyphens
+	// (no leading/trailing hyphens, no consecutive hyphens)
+	if !slugPattern.MatchString(slug) {
+		return errors.New("slug must be lowercase alphanumeric words separated by single hyphens")
 	}
 
 	// Count words (split by hyphen)
 	words := strings.Split(slug, "-")
-	if len(words) > 2 {
-		return errors.New("slug can have maximum 2 words")
+	if len(words) > r.MaxWords {
+		return fmt.Errorf("slug can have maximum %d words", r.MaxWords)
 	}
 
-	// Check minimum word length (at least 2 characters per word)
+	// Check minimum word length
 	for _, word := range words {
-		if len(word) < 2 {
-			return errors.New("each word must be at least 2 characters")
+		if len(word) < r.MinWordLength {
+			return fmt.Errorf("each word must be at least %d characters", r.MinWordLength)
 		}
 	}
 
 	return nil
 }
 
+var slugWordSeparator = regexp.MustCompile("[^a-z0-9]+")
+
+// SlugCandidates returns alternatives close to a wanted slug, most similar
+// first: numbered variants, swapped word order, then petname variations.
+// Candidates are not validated or checked for availability.
+func SlugCandidates(slug string) []string {
+	var words []string
+	for _, w := range slugWordSeparator.Split(strings.ToLower(slug), -1) {
+		if w != "" {
+			words = append(words, w)
+		}
+	}
+	if len(words) == 0 {
+		return nil
+	}
+
+	base := strings.Join(words, "-")
+	first, last := words[0], words[len(words)-1]
+
+	var out []string
+	for n := 2; n <= 5; n++ {
+		out = append(out, fmt.Sprintf("%s%d", base, n))
+	}
+	if len(words) == 2 {
+		out = append(out, last+"-"+first)
+	}
+	for i := 0; i < 3; i++ {
+		out = append(out, petname.Adjective()+"-"+last, first+"-"+petname.Name())
+	}
+	out = append(out, fmt.Sprintf("%s%d", base, 10+rand.Intn(90)))
+
+	// Drop duplicates and the slug itself
+	seen := map[string]bool{slug: true}
+	candidates := out[:0]
+	for _, c := range out {
+		if !seen[c] {
+			seen[c] = true
+			candidates = append(candidates, c)
+		}
+	}
+	return candidates
+}
@@ -1,6 +1,10 @@
 package utils
 
 import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
 	"testing"
 )
 
@@ -50,8 +54,7 @@ func TestGenerateSlugUniqueness(t *testing.T) {
 	}
 }
 
-// New validation tests
-func TestValidateCustomSlug(t *testing.T) {
+func TestSlugRulesV1(t *testing.T) {
 	tests := []struct {
 		name    string
 		slug    string
@@ -79,9 +82,9 @@ func TestValidateCustomSlug(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := ValidateCustomSlug(tt.slug)
+			err := SlugRulesV1.Validate(tt.slug)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("ValidateCustomSlug(%q) error = %v, wantErr %v", tt.slug, err, tt.wantErr)
+				t.Errorf("SlugRulesV1.Validate(%q) error = %v, wantErr %v", tt.slug, err, tt.wantErr)
 			}
 			if err != nil {
 				t.Logf("Validation error for %q: %v", tt.slug, err)
@@ -89,3 +92,153 @@ func TestValidateCustomSlug(t *testing.T) {
 		})
 	}
 }
+
+func TestSlugGeneratorEscalates(t *testing.T) {
+	g, err := NewSlugGenerator([]string{StrategyPetname2, StrategyWordNumber, StrategyPetname3}, 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every 2-word and word-number slug is taken, only 3 words are free
+	var tried []string
+	slug, err := g.Generate(context.Background(), func(ctx context.Context, slug string) (bool, error) {
+		tried = append(tried, slug)
+		return strings.Count(slug, "-") == 2, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tried) != 7 {
+		t.Errorf("expected 3 attempts per strategy before petname3, got %d: %v", len(tried), tried)
+	}
+	if strings.Count(slug, "-") != 2 {
+		t.Errorf("expected a 3-word slug, got %q", slug)
+	}
+	for _, s := range tried[3:6] {
+		if !regexp.MustCompile(`^[a-z]+-[a-z]+[0-9]{3}$`).MatchString(s) {
+			t.Errorf("expected word-number slug, got %q", s)
+		}
+	}
+
+	_, err = g.Generate(context.Background(), func(ctx context.Context, slug string) (bool, error) {
+		return false, nil
+	})
+	if !errors.Is(err, ErrSlugSpaceExhausted) {
+		t.Errorf("expected ErrSlugSpaceExhausted, got %v", err)
+	}
+
+	// Store errors are passed through
+	boom := errors.New("boom")
+	_, err = g.Generate(context.Background(), func(ctx context.Context, slug string) (bool, error) {
+		return false, boom
+	})
+	if !errors.Is(err, boom) {
+		t.Errorf("expected claim error, got %v", err)
+	}
+}
+
+func TestSlugStrategyByName(t *testing.T) {
+	for _, name := range []string{"petname2", "petname3", "word-number", "theme:space", "theme:ocean", "theme:forest"} {
+		s, err := SlugStrategyByName(name)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if slug := s.Generate(); SlugRulesV2.Validate(slug) != nil {
+			t.Errorf("%s generated invalid slug %q", name, slug)
+		}
+	}
+
+	for _, name := range []string{"", "petname4", "theme:desert"} {
+		if _, err := SlugStrategyByName(name); err == nil {
+			t.Errorf("expected error for strategy %q", name)
+		}
+	}
+}
+
+func TestSlugRulesV2(t *testing.T) {
+	tests := []struct {
+		slug    string
+		wantErr bool
+	}{
+		{"my-team-room", false},
+		{"team-alpha-weekly-sync", false},
+		{"one-two-three-four-five", true},
+		{"my--room", true},
+		{"a-team", true},
+	}
+
+	for _, tt := range tests {
+		err := SlugRulesV2.Validate(tt.slug)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SlugRulesV2.Validate(%q) error = %v, wantErr %v", tt.slug, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSlugFilter(t *testing.T) {
+	f := NewSlugFilter([]string{"billing"}, []string{"frak"})
+
+	tests := []struct {
+		name string
+		slug string
+		want error
+	}{
+		// Allowed
+		{"petname", "cosmic-whale", nil},
+		{"contains reserved word", "api-docs", nil},
+		{"embedded blocked word", "therapist-notes", nil},
+		{"blocked word inside longer word", "classic-cockatoo", nil},
+		{"double letters kept", "happy-kitten", nil},
+		{"blocked word plus es", "spices", nil},
+		{"blocked word plus ers", "cockers", nil},
+		{"blocked word plus ing", "cocking-hammer", nil},
+
+		// Reserved
+		{"api", "api", ErrSlugReserved},
+		{"ws", "ws", ErrSlugReserved},
+		{"uploads", "uploads", ErrSlugReserved},
+		{"health", "health", ErrSlugReserved},
+		{"assets", "assets", ErrSlugReserved},
+		{"admin", "admin", ErrSlugReserved},
+		{"configured reserved", "billing", ErrSlugReserved},
+
+		// Blocked
+		{"whole word", "shit", ErrSlugBlocked},
+		{"second word", "cosmic-shit", ErrSlugBlocked},
+		{"leetspeak", "sh1t-happens", ErrSlugBlocked},
+		{"leetspeak digits", "5h17", ErrSlugBlocked},
+		{"stretched", "shiiiit", ErrSlugBlocked},
+		{"split across words", "sh-it", ErrSlugBlocked},
+		{"listed variant", "shitty-room", ErrSlugBlocked},
+		{"listed plural", "fuckers", ErrSlugBlocked},
+		{"listed variant leetspeak", "fuck3rs", ErrSlugBlocked},
+		{"configured blocklist", "frak-this", ErrSlugBlocked},
+		{"configured blocklist leet", "fr4k", ErrSlugBlocked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := f.Check(tt.slug); !errors.Is(err, tt.want) {
+				t.Errorf("Check(%q) = %v, want %v", tt.slug, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlugGeneratorSkipsFilteredSlugs(t *testing.T) {
+	g, err := NewSlugGenerator([]string{StrategyPetname2}, 50, NewSlugFilter(nil, []string{"blocked"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.strategies[0].Generate = func() string { return "blocked-whale" }
+
+	claims := 0
+	_, err = g.Generate(context.Background(), func(ctx context.Context, slug string) (bool, error) {
+		claims++
+		return true, nil
+	})
+	if !errors.Is(err, ErrSlugSpaceExhausted) || claims != 0 {
+		t.Errorf("expected filtered slugs never to be claimed, got %d claims (err %v)", claims, err)
+	}
+}
@@ -0,0 +1,21 @@
+package utils
+
+// themeWords are the nouns used by the "theme:<name>" slug strategies,
+// paired with a petname adjective (e.g. brave-nebula)
+var themeWords = map[string][]string{
+	"space": {
+		"asteroid", "aurora", "comet", "cosmos", "eclipse", "galaxy", "lander",
+		"meteor", "moon", "nebula", "nova", "orbit", "planet", "pulsar", "quasar",
+		"rocket", "satellite", "star", "sun", "supernova", "telescope", "zenith",
+	},
+	"ocean": {
+		"anchor", "atoll", "barnacle", "coral", "current", "dolphin", "harbor",
+		"kelp", "lagoon", "lighthouse", "manta", "narwhal", "octopus", "orca",
+		"pearl", "reef", "seal", "shell", "starfish", "tide", "urchin", "wave",
+	},
+	"forest": {
+		"acorn", "aspen", "badger", "birch", "bramble", "cedar", "clearing",
+		"fern", "grove", "hazel", "lichen", "maple", "meadow", "moss", "oak",
+		"owl", "pine", "sapling", "spruce", "thicket", "willow", "wren",
+	},
+}
@@ -0,0 +1,168 @@
+package web
+
+import (
+	"embed"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The Vite build output, copied here by scripts/embed-client.sh.
+// Only .gitkeep is present in a fresh checkout.
+//
+//go:embed all:dist
+var embedded embed.FS
+
+// Files returns the client build to serve: dir on disk when set, otherwise
+// the copy embedded in the binary.
+func Files(dir string) (fs.FS, error) {
+	var files fs.FS
+	if dir != "" {
+		files = os.DirFS(dir)
+	} else {
+		sub, err := fs.Sub(embedded, "dist")
+		if err != nil {
+			return nil, err
+		}
+		files = sub
+	}
+
+	if _, err := fs.Stat(files, "index.html"); err != nil {
+		if dir == "" {
+			return nil, errors.New("client build not embedded (run scripts/embed-client.sh before go build)")
+		}
+		return nil, err
+	}
+	return files, nil
+}
+
+// SPA serves the client with index.html as the fallback for client-side
+// routes (e.g. /cosmic-whale), long-lived caching for Vite's hashed assets,
+// and precompressed .br/.gz variants when the browser accepts them.
+type SPA struct {
+	files fs.FS
+
+	// Server route prefixes that must 404 instead of falling back to index.html
+	reserved []string
+}
+
+func NewSPA(files fs.FS, reservedPrefixes ...string) *SPA {
+	return &SPA{files: files, reserved: reservedPrefixes}
+}
+
+// Precompressed variants, in order of preference
+var encodings = []struct {
+	name string
+	ext  string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// Handle is meant to be installed as the router's NoRoute handler
+func (s *SPA) Handle(c *gin.Context) {
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+
+	urlPath := path.Clean("/" + c.Request.URL.Path)
+	for _, prefix := range s.reserved {
+		if urlPath == prefix || strings.HasPrefix(urlPath, prefix+"/") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+	}
+
+	name := strings.TrimPrefix(urlPath, "/")
+	if name == "" || !s.isFile(name) {
+		// Paths that look like files are real misses, not client routes
+		if path.Ext(name) != "" {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		name = "index.html"
+	}
+
+	switch {
+	case name == "index.html":
+		// Always revalidate so new deploys pick up new asset hashes
+		c.Header("Cache-Control", "no-cache")
+	case strings.HasPrefix(name, "assets/"):
+		// Vite puts a content hash in every asset filename
+		c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	default:
+		c.Header("Cache-Control", "public, max-age=3600")
+	}
+
+	s.serve(c, name)
+}
+
+func (s *SPA) isFile(name string) bool {
+	info, err := fs.Stat(s.files, name)
+	return err == nil && !info.IsDir()
+}
+
+func (s *SPA) serve(c *gin.Context, name string) {
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.Header("Vary", "Accept-Encoding")
+
+	accepted := c.GetHeader("Accept-Encoding")
+	for _, enc := range encodings {
+		if !acceptsEncoding(accepted, enc.name) || !s.isFile(name+enc.ext) {
+			continue
+		}
+		c.Header("Content-Encoding", enc.name)
+		s.serveFile(c, name+enc.ext, contentType)
+		return
+	}
+
+	s.serveFile(c, name, contentType)
+}
+
+func (s *SPA) serveFile(c *gin.Context, name, contentType string) {
+	f, err := s.files.Open(name)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.Header("Content-Type", contentType)
+	if rs, ok := f.(io.ReadSeeker); ok {
+		http.ServeContent(c.Writer, c.Request, name, info.ModTime(), rs)
+		return
+	}
+
+	c.DataFromReader(http.StatusOK, info.Size(), contentType, f, nil)
+}
+
+// acceptsEncoding reports whether an Accept-Encoding header allows enc
+func acceptsEncoding(header, enc string) bool {
+	for _, part := range strings.Split(header, ",") {
+		token, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(token), enc) {
+			continue
+		}
+		// "br;q=0" explicitly refuses the encoding
+		q := strings.ReplaceAll(strings.TrimSpace(params), " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	files := fstest.MapFS{
+		"index.html":             {Data: []byte("<html>app</html>")},
+		"notex.svg":              {Data: []byte("<svg/>")},
+		"assets/index-abc.js":    {Data: []byte("console.log(1)")},
+		"assets/index-abc.js.br": {Data: []byte("brotli")},
+		"assets/index-abc.js.gz": {Data: []byte("gzip")},
+	}
+	r := gin.New()
+	r.GET("/api/rooms/:room", func(c *gin.Context) { c.String(http.StatusOK, "room") })
+	r.NoRoute(NewSPA(files, "/api", "/ws", "/uploads").Handle)
+	return r
+}
+
+func TestSPA(t *testing.T) {
+	tests := []struct {
+		name         string
+		path         string
+		encoding     string
+		wantStatus   int
+		wantBody     string
+		wantCache    string
+		wantEncoding string
+	}{
+		{"root", "/", "", http.StatusOK, "<html>app</html>", "no-cache", ""},
+		{"client route falls back", "/cosmic-whale", "", http.StatusOK, "<html>app</html>", "no-cache", ""},
+		{"hashed asset", "/assets/index-abc.js", "", http.StatusOK, "console.log(1)", "public, max-age=31536000, immutable", ""},
+		{"prefers brotli", "/assets/index-abc.js", "gzip, deflate, br", http.StatusOK, "brotli", "public, max-age=31536000, immutable", "br"},
+		{"gzip only", "/assets/index-abc.js", "gzip", http.StatusOK, "gzip", "public, max-age=31536000, immutable", "gzip"},
+		{"refused brotli", "/assets/index-abc.js", "br;q=0, gzip", http.StatusOK, "gzip", "public, max-age=31536000, immutable", "gzip"},
+		{"public file", "/notex.svg", "", http.StatusOK, "<svg/>", "public, max-age=3600", ""},
+		{"missing asset", "/assets/gone.js", "", http.StatusNotFound, "", "", ""},
+		{"unknown api route", "/api/nope", "", http.StatusNotFound, "", "", ""},
+		{"uploads miss", "/uploads/room/file.png", "", http.StatusNotFound, "", "", ""},
+		{"api route", "/api/rooms/x", "", http.StatusOK, "room", "", ""},
+	}
+
+	r := newTestRouter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.encoding != "" {
+				req.Header.Set("Accept-Encoding", tt.encoding)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("expected body %q, got %q", tt.wantBody, w.Body.String())
+			}
+			if got := w.Header().Get("Cache-Control"); got != tt.wantCache {
+				t.Errorf("expected Cache-Control %q, got %q", tt.wantCache, got)
+			}
+			if got := w.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Errorf("expected Content-Encoding %q, got %q", tt.wantEncoding, got)
+			}
+			if tt.wantEncoding != "" && w.Header().Get("Content-Type") != "text/javascript; charset=utf-8" {
+				t.Errorf("precompressed asset should keep its own type, got %q", w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
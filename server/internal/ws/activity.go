@@ -0,0 +1,36 @@
+package ws
+
+import "time"
+
+// activityInterval limits how often one client reports its room as active
+const activityInterval = 10 * time.Second
+
+// Yjs sync message subtypes, after the MessageSync (0) byte
+const (
+	syncStep2  = 1
+	syncUpdate = 2
+)
+
+// Activity delivers the IDs of rooms whose documents are being edited over
+// the websocket, so their expiry can be pushed back. Reports are dropped
+// when nobody keeps up with the channel.
+func (h *Hub) Activity() <-chan string {
+	return h.activity
+}
+
+// noteActivity reports an edit by c, at most once per activityInterval
+func (c *Client) noteActivity(message []byte) {
+	if len(message) < 2 || message[0] != 0 || (message[1] != syncStep2 && message[1] != syncUpdate) {
+		return
+	}
+	now := time.Now()
+	if now.Sub(c.lastActivity) < activityInterval {
+		return
+	}
+	c.lastActivity = now
+
+	select {
+	case c.hub.activity <- c.roomID:
+	default:
+	}
+}
@@ -7,20 +7,6 @@ import (
 	"github.com/gorilla/websocket"
 )
 
-const (
-	// Time allowed to write a message to the peer.
-	writeWait = 10 * time.Second
-
-	// Time allowed to read the next pong message from the peer.
-	pongWait = 60 * time.Second
-
-	// Send pings to peer with this period. Must be less than pongWait.
-	pingPeriod = (pongWait * 9) / 10
-
-	// Maximum message size allowed from peer.
-	maxMessageSize = 512 * 1024 // 512KB for large syncs
-)
-
 type Client struct {
 	hub *Hub
 
@@ -32,6 +18,16 @@ type Client struct {
 
 	// Room ID this client is connected to
 	roomID string
+
+	// Whether this client views someone else's burn-on-disconnect room,
+	// so its leaving burns the room
+	viewer bool
+
+	// Why the hub closed this client; set before send is closed
+	closeReason *CloseReason
+
+	// Last time this client reported an edit, only touched by readPump
+	lastActivity time.Time
 }
 
 // readPump pumps messages from the websocket connection to the hub.
@@ -40,9 +36,10 @@ func (c *Client) readPump() {
 		c.hub.unregister <- c
 		c.conn.Close()
 	}()
-	c.conn.SetReadLimit(maxMessageSize)
-	c.conn.SetReadDeadline(time.Now().Add(pongWait))
-	c.conn.SetPongHandler(func(string) error { c.conn.SetReadDeadline(time.Now().Add(pongWait)); return nil })
+	cfg := c.hub.cfg
+	c.conn.SetReadLimit(cfg.MaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(cfg.PongWait))
+	c.conn.SetPongHandler(func(string) error { c.conn.SetReadDeadline(time.Now().Add(cfg.PongWait)); return nil })
 	for {
 		_, message, err := c.conn.ReadMessage()
 		if err != nil {
@@ -51,6 +48,12 @@ func (c *Client) readPump() {
 			}
 			break
 		}
+		// Control messages are server-originated only
+		if len(message) > 0 && message[0] == MessageControl {
+			continue
+		}
+		c.noteActivity(message)
+
 		// Send to hub for broadcast
 		c.hub.broadcast <- &Message{
 			RoomID:  c.roomID,
@@ -62,18 +65,24 @@ func (c *Client) readPump() {
 
 // writePump pumps messages from the hub to the websocket connection.
 func (c *Client) writePump() {
-	ticker := time.NewTicker(pingPeriod)
+	cfg := c.hub.cfg
+	ticker := time.NewTicker(cfg.PingPeriod())
 	defer func() {
 		ticker.Stop()
 		c.conn.Close()
+		c.hub.pumps.Done()
 	}()
 	for {
 		select {
 		case message, ok := <-c.send:
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			c.conn.SetWriteDeadline(time.Now().Add(cfg.WriteWait))
 			if !ok {
 				// The hub closed the channel.
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				closeMessage := []byte{}
+				if c.closeReason != nil {
+					closeMessage = c.closeReason.message()
+				}
+				c.conn.WriteMessage(websocket.CloseMessage, closeMessage)
 				return
 			}
 
@@ -87,7 +96,7 @@ func (c *Client) writePump() {
 				return
 			}
 		case <-ticker.C:
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			c.conn.SetWriteDeadline(time.Now().Add(cfg.WriteWait))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
@@ -0,0 +1,31 @@
+package ws
+
+import "github.com/gorilla/websocket"
+
+// CloseReason is the close code and text sent to clients when the server
+// ends their session, so they can tell a deliberate close from a network blip.
+type CloseReason struct {
+	Code int
+	Text string
+}
+
+// Application close codes (RFC 6455 reserves 4000-4999 for applications).
+// Clients should not reconnect after receiving any of these.
+const (
+	CloseCodeRoomDeleted = 4000 // The owner deleted the room
+	CloseCodeRoomExpired = 4001 // The room outlived its expiry
+	CloseCodeKicked      = 4002 // The client was removed from the room; nothing sends it yet
+	CloseCodeRoomBurned  = 4003 // The room burned after reading
+)
+
+var (
+	ReasonRoomDeleted    = CloseReason{Code: CloseCodeRoomDeleted, Text: "room deleted"}
+	ReasonRoomExpired    = CloseReason{Code: CloseCodeRoomExpired, Text: "room expired"}
+	ReasonKicked         = CloseReason{Code: CloseCodeKicked, Text: "kicked"}
+	ReasonRoomBurned     = CloseReason{Code: CloseCodeRoomBurned, Text: "room burned after reading"}
+	ReasonServerShutdown = CloseReason{Code: websocket.CloseServiceRestart, Text: "server restarting"}
+)
+
+func (r CloseReason) message() []byte {
+	return websocket.FormatCloseMessage(r.Code, r.Text)
+}
@@ -0,0 +1,59 @@
+package ws
+
+import (
+	"encoding/json"
+	"log"
+)
+
+// MessageControl is the message type for server-originated control events.
+// 0 (sync) and 1 (awareness) carry Yjs traffic and y-protocols reserves 2 (auth)
+// and 3 (query awareness), so control messages use a type clients never send.
+// Wire format: [MessageControl][JSON-encoded Event].
+const MessageControl = 100
+
+// Control event types
+const (
+	EventFileAdded    = "file.added"
+	EventFileRemoved  = "file.removed"
+	EventFileUpdated  = "file.updated" // Rename, description or pin change
+	EventRoomDeleted  = "room.deleted"
+	EventRoomExpired  = "room.expired"
+	EventRoomRenamed  = "room.renamed"  // Clients should switch to the new slug
+	EventRoomBurned   = "room.burned"   // Burn-after-reading room destroyed
+	EventRoomExpiring = "room.expiring" // Expiry is near; data has expireAt
+	EventRoomExtended = "room.extended" // Expiry was pushed back; data has expireAt
+)
+
+// Event is a control message announced to every client in a room
+type Event struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+func encodeEvent(event Event) ([]byte, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{MessageControl}, payload...), nil
+}
+
+// Notify sends a control event to all clients connected to a room
+func (h *Hub) Notify(roomID string, event Event) {
+	message, err := encodeEvent(event)
+	if err != nil {
+		log.Printf("Failed to encode %s event: %v", event.Type, err)
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for client := range h.rooms[roomID] {
+		select {
+		case client.send <- message:
+		default:
+			log.Printf("WARN: Dropped %s event for client in room %s (buffer full)", event.Type, roomID)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package ws
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// activeRooms returns the IDs of rooms with at least one connected client
+func (h *Hub) activeRooms() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	roomIDs := make([]string, 0, len(h.rooms))
+	for roomID := range h.rooms {
+		roomIDs = append(roomIDs, roomID)
+	}
+	return roomIDs
+}
+
+// WatchExpiry periodically closes connections to rooms that have expired.
+// TTL expiry deletes rooms silently, so rooms the store no longer reports as
+// live are treated as expired.
+func (h *Hub) WatchExpiry(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.closeExpiredRooms(ctx)
+		}
+	}
+}
+
+func (h *Hub) closeExpiredRooms(ctx context.Context) {
+	roomIDs := h.activeRooms()
+	if len(roomIDs) == 0 {
+		return
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	alive, err := h.store.Live(queryCtx, roomIDs)
+	if err != nil {
+		log.Printf("Failed to check room expiry: %v", err)
+		return
+	}
+
+	for _, roomID := range roomIDs {
+		if alive[roomID] {
+			continue
+		}
+		h.Notify(roomID, Event{Type: EventRoomExpired, Data: map[string]string{"id": roomID}})
+		h.CloseRoom(roomID, ReasonRoomExpired)
+		log.Printf("Room expired with clients connected: %s", roomID)
+	}
+}
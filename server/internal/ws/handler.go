@@ -1,17 +1,15 @@
 package ws
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
 	"time"
-	"context"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
-	"github.com/pranavdhawale/notex/server/internal/models"
 	"github.com/pranavdhawale/notex/server/internal/state"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 var upgrader = websocket.Upgrader{
@@ -22,45 +20,54 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-// Main Hub instance (singleton for now)
-var MainHub = NewHub()
-
 func ServeWs(hub *Hub, c *gin.Context) {
-	roomID := c.Query("room")
-	if roomID == "" {
-		roomID = c.Param("room")
+	slug := c.Query("room")
+	if slug == "" {
+		slug = c.Param("room")
 	}
-	if roomID == "" {
+	if slug == "" {
 		log.Println("No room ID provided in WS connection")
 		http.Error(c.Writer, "Room ID is required", http.StatusBadRequest)
 		return
 	}
 
-	// CHECK: Verify room exists in DB
-	collection := state.MongoDatabase.Collection("rooms")
+	// CHECK: Verify room exists in DB, following aliases of renamed rooms
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	var room models.Room
-	err := collection.FindOne(ctx, bson.M{"slug": roomID}).Decode(&room)
+	room, _, err := hub.store.Resolve(ctx, slug)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			log.Printf("Attempt to connect to non-existent room: %s", roomID)
+		if errors.Is(err, state.ErrNotFound) {
+			log.Printf("Attempt to connect to non-existent room: %s", slug)
 			http.Error(c.Writer, "Room not found", http.StatusNotFound)
 			return
 		}
-		log.Printf("Database error checking room %s: %v", roomID, err)
+		log.Printf("Database error checking room %s: %v", slug, err)
 		http.Error(c.Writer, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	if room.Burned() {
+		http.Error(c.Writer, "Room has already been read", http.StatusGone)
+		return
+	}
+
+	if !hub.track() {
+		http.Error(c.Writer, "Server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
 
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
+		hub.pumps.Done()
 		log.Printf("Failed to upgrade websocket: %v", err)
 		return
 	}
 
-	client := &Client{hub: hub, conn: conn, send: make(chan []byte, 256), roomID: roomID}
+	// The owner can come and go; anyone else is a viewer
+	user := c.Query("user")
+	viewer := room.BurnOnDisconnect && (room.Owner == "" || user != room.Owner)
+
+	client := &Client{hub: hub, conn: conn, send: make(chan []byte, hub.cfg.SendBuffer), roomID: room.ID, viewer: viewer}
 	client.hub.register <- client
 
 	// Allow collection of memory referenced by the caller by doing all work in
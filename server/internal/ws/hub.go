@@ -1,11 +1,20 @@
 package ws
 
 import (
+	"context"
 	"log"
 	"sync"
+
+	"github.com/pranavdhawale/notex/server/internal/config"
+	"github.com/pranavdhawale/notex/server/internal/state"
 )
 
 type Hub struct {
+	cfg config.WebSocketConfig
+
+	// Used to check that rooms exist and have not expired
+	store state.RoomStore
+
 	// Registered clients by room
 	rooms map[string]map[*Client]bool
 
@@ -23,6 +32,18 @@ type Hub struct {
 
 	// Lock for rooms map
 	mu sync.RWMutex
+
+	// Set once Shutdown starts; no new clients are accepted after that
+	closed bool
+
+	// Running write pumps, so Shutdown can wait for close frames to flush
+	pumps sync.WaitGroup
+
+	// IDs of rooms being edited, see Activity
+	activity chan string
+
+	// Called when a viewer leaves, see OnViewerLeft
+	onViewerLeft func(roomID string)
 }
 
 type Message struct {
@@ -31,22 +52,34 @@ type Message struct {
 	Content []byte
 }
 
-func NewHub() *Hub {
+func NewHub(cfg config.WebSocketConfig, store state.RoomStore) *Hub {
 	return &Hub{
+		cfg:        cfg,
+		store:      store,
 		rooms:      make(map[string]map[*Client]bool),
 		awareness:  make(map[string]map[*Client][]byte),
 		broadcast:  make(chan *Message),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
+		activity:   make(chan string, 64),
 	}
 }
 
-func (h *Hub) CloseRoom(roomID string) {
+// OnViewerLeft sets fn to be called, in its own goroutine, when the viewer
+// of a burn-on-disconnect room leaves of its own accord. Clients closed by
+// the hub do not count. Must be called before Run.
+func (h *Hub) OnViewerLeft(fn func(roomID string)) {
+	h.onViewerLeft = fn
+}
+
+// CloseRoom disconnects every client in a room, telling them why
+func (h *Hub) CloseRoom(roomID string, reason CloseReason) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
 	if clients, ok := h.rooms[roomID]; ok {
 		for client := range clients {
+			client.closeReason = &reason
 			close(client.send)
 			delete(h.rooms[roomID], client)
 		}
@@ -56,13 +89,71 @@ func (h *Hub) CloseRoom(roomID string) {
 	}
 }
 
+// Participants returns how many clients are connected to a room
+func (h *Hub) Participants(roomID string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.rooms[roomID])
+}
+
+// track reserves a write pump slot for a new client.
+// It reports false once the hub is shutting down.
+func (h *Hub) track() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return false
+	}
+	h.pumps.Add(1)
+	return true
+}
+
+// Shutdown closes every client with a "server restarting" reason and waits
+// for their close frames to be written, or for ctx to expire.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.mu.Lock()
+	h.closed = true
+	for roomID, clients := range h.rooms {
+		for client := range clients {
+			reason := ReasonServerShutdown
+			client.closeReason = &reason
+			close(client.send)
+		}
+		delete(h.rooms, roomID)
+		delete(h.awareness, roomID)
+	}
+	h.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		h.pumps.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Println("All WebSocket clients disconnected")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (h *Hub) Run() {
 	for {
-// ... (rest of Run loop remains the same, I will use replace_file_content carefully or just append the method if I can't match Run properly easily - actually I can match end of file or insert before Run)
-// Better: Insert CloseRoom before Run.
 		select {
 		case client := <-h.register:
 			h.mu.Lock()
+			if h.closed {
+				// Upgraded just as Shutdown ran
+				reason := ReasonServerShutdown
+				client.closeReason = &reason
+				close(client.send)
+				h.mu.Unlock()
+				continue
+			}
 			if _, ok := h.rooms[client.roomID]; !ok {
 				h.rooms[client.roomID] = make(map[*Client]bool)
 				h.awareness[client.roomID] = make(map[*Client][]byte)
@@ -98,6 +189,9 @@ func (h *Hub) Run() {
 					
 					close(client.send)
 					log.Printf("Client unregistered from room: %s", client.roomID)
+					if client.viewer && h.onViewerLeft != nil {
+						go h.onViewerLeft(client.roomID)
+					}
 					// Cleanup room if empty
 					if len(h.rooms[client.roomID]) == 0 {
 						delete(h.rooms, client.roomID)
@@ -124,23 +218,21 @@ func (h *Hub) Run() {
 				// log.Printf("DEBUG: Cached awareness update for client in room %s", message.RoomID)
 			}
 
-			clients, ok := h.rooms[message.RoomID]
-			h.mu.Unlock()
-			
-			if ok {
-				for client := range clients {
-					// Don't send back to sender
-					if client == message.Sender {
-						continue
-					}
-					
-					select {
-					case client.send <- message.Content:
-					default:
-						// If send buffer is full, close channel and assume client is dead
-					}
+			// Sends happen under the lock, as CloseRoom and Shutdown close
+			// send channels under it; they never block
+			for client := range h.rooms[message.RoomID] {
+				// Don't send back to sender
+				if client == message.Sender {
+					continue
+				}
+				
+				select {
+				case client.send <- message.Content:
+				default:
+					// If send buffer is full, close channel and assume client is dead
 				}
 			}
+			h.mu.Unlock()
 		}
 	}
 }
@@ -0,0 +1,139 @@
+package yjs
+
+import (
+	"errors"
+	"unicode/utf8"
+)
+
+// ErrInvalidUpdate is returned for bytes that are not a Yjs update
+var ErrInvalidUpdate = errors.New("yjs: invalid update")
+
+// maxAnyDepth bounds the nesting of decoded values, so a hostile update
+// cannot exhaust the stack
+const maxAnyDepth = 256
+
+// maxClock is the largest clock JavaScript can represent exactly
+const maxClock = 1<<53 - 1
+
+// decoder reads the lib0 binary encoding. The first error sticks; reads
+// after it return zero values.
+type decoder struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (d *decoder) fail() {
+	if d.err == nil {
+		d.err = ErrInvalidUpdate
+	}
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil || d.pos >= len(d.buf) {
+		d.fail()
+		return 0
+	}
+	b := d.buf[d.pos]
+	d.pos++
+	return b
+}
+
+func (d *decoder) varUint() uint64 {
+	var n uint64
+	for shift := 0; shift < 64; shift += 7 {
+		b := d.byte()
+		if d.err != nil {
+			return 0
+		}
+		n |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return n
+		}
+	}
+	d.fail()
+	return 0
+}
+
+// clock reads a varUint that has to fit in a JavaScript number
+func (d *decoder) clock() uint64 {
+	n := d.varUint()
+	if n > maxClock {
+		d.fail()
+		return 0
+	}
+	return n
+}
+
+func (d *decoder) varInt() {
+	b := d.byte()
+	for b&0x80 != 0 && d.err == nil {
+		b = d.byte()
+	}
+}
+
+func (d *decoder) bytes(n uint64) []byte {
+	if d.err != nil || n > uint64(len(d.buf)-d.pos) {
+		d.fail()
+		return nil
+	}
+	b := d.buf[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b
+}
+
+func (d *decoder) varBytes() []byte {
+	return d.bytes(d.varUint())
+}
+
+// varString rejects invalid UTF-8, which would decode differently here and
+// in the browser and so break the string lengths items are measured in
+func (d *decoder) varString() string {
+	b := d.varBytes()
+	if !utf8.Valid(b) {
+		d.fail()
+		return ""
+	}
+	return string(b)
+}
+
+// any skips a value written by lib0's writeAny and returns its encoding
+func (d *decoder) any() []byte {
+	start := d.pos
+	d.skipAny(0)
+	if d.err != nil {
+		return nil
+	}
+	return d.buf[start:d.pos]
+}
+
+func (d *decoder) skipAny(depth int) {
+	if depth > maxAnyDepth {
+		d.fail()
+		return
+	}
+	switch d.byte() {
+	case anyUndefined, anyNull, anyFalse, anyTrue:
+	case anyInt:
+		d.varInt()
+	case anyFloat32:
+		d.bytes(4)
+	case anyFloat64, anyBigInt:
+		d.bytes(8)
+	case anyString:
+		d.varString()
+	case anyObject:
+		for n := d.varUint(); n > 0 && d.err == nil; n-- {
+			d.varString()
+			d.skipAny(depth + 1)
+		}
+	case anyArray:
+		for n := d.varUint(); n > 0 && d.err == nil; n-- {
+			d.skipAny(depth + 1)
+		}
+	case anyBytes:
+		d.varBytes()
+	default:
+		d.fail()
+	}
+}
@@ -0,0 +1,156 @@
+package yjs
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// encoder writes the lib0 binary encoding used by Yjs
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) byte(b byte) {
+	e.buf = append(e.buf, b)
+}
+
+func (e *encoder) varUint(n uint64) {
+	for n > 0x7f {
+		e.buf = append(e.buf, byte(n&0x7f)|0x80)
+		n >>= 7
+	}
+	e.buf = append(e.buf, byte(n))
+}
+
+// varInt stores the sign in the first byte, next to 6 bits of the number
+func (e *encoder) varInt(n int64) {
+	var sign byte
+	if n < 0 {
+		sign = 0x40
+		n = -n
+	}
+	u := uint64(n)
+	first := sign | byte(u&0x3f)
+	if u > 0x3f {
+		first |= 0x80
+	}
+	e.buf = append(e.buf, first)
+	u >>= 6
+	for u > 0 {
+		b := byte(u & 0x7f)
+		if u > 0x7f {
+			b |= 0x80
+		}
+		e.buf = append(e.buf, b)
+		u >>= 7
+	}
+}
+
+func (e *encoder) varString(s string) {
+	e.varUint(uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) varBytes(b []byte) {
+	e.varUint(uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// json writes a value as a JSON string, as Yjs does for format attributes
+func (e *encoder) json(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	e.varString(string(data))
+	return nil
+}
+
+// lib0 type tags for writeAny
+const (
+	anyUndefined = 127
+	anyNull      = 126
+	anyInt       = 125
+	anyFloat32   = 124
+	anyFloat64   = 123
+	anyBigInt    = 122
+	anyFalse     = 121
+	anyTrue      = 120
+	anyString    = 119
+	anyObject    = 118
+	anyArray     = 117
+	anyBytes     = 116
+)
+
+// any writes a JSON-like value the way lib0's writeAny does for the
+// equivalent JavaScript value
+func (e *encoder) any(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		e.byte(anyNull)
+	case bool:
+		if v {
+			e.byte(anyTrue)
+		} else {
+			e.byte(anyFalse)
+		}
+	case string:
+		e.byte(anyString)
+		e.varString(v)
+	case int:
+		e.number(float64(v))
+	case int32:
+		e.number(float64(v))
+	case int64:
+		e.number(float64(v))
+	case float64:
+		e.number(v)
+	case []byte:
+		e.byte(anyBytes)
+		e.varBytes(v)
+	case []interface{}:
+		e.byte(anyArray)
+		e.varUint(uint64(len(v)))
+		for _, item := range v {
+			if err := e.any(item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		// Go maps are unordered; sorted keys keep the output deterministic
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		e.byte(anyObject)
+		e.varUint(uint64(len(keys)))
+		for _, k := range keys {
+			e.varString(k)
+			if err := e.any(v[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("yjs: cannot encode %T", v)
+	}
+	return nil
+}
+
+// number picks the smallest encoding JavaScript would for a number
+func (e *encoder) number(f float64) {
+	switch {
+	case f == math.Trunc(f) && math.Abs(f) <= math.MaxInt32:
+		e.byte(anyInt)
+		e.varInt(int64(f))
+	case float64(float32(f)) == f:
+		e.byte(anyFloat32)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, math.Float32bits(float32(f)))
+	default:
+		e.byte(anyFloat64)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(f))
+	}
+}
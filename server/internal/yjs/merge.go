@@ -0,0 +1,118 @@
+package yjs
+
+import (
+	"sort"
+)
+
+// ValidateUpdate checks that data is a well-formed Yjs update
+func ValidateUpdate(data []byte) error {
+	_, err := decodeUpdate(data)
+	return err
+}
+
+// MergeUpdates combines Yjs updates into one holding everything they do,
+// like Y.mergeUpdates. Updates are commutative, so applying the result
+// equals applying the inputs in any order. Fails with ErrInvalidUpdate if
+// any input is not an update.
+func MergeUpdates(updates ...[]byte) ([]byte, error) {
+	merged := &update{structs: map[uint64][]*ystruct{}, deletes: map[uint64][]span{}}
+	for _, data := range updates {
+		u, err := decodeUpdate(data)
+		if err != nil {
+			return nil, err
+		}
+		for client, structs := range u.structs {
+			merged.structs[client] = append(merged.structs[client], structs...)
+		}
+		for client, spans := range u.deletes {
+			merged.deletes[client] = append(merged.deletes[client], spans...)
+		}
+	}
+
+	var e encoder
+	clients := sortedClients(merged.structs)
+	var written [][]*ystruct
+	for _, client := range clients {
+		if structs := mergeStructs(merged.structs[client]); len(structs) > 0 {
+			written = append(written, structs)
+		}
+	}
+	e.varUint(uint64(len(written)))
+	for _, structs := range written {
+		e.varUint(uint64(len(structs)))
+		e.varUint(structs[0].client)
+		e.varUint(structs[0].clock)
+		for _, s := range structs {
+			s.write(&e)
+		}
+	}
+
+	clients = sortedClients(merged.deletes)
+	e.varUint(uint64(len(clients)))
+	for _, client := range clients {
+		spans := mergeSpans(merged.deletes[client])
+		e.varUint(client)
+		e.varUint(uint64(len(spans)))
+		for _, r := range spans {
+			e.varUint(r.clock)
+			e.varUint(r.length)
+		}
+	}
+	return e.buf, nil
+}
+
+// sortedClients returns the client IDs of m in descending order, the order
+// Yjs writes them in
+func sortedClients[T any](m map[uint64]T) []uint64 {
+	clients := make([]uint64, 0, len(m))
+	for client := range m {
+		clients = append(clients, client)
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i] > clients[j] })
+	return clients
+}
+
+// mergeStructs lays the structs of one client end to end. Where they
+// overlap the earlier one wins and the later one is cut; gaps, including
+// the Skips of the inputs, become Skips.
+func mergeStructs(structs []*ystruct) []*ystruct {
+	sort.SliceStable(structs, func(i, j int) bool { return structs[i].clock < structs[j].clock })
+
+	var out []*ystruct
+	var next uint64
+	for _, s := range structs {
+		if s.ref == structSkip {
+			continue
+		}
+		if len(out) > 0 {
+			if s.end() <= next {
+				continue
+			}
+			if s.clock > next {
+				out = append(out, &ystruct{ref: structSkip, client: s.client, clock: next, length: s.clock - next})
+			} else if s.clock < next {
+				s = s.slice(next - s.clock)
+			}
+		}
+		out = append(out, s)
+		next = s.end()
+	}
+	return out
+}
+
+// mergeSpans sorts deleted ranges and joins those that overlap or touch
+func mergeSpans(spans []span) []span {
+	sort.Slice(spans, func(i, j int) bool { return spans[i].clock < spans[j].clock })
+
+	out := spans[:0:0]
+	for _, r := range spans {
+		if n := len(out); n > 0 && r.clock <= out[n-1].clock+out[n-1].length {
+			if end := r.clock + r.length; end > out[n-1].clock+out[n-1].length {
+				out[n-1].length = end - out[n-1].clock
+			}
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
@@ -0,0 +1,192 @@
+package yjs
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/pranavdhawale/notex/server/internal/models"
+)
+
+// textItem is a string item of client 1 in the root type "t": after
+// origin if set, otherwise at the start
+type textItem struct {
+	clock  uint64
+	origin *id
+	text   string
+}
+
+// textUpdate encodes items of client 1, which must be contiguous, and a
+// delete set
+func textUpdate(items []textItem, deletes ...span) []byte {
+	var e encoder
+	if len(items) == 0 {
+		e.varUint(0)
+	} else {
+		e.varUint(1)
+		e.varUint(uint64(len(items)))
+		e.varUint(1)
+		e.varUint(items[0].clock)
+		for _, it := range items {
+			if it.origin != nil {
+				e.byte(contentString | hasOrigin)
+				e.varUint(it.origin.client)
+				e.varUint(it.origin.clock)
+			} else {
+				e.byte(contentString)
+				e.varUint(1)
+				e.varString("t")
+			}
+			e.varString(it.text)
+		}
+	}
+	if len(deletes) == 0 {
+		e.varUint(0)
+	} else {
+		e.varUint(1)
+		e.varUint(1)
+		e.varUint(uint64(len(deletes)))
+		for _, r := range deletes {
+			e.varUint(r.clock)
+			e.varUint(r.length)
+		}
+	}
+	return e.buf
+}
+
+func TestMergeRoundTrip(t *testing.T) {
+	doc := models.DocNode{Type: "doc", Content: []models.DocNode{
+		{Type: "heading", Attrs: map[string]interface{}{"level": float64(2)}, Content: []models.DocNode{
+			{Type: "text", Text: "Notes"},
+		}},
+		{Type: "paragraph", Content: []models.DocNode{
+			{Type: "text", Text: "bold", Marks: []models.DocMark{{Type: "bold"}}},
+			{Type: "text", Text: " and plain"},
+		}},
+	}}
+	update, err := EncodeProseMirror(doc, DefaultFragment, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	merged, err := MergeUpdates(update)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(merged, update) {
+		t.Errorf("merging one update changed it:\n got %v\nwant %v", merged, update)
+	}
+
+	if merged, _ := MergeUpdates(update, update); !bytes.Equal(merged, update) {
+		t.Errorf("merging an update with itself changed it:\n got %v\nwant %v", merged, update)
+	}
+}
+
+func TestMergeClientsCommute(t *testing.T) {
+	a, _ := EncodeProseMirror(models.DocNode{Type: "doc"}, DefaultFragment, 1)
+	b, _ := EncodeProseMirror(models.DocNode{Type: "doc", Content: []models.DocNode{{Type: "paragraph"}}}, DefaultFragment, 2)
+
+	ab, err := MergeUpdates(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ba, _ := MergeUpdates(b, a)
+	if !bytes.Equal(ab, ba) {
+		t.Errorf("merge depends on order:\n%v\n%v", ab, ba)
+	}
+	if ab[0] != 1 || ab[2] != 2 {
+		t.Errorf("expected only client 2 to have structs, got %v", ab)
+	}
+}
+
+func TestMergeOverlappingStructs(t *testing.T) {
+	older := textUpdate([]textItem{{clock: 0, text: "hel"}})
+	newer := textUpdate([]textItem{{clock: 0, text: "hello"}, {clock: 5, origin: &id{1, 4}, text: "!"}})
+
+	got, err := MergeUpdates(older, newer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// "hel" is kept and the rest of "hello" continues after its last character
+	want := textUpdate([]textItem{
+		{clock: 0, text: "hel"},
+		{clock: 3, origin: &id{1, 2}, text: "lo"},
+		{clock: 5, origin: &id{1, 4}, text: "!"},
+	})
+	if !bytes.Equal(got, want) {
+		t.Errorf("MergeUpdates:\n got %v\nwant %v", got, want)
+	}
+}
+
+func TestMergeGapsBecomeSkips(t *testing.T) {
+	first := textUpdate([]textItem{{clock: 0, text: "a"}})
+	later := textUpdate([]textItem{{clock: 5, origin: &id{1, 4}, text: "b"}})
+
+	got, err := MergeUpdates(later, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{1, 3, 1, 0,
+		contentString, 1, 1, 't', 1, 'a',
+		structSkip, 4,
+		contentString | hasOrigin, 1, 4, 1, 'b',
+		0}
+	if !bytes.Equal(got, want) {
+		t.Errorf("MergeUpdates:\n got %v\nwant %v", got, want)
+	}
+}
+
+func TestMergeDeleteSets(t *testing.T) {
+	a := textUpdate(nil, span{0, 2})
+	b := textUpdate(nil, span{10, 1}, span{1, 3})
+
+	got, err := MergeUpdates(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := textUpdate(nil, span{0, 4}, span{10, 1}); !bytes.Equal(got, want) {
+		t.Errorf("MergeUpdates:\n got %v\nwant %v", got, want)
+	}
+}
+
+func TestSliceSplitsSurrogatePairs(t *testing.T) {
+	update := textUpdate([]textItem{{clock: 0, text: "a\U0001F600b"}})
+	u, err := decodeUpdate(update)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := u.structs[1][0]
+	if s.length != 4 {
+		t.Fatalf("expected UTF-16 length 4, got %d", s.length)
+	}
+
+	var e encoder
+	s.slice(2).content.write(&e)
+	if got, want := e.buf, append([]byte{4}, "�b"...); !bytes.Equal(got, want) {
+		t.Errorf("slice: got %q, want %q", got, want)
+	}
+}
+
+func TestValidateUpdate(t *testing.T) {
+	valid := textUpdate([]textItem{{clock: 0, text: "hi"}})
+	if err := ValidateUpdate(valid); err != nil {
+		t.Errorf("valid update: %v", err)
+	}
+	if err := ValidateUpdate([]byte{0, 0}); err != nil {
+		t.Errorf("empty update: %v", err)
+	}
+
+	for name, data := range map[string][]byte{
+		"empty":     {},
+		"truncated": valid[:len(valid)-2],
+		"trailing":  append(append([]byte{}, valid...), 0),
+		"json":      []byte(`{"type":"doc"}`),
+		"utf8":      {1, 1, 1, 0, contentString, 1, 1, 't', 1, 0xff, 0},
+		"zero gc":   {1, 1, 1, 0, structGC, 0, 0},
+		"bad ref":   {1, 1, 1, 0, 15, 0},
+	} {
+		if err := ValidateUpdate(data); !errors.Is(err, ErrInvalidUpdate) {
+			t.Errorf("%s: expected ErrInvalidUpdate, got %v", name, err)
+		}
+	}
+}
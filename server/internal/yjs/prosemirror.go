@@ -0,0 +1,235 @@
+package yjs
+
+import (
+	"errors"
+	"sort"
+	"unicode/utf16"
+
+	"github.com/pranavdhawale/notex/server/internal/models"
+)
+
+// DefaultFragment is the shared type the editor binds to
+const DefaultFragment = "default"
+
+// Content and type references of the Yjs update format
+const (
+	contentString = 4
+	contentFormat = 6
+	contentType   = 7
+	contentAny    = 8
+
+	typeXmlElement = 3
+	typeXmlText    = 6
+)
+
+// Item info flags
+const (
+	hasOrigin      = 0x80
+	hasRightOrigin = 0x40
+	hasParentSub   = 0x20
+)
+
+type id struct {
+	client, clock uint64
+}
+
+// docEncoder lays out a document as the items of a single client
+type docEncoder struct {
+	structs encoder
+	count   uint64
+	client  uint64
+	clock   uint64
+}
+
+// item writes the header of the next item and returns its ID. origin is
+// the item to its left among its siblings; without one the item names its
+// parent, a root type when parent is nil.
+func (d *docEncoder) item(ref byte, origin *id, rootKey string, parent *id, parentSub string) id {
+	info := ref
+	if origin != nil {
+		info |= hasOrigin
+	}
+	if parentSub != "" {
+		info |= hasParentSub
+	}
+	d.structs.byte(info)
+
+	if origin != nil {
+		d.structs.varUint(origin.client)
+		d.structs.varUint(origin.clock)
+	} else {
+		if parent == nil {
+			d.structs.varUint(1)
+			d.structs.varString(rootKey)
+		} else {
+			d.structs.varUint(0)
+			d.structs.varUint(parent.client)
+			d.structs.varUint(parent.clock)
+		}
+		if parentSub != "" {
+			d.structs.varString(parentSub)
+		}
+	}
+
+	d.count++
+	return id{d.client, d.clock}
+}
+
+// siblings tracks where the next child of a type goes
+type siblings struct {
+	rootKey string
+	parent  *id
+	last    *id // Last clock of the previous sibling
+}
+
+func (s *siblings) next(d *docEncoder, ref byte) id {
+	return d.item(ref, s.last, s.rootKey, s.parent, "")
+}
+
+// advance moves the clock past an item of length n and makes it the left
+// neighbour of the next sibling
+func (s *siblings) advance(d *docEncoder, start id, n uint64) {
+	d.clock += n
+	last := id{start.client, start.clock + n - 1}
+	s.last = &last
+}
+
+// EncodeProseMirror encodes doc as a Yjs update holding it in fragment,
+// laid out the way y-prosemirror binds an editor document: elements become
+// XmlElements with their attributes, and runs of text become an XmlText
+// with marks as formatting attributes. client is the Yjs client ID the
+// items are attributed to.
+func EncodeProseMirror(doc models.DocNode, fragment string, client uint32) ([]byte, error) {
+	if doc.Type != "doc" {
+		return nil, errors.New("yjs: document root must be of type doc")
+	}
+
+	d := &docEncoder{client: uint64(client)}
+	root := &siblings{rootKey: fragment}
+	if err := d.children(root, doc.Content); err != nil {
+		return nil, err
+	}
+
+	var update encoder
+	if d.count > 0 {
+		update.varUint(1) // Clients
+		update.varUint(d.count)
+		update.varUint(d.client)
+		update.varUint(0) // First clock
+		update.buf = append(update.buf, d.structs.buf...)
+	} else {
+		update.varUint(0)
+	}
+	update.varUint(0) // Empty delete set
+	return update.buf, nil
+}
+
+// children writes the child nodes of a type, grouping adjacent text nodes
+// into one XmlText
+func (d *docEncoder) children(s *siblings, nodes []models.DocNode) error {
+	for i := 0; i < len(nodes); {
+		if nodes[i].Type == "text" {
+			j := i
+			for j < len(nodes) && nodes[j].Type == "text" {
+				j++
+			}
+			if err := d.text(s, nodes[i:j]); err != nil {
+				return err
+			}
+			i = j
+			continue
+		}
+		if err := d.element(s, nodes[i]); err != nil {
+			return err
+		}
+		i++
+	}
+	return nil
+}
+
+func (d *docEncoder) element(s *siblings, node models.DocNode) error {
+	if node.Type == "" {
+		return errors.New("yjs: node without a type")
+	}
+
+	start := s.next(d, contentType)
+	d.structs.varUint(typeXmlElement)
+	d.structs.varString(node.Type)
+	s.advance(d, start, 1)
+
+	// Attributes are map entries of the element; null means unset
+	for _, key := range sortedKeys(node.Attrs) {
+		value := node.Attrs[key]
+		if value == nil {
+			continue
+		}
+		d.item(contentAny, nil, "", &start, key)
+		d.structs.varUint(1)
+		if err := d.structs.any(value); err != nil {
+			return err
+		}
+		d.clock++
+	}
+
+	return d.children(&siblings{parent: &start}, node.Content)
+}
+
+func (d *docEncoder) text(s *siblings, nodes []models.DocNode) error {
+	start := s.next(d, contentType)
+	d.structs.varUint(typeXmlText)
+	s.advance(d, start, 1)
+
+	inner := &siblings{parent: &start}
+	for _, node := range nodes {
+		if node.Text == "" {
+			continue
+		}
+
+		// Formatting brackets the text: each mark is switched on before it
+		// and off again after it
+		for _, mark := range node.Marks {
+			if err := d.format(inner, mark.Type, markValue(mark)); err != nil {
+				return err
+			}
+		}
+
+		item := inner.next(d, contentString)
+		d.structs.varString(node.Text)
+		inner.advance(d, item, uint64(len(utf16.Encode([]rune(node.Text)))))
+
+		for _, mark := range node.Marks {
+			if err := d.format(inner, mark.Type, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (d *docEncoder) format(s *siblings, key string, value interface{}) error {
+	item := s.next(d, contentFormat)
+	d.structs.varString(key)
+	if err := d.structs.json(value); err != nil {
+		return err
+	}
+	s.advance(d, item, 1)
+	return nil
+}
+
+// markValue is how y-prosemirror stores a mark: its attributes, or an
+// empty object for marks without any
+func markValue(mark models.DocMark) interface{} {
+	if mark.Attrs == nil {
+		return map[string]interface{}{}
+	}
+	return mark.Attrs
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
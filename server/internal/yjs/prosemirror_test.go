@@ -0,0 +1,90 @@
+package yjs
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pranavdhawale/notex/server/internal/models"
+)
+
+func TestEncodeProseMirror(t *testing.T) {
+	doc := models.DocNode{Type: "doc", Content: []models.DocNode{
+		{Type: "paragraph", Content: []models.DocNode{{Type: "text", Text: "Hi"}}},
+	}}
+
+	got, err := EncodeProseMirror(doc, DefaultFragment, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want []byte
+	want = append(want, 1, 3, 1, 0) // 1 client with 3 items, client 1 from clock 0
+	want = append(want, 0x07, 1, 7) // Type item in root "default"
+	want = append(want, "default"...)
+	want = append(want, 3, 9) // XmlElement "paragraph"
+	want = append(want, "paragraph"...)
+	want = append(want, 0x07, 0, 1, 0, 6) // XmlText in item 1:0
+	want = append(want, 0x04, 0, 1, 1, 2) // String in item 1:1
+	want = append(want, "Hi"...)
+	want = append(want, 0) // Empty delete set
+
+	if !bytes.Equal(got, want) {
+		t.Errorf("EncodeProseMirror:\n got %v\nwant %v", got, want)
+	}
+}
+
+func TestEncodeProseMirrorSiblingsAndMarks(t *testing.T) {
+	doc := models.DocNode{Type: "doc", Content: []models.DocNode{
+		{Type: "heading", Attrs: map[string]interface{}{"level": float64(1), "textAlign": nil}},
+		{Type: "paragraph", Content: []models.DocNode{
+			{Type: "text", Text: "bold", Marks: []models.DocMark{{Type: "bold"}}},
+		}},
+	}}
+
+	got, err := EncodeProseMirror(doc, DefaultFragment, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The second paragraph follows the heading (1:0), and the heading's
+	// level is a map entry; null attributes are left out
+	for _, part := range [][]byte{
+		{0x87, 1, 0, 3, 9, 'p', 'a', 'r', 'a', 'g', 'r', 'a', 'p', 'h'},
+		{0x28, 0, 1, 0, 5, 'l', 'e', 'v', 'e', 'l', 1, 125, 1},
+		{0x06, 0, 1, 3, 4, 'b', 'o', 'l', 'd', 2, '{', '}'},
+		{0x84, 1, 4, 4, 'b', 'o', 'l', 'd'},                        // Text after the opening format
+		{0x86, 1, 8, 4, 'b', 'o', 'l', 'd', 4, 'n', 'u', 'l', 'l'}, // Closing format after 4 characters
+	} {
+		if !bytes.Contains(got, part) {
+			t.Errorf("update %v lacks %v", got, part)
+		}
+	}
+	if bytes.Contains(got, []byte("textAlign")) {
+		t.Error("null attributes should be skipped")
+	}
+}
+
+func TestEncoderAny(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  []byte
+	}{
+		{float64(1), []byte{125, 1}},
+		{float64(-1), []byte{125, 0x41}},
+		{float64(100), []byte{125, 0xa4, 1}},
+		{true, []byte{120}},
+		{nil, []byte{126}},
+		{"a", []byte{119, 1, 'a'}},
+		{[]interface{}{float64(1)}, []byte{117, 1, 125, 1}},
+		{map[string]interface{}{"b": false, "a": "x"}, []byte{118, 2, 1, 'a', 119, 1, 'x', 1, 'b', 121}},
+	}
+	for _, tt := range tests {
+		var e encoder
+		if err := e.any(tt.value); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(e.buf, tt.want) {
+			t.Errorf("any(%v) = %v, want %v", tt.value, e.buf, tt.want)
+		}
+	}
+}
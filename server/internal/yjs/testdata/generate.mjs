@@ -0,0 +1,133 @@
+// Generates the golden fixtures of golden_test.go with real Yjs:
+//
+//   cd server/internal/yjs/testdata && npm install && node generate.mjs
+//
+// Commit the .json files it writes; the golden tests fail without them.
+// Each fixture is the updates a scenario emits, as the client sends them,
+// with what Y.mergeUpdates makes of them and the document they build.
+// Documents are built the way y-prosemirror builds tiptap documents: an
+// XmlFragment "default" of elements holding XmlText, with marks as
+// formatting attributes.
+
+import { writeFileSync } from "node:fs";
+import { createRequire } from "node:module";
+import * as Y from "yjs";
+
+const version = createRequire(import.meta.url)("yjs/package.json").version;
+
+// doc returns a document with a fixed client ID, recording its updates
+function doc(clientID) {
+  const d = new Y.Doc();
+  d.clientID = clientID;
+  d.updates = [];
+  d.on("update", (u) => d.updates.push(u));
+  return d;
+}
+
+function paragraph(fragment, index, text) {
+  const p = new Y.XmlElement("paragraph");
+  const t = new Y.XmlText();
+  p.insert(0, [t]);
+  fragment.insert(index, [p]);
+  t.insert(0, text);
+  return t;
+}
+
+function sync(from, to) {
+  Y.applyUpdate(to, Y.encodeStateAsUpdate(from, Y.encodeStateVector(to)));
+}
+
+function write(name, updates) {
+  const merged = Y.mergeUpdates(updates);
+  const built = new Y.Doc();
+  for (const u of updates) Y.applyUpdate(built, u);
+  const b64 = (u) => Buffer.from(u).toString("base64");
+  const fixture = {
+    yjs: version,
+    updates: updates.map(b64),
+    merged: b64(merged),
+    state: b64(Y.encodeStateAsUpdate(built)),
+    document: built.getXmlFragment("default").toString(),
+  };
+  writeFileSync(`${name}.json`, JSON.stringify(fixture, null, 2) + "\n");
+}
+
+// Marks: bold, italic and a link with attributes, set and removed
+{
+  const a = doc(101);
+  const f = a.getXmlFragment("default");
+  const h = new Y.XmlElement("heading");
+  h.setAttribute("level", 2);
+  f.insert(0, [h]);
+  h.insert(0, [new Y.XmlText("Notes")]);
+  const t = paragraph(f, 1, "Some bold and linked text");
+  t.format(5, 4, { bold: {} });
+  t.format(14, 6, { link: { href: "https://example.com", target: "_blank" } });
+  t.format(0, 4, { italic: {} });
+  t.format(0, 4, { italic: null });
+  write("marks", a.updates);
+}
+
+// Deletes: typing, backspacing and deleting a selection across marks
+{
+  const a = doc(102);
+  const f = a.getXmlFragment("default");
+  const t = paragraph(f, 0, "");
+  for (const ch of "Hello world") t.insert(t.length, ch);
+  t.delete(t.length - 5, 5);
+  t.insert(t.length, "there");
+  t.format(0, 5, { bold: {} });
+  t.delete(2, 6);
+  write("deletes", a.updates);
+}
+
+// GC: a deleted paragraph is garbage collected into GC structs
+{
+  const a = doc(103);
+  const f = a.getXmlFragment("default");
+  paragraph(f, 0, "Kept");
+  paragraph(f, 1, "Deleted with its paragraph");
+  paragraph(f, 2, "Also kept");
+  f.delete(1, 1);
+  // The collected state is what a new client is sent
+  write("gc", [Y.encodeStateAsUpdate(a)]);
+}
+
+// Skip: Y.mergeUpdates of updates with a gap between them writes a Skip
+{
+  const a = doc(104);
+  const t = paragraph(a.getXmlFragment("default"), 0, "one ");
+  t.insert(t.length, "two ");
+  t.insert(t.length, "three");
+  const [first, second, third, fourth] = a.updates;
+  write("skip", [Y.mergeUpdates([first, second, fourth]), third]);
+}
+
+// Surrogate pairs: text with astral characters, split inside a pair
+{
+  const a = doc(105);
+  const t = paragraph(a.getXmlFragment("default"), 0, "a\u{1F600}b\u{1F680}c");
+  t.insert(2, "x"); // Between the halves of U+1F600
+  t.format(6, 2, { bold: {} }); // Starts between the halves of U+1F680
+  t.delete(1, 1); // The high half of U+1F600
+  write("surrogates", a.updates);
+}
+
+// Clients: concurrent edits of two clients, synced both ways
+{
+  const a = doc(106);
+  const b = doc(4294967295); // The largest client ID
+  const fa = a.getXmlFragment("default");
+  const ta = paragraph(fa, 0, "Shared");
+  sync(a, b);
+  const tb = b.getXmlFragment("default").get(0).get(0);
+  ta.insert(6, " by a");
+  tb.insert(0, "B: ");
+  tb.format(0, 2, { bold: {} });
+  paragraph(b.getXmlFragment("default"), 1, "Second paragraph from b");
+  sync(b, a);
+  sync(a, b);
+  ta.delete(0, 3);
+  paragraph(fa, 0, "First from a");
+  write("clients", [...a.updates, ...b.updates]);
+}
@@ -0,0 +1,297 @@
+package yjs
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// Text returns the plain text of a document update, for search indexing:
+// the strings of its root types in document order, with each XML element
+// on lines of its own. Formatting, attributes and deleted text are dropped.
+//
+// Items are put in order the way Y.applyUpdate integrates them, so text
+// typed concurrently comes out as the editor shows it.
+func Text(data []byte) (string, error) {
+	u, err := decodeUpdate(data)
+	if err != nil {
+		return "", err
+	}
+	d := newTextDoc(u)
+
+	for _, client := range sortedClients(d.nodes) {
+		for _, n := range d.nodes[client] {
+			d.integrate(n)
+		}
+	}
+
+	names := make([]string, 0, len(d.roots))
+	for name := range d.roots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		d.roots[name].write(&b)
+		b.WriteByte('\n')
+	}
+
+	var lines []string
+	for _, line := range strings.Split(b.String(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// textType is a shared type: a root type or the type of an item
+type textType struct {
+	element bool // XmlElement, whose text is a block of its own
+	start   *textNode
+}
+
+func (t *textType) write(b *strings.Builder) {
+	for n := t.start; n != nil; n = n.right {
+		if n.deleted {
+			continue
+		}
+		switch {
+		case n.text != nil:
+			b.WriteString(string(utf16.Decode(n.text)))
+		case n.typ != nil:
+			n.typ.write(b)
+			if n.typ.element {
+				b.WriteByte('\n')
+			}
+		}
+	}
+}
+
+// textNode is an item, cut so that every origin, right origin and deleted
+// range refers to whole nodes
+type textNode struct {
+	client, clock, length uint64
+	origin, rightOrigin   *id
+	parentKey             string
+	parentID              *id
+	parentSub             string
+
+	text    []uint16  // String content
+	typ     *textType // Type content
+	deleted bool
+
+	// Set by integrate
+	parent      *textType
+	sub         string // Map key; such items are not part of the sequence
+	left, right *textNode
+	state       byte
+}
+
+const (
+	nodePending = iota
+	nodeIntegrating
+	nodeIntegrated
+	nodeDropped // Depends on an item that is missing or was collected
+)
+
+type textDoc struct {
+	nodes map[uint64][]*textNode // By client, in clock order
+	roots map[string]*textType
+}
+
+func newTextDoc(u *update) *textDoc {
+	d := &textDoc{nodes: map[uint64][]*textNode{}, roots: map[string]*textType{}}
+
+	cuts := map[uint64][]uint64{}
+	for _, structs := range u.structs {
+		for _, s := range structs {
+			if s.origin != nil {
+				cuts[s.origin.client] = append(cuts[s.origin.client], s.origin.clock+1)
+			}
+			if s.rightOrigin != nil {
+				cuts[s.rightOrigin.client] = append(cuts[s.rightOrigin.client], s.rightOrigin.clock)
+			}
+		}
+	}
+	deletes := map[uint64][]span{}
+	for client, spans := range u.deletes {
+		deletes[client] = mergeSpans(spans)
+		for _, r := range deletes[client] {
+			cuts[client] = append(cuts[client], r.clock, r.clock+r.length)
+		}
+	}
+
+	for client, structs := range u.structs {
+		at := cuts[client]
+		sort.Slice(at, func(i, j int) bool { return at[i] < at[j] })
+		for _, s := range mergeStructs(structs) {
+			if s.content == nil {
+				continue // GC and Skip
+			}
+			i := sort.Search(len(at), func(i int) bool { return at[i] > s.clock })
+			for start := s.clock; start < s.end(); {
+				cut := s.end()
+				if i < len(at) && at[i] < cut {
+					cut = at[i]
+				}
+				n := newTextNode(s, start, cut)
+				n.deleted = n.deleted || covered(deletes[client], start)
+				d.nodes[client] = append(d.nodes[client], n)
+				for start = cut; i < len(at) && at[i] <= start; i++ {
+				}
+			}
+		}
+	}
+	return d
+}
+
+// newTextNode makes the node for the clocks [from, to) of s. Parts after
+// the first continue from the one before, like items Yjs splits.
+func newTextNode(s *ystruct, from, to uint64) *textNode {
+	n := &textNode{client: s.client, clock: from, length: to - from, rightOrigin: s.rightOrigin}
+	if from == s.clock {
+		n.origin, n.parentKey, n.parentID, n.parentSub = s.origin, s.parentKey, s.parentID, s.parentSub
+	} else {
+		n.origin = &id{s.client, from - 1}
+	}
+
+	switch c := s.content.(type) {
+	case deletedContent:
+		n.deleted = true
+	case stringContent:
+		n.text = c[from-s.clock : to-s.clock]
+	case atomContent:
+		if s.ref == contentType {
+			n.typ = &textType{element: len(c) > 0 && c[0] == typeXmlElement}
+		}
+	}
+	return n
+}
+
+// covered reports whether clock is in one of the sorted spans
+func covered(spans []span, clock uint64) bool {
+	i := sort.Search(len(spans), func(i int) bool { return spans[i].clock+spans[i].length > clock })
+	return i < len(spans) && spans[i].clock <= clock
+}
+
+// find returns the node holding the given ID
+func (d *textDoc) find(ref *id) *textNode {
+	nodes := d.nodes[ref.client]
+	i := sort.Search(len(nodes), func(i int) bool { return nodes[i].clock+nodes[i].length > ref.clock })
+	if i < len(nodes) && nodes[i].clock <= ref.clock {
+		return nodes[i]
+	}
+	return nil
+}
+
+func (d *textDoc) root(name string) *textType {
+	t, ok := d.roots[name]
+	if !ok {
+		t = &textType{}
+		d.roots[name] = t
+	}
+	return t
+}
+
+// integrate places n among its siblings after the items it depends on,
+// resolving concurrent inserts at the same place as Yjs does (YATA)
+func (d *textDoc) integrate(n *textNode) bool {
+	switch n.state {
+	case nodeIntegrated:
+		return true
+	case nodeIntegrating, nodeDropped:
+		return false
+	}
+	n.state = nodeIntegrating
+
+	var left, right *textNode
+	if n.origin != nil {
+		if left = d.find(n.origin); left == nil || !d.integrate(left) {
+			n.state = nodeDropped
+			return false
+		}
+	}
+	if n.rightOrigin != nil {
+		if right = d.find(n.rightOrigin); right == nil || !d.integrate(right) {
+			n.state = nodeDropped
+			return false
+		}
+	}
+
+	switch {
+	case left != nil:
+		n.parent, n.sub = left.parent, left.sub
+	case right != nil:
+		n.parent, n.sub = right.parent, right.sub
+	case n.parentID != nil:
+		if p := d.find(n.parentID); p != nil && d.integrate(p) && p.typ != nil {
+			n.parent = p.typ
+		}
+		n.sub = n.parentSub
+	default:
+		n.parent, n.sub = d.root(n.parentKey), n.parentSub
+	}
+	if n.parent == nil {
+		n.state = nodeDropped
+		return false
+	}
+	n.state = nodeIntegrated
+	if n.sub != "" {
+		return true
+	}
+
+	if (left == nil && (right == nil || right.left != nil)) || (left != nil && left.right != right) {
+		o := n.parent.start
+		if left != nil {
+			o = left.right
+		}
+		conflicting := map[*textNode]bool{}
+		beforeOrigin := map[*textNode]bool{}
+		for o != nil && o != right {
+			beforeOrigin[o] = true
+			conflicting[o] = true
+			if sameID(n.origin, o.origin) {
+				if o.client < n.client {
+					left = o
+					clear(conflicting)
+				} else if sameID(n.rightOrigin, o.rightOrigin) {
+					break
+				}
+			} else if oo := o.originNode(d); oo != nil && beforeOrigin[oo] {
+				if !conflicting[oo] {
+					left = o
+					clear(conflicting)
+				}
+			} else {
+				break
+			}
+			o = o.right
+		}
+	}
+
+	n.left = left
+	if left != nil {
+		n.right, left.right = left.right, n
+	} else {
+		n.right, n.parent.start = n.parent.start, n
+	}
+	if n.right != nil {
+		n.right.left = n
+	}
+	return true
+}
+
+func (n *textNode) originNode(d *textDoc) *textNode {
+	if n.origin == nil {
+		return nil
+	}
+	return d.find(n.origin)
+}
+
+func sameID(a, b *id) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,70 @@
+package yjs
+
+import (
+	"testing"
+
+	"github.com/pranavdhawale/notex/server/internal/models"
+)
+
+func TestTextOfProseMirror(t *testing.T) {
+	doc := models.DocNode{Type: "doc", Content: []models.DocNode{
+		{Type: "heading", Attrs: map[string]interface{}{"level": float64(1)}, Content: []models.DocNode{
+			{Type: "text", Text: "Weekly sync"},
+		}},
+		{Type: "bulletList", Content: []models.DocNode{
+			{Type: "listItem", Content: []models.DocNode{{Type: "paragraph", Content: []models.DocNode{
+				{Type: "text", Text: "ship the "},
+				{Type: "text", Text: "search", Marks: []models.DocMark{{Type: "bold"}}},
+				{Type: "text", Text: " index"},
+			}}}},
+		}},
+	}}
+	update, err := EncodeProseMirror(doc, DefaultFragment, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Text(update)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Weekly sync\nship the search index"; got != want {
+		t.Errorf("Text = %q, want %q", got, want)
+	}
+}
+
+func TestTextOrdersAndDeletes(t *testing.T) {
+	for name, tt := range map[string]struct {
+		update []byte
+		want   string
+	}{
+		"insert inside": {textUpdate([]textItem{
+			{clock: 0, text: "helo"},
+			{clock: 4, origin: &id{1, 2}, text: "l"},
+		}), "hello"},
+		"deleted range":  {textUpdate([]textItem{{clock: 0, text: "hello world"}}, span{5, 6}), "hello"},
+		"missing origin": {textUpdate([]textItem{{clock: 5, origin: &id{1, 4}, text: "lost"}}), ""},
+	} {
+		got, err := Text(tt.update)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: Text = %q, want %q", name, got, tt.want)
+		}
+	}
+}
+
+func TestTextConcurrentInserts(t *testing.T) {
+	// Two clients typing at the start of the same empty text: the lower
+	// client ID goes first, whichever update is applied first
+	first := []byte{1, 1, 2, 0, contentString, 1, 1, 't', 2, 'b', 'b', 0}
+	second := []byte{1, 1, 1, 0, contentString, 1, 1, 't', 2, 'a', 'a', 0}
+	merged, err := MergeUpdates(first, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := Text(merged); got != "aabb" {
+		t.Errorf("Text = %q, want %q", got, "aabb")
+	}
+}
@@ -0,0 +1,292 @@
+package yjs
+
+import (
+	"unicode/utf16"
+)
+
+// Struct references of the update format besides the item contents
+const (
+	structGC   = 0
+	structSkip = 10
+
+	contentDeleted = 1
+	contentJSON    = 2
+	contentBinary  = 3
+	contentEmbed   = 5
+	contentDoc     = 9
+
+	typeXmlHook = 5
+)
+
+// update is a decoded Yjs update: the structs of each client, in clock
+// order, and the delete set
+type update struct {
+	structs map[uint64][]*ystruct
+	deletes map[uint64][]span
+}
+
+type span struct {
+	clock, length uint64
+}
+
+// ystruct is a GC, Skip or item struct. Item contents are kept in a form
+// that can be split and written back.
+type ystruct struct {
+	ref           byte // Struct or content reference
+	client, clock uint64
+	length        uint64
+
+	origin, rightOrigin *id
+	parentKey           string // Root type name, when the item names its parent
+	parentID            *id    // Parent type item, when the item names its parent
+	parentSub           string
+	hasParentSub        bool
+
+	content content // Nil for GC and Skip
+}
+
+func (s *ystruct) end() uint64 {
+	return s.clock + s.length
+}
+
+// content is the payload of an item
+type content interface {
+	length() uint64
+	// splice returns the content from offset on. Only called with an offset
+	// inside content longer than one.
+	splice(offset uint64) content
+	write(e *encoder)
+}
+
+type deletedContent uint64
+
+func (c deletedContent) length() uint64            { return uint64(c) }
+func (c deletedContent) splice(off uint64) content { return c - deletedContent(off) }
+func (c deletedContent) write(e *encoder)          { e.varUint(uint64(c)) }
+
+type jsonContent []string
+
+func (c jsonContent) length() uint64            { return uint64(len(c)) }
+func (c jsonContent) splice(off uint64) content { return c[off:] }
+func (c jsonContent) write(e *encoder) {
+	e.varUint(uint64(len(c)))
+	for _, s := range c {
+		e.varString(s)
+	}
+}
+
+type anyContent [][]byte // Encoded values
+
+func (c anyContent) length() uint64            { return uint64(len(c)) }
+func (c anyContent) splice(off uint64) content { return c[off:] }
+func (c anyContent) write(e *encoder) {
+	e.varUint(uint64(len(c)))
+	for _, v := range c {
+		e.buf = append(e.buf, v...)
+	}
+}
+
+// stringContent is measured in UTF-16 code units, like JavaScript strings
+type stringContent []uint16
+
+func (c stringContent) length() uint64 { return uint64(len(c)) }
+
+// splice replaces a surrogate pair cut in half with U+FFFD on both sides,
+// as Yjs does
+func (c stringContent) splice(off uint64) content {
+	right := c[off:]
+	if utf16.IsSurrogate(rune(c[off-1])) && c[off-1] < 0xdc00 {
+		right = append(stringContent{0xfffd}, right[1:]...)
+	}
+	return right
+}
+
+func (c stringContent) write(e *encoder) {
+	e.varString(string(utf16.Decode(c)))
+}
+
+// atomContent is any content of length one, kept as encoded
+type atomContent []byte
+
+func (c atomContent) length() uint64            { return 1 }
+func (c atomContent) splice(off uint64) content { panic("yjs: splitting content of length one") }
+func (c atomContent) write(e *encoder)          { e.buf = append(e.buf, c...) }
+
+// decodeUpdate parses a v1 update, as produced by Y.encodeStateAsUpdate
+func decodeUpdate(data []byte) (*update, error) {
+	d := &decoder{buf: data}
+	u := &update{structs: map[uint64][]*ystruct{}, deletes: map[uint64][]span{}}
+
+	for clients := d.varUint(); clients > 0 && d.err == nil; clients-- {
+		count := d.varUint()
+		client := d.clock()
+		clock := d.clock()
+		for ; count > 0 && d.err == nil; count-- {
+			s := d.ystruct(client, clock)
+			if d.err != nil {
+				break
+			}
+			if s.length == 0 || s.end() > maxClock {
+				d.fail()
+				break
+			}
+			u.structs[client] = append(u.structs[client], s)
+			clock = s.end()
+		}
+	}
+
+	for clients := d.varUint(); clients > 0 && d.err == nil; clients-- {
+		client := d.clock()
+		for ranges := d.varUint(); ranges > 0 && d.err == nil; ranges-- {
+			r := span{d.clock(), d.clock()}
+			if r.clock+r.length > maxClock {
+				d.fail()
+			}
+			if r.length > 0 {
+				u.deletes[client] = append(u.deletes[client], r)
+			}
+		}
+	}
+
+	if d.err == nil && d.pos != len(d.buf) {
+		d.fail() // Trailing bytes
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return u, nil
+}
+
+func (d *decoder) id() *id {
+	return &id{d.clock(), d.clock()}
+}
+
+func (d *decoder) ystruct(client, clock uint64) *ystruct {
+	info := d.byte()
+	s := &ystruct{ref: info & 0x1f, client: client, clock: clock}
+
+	switch s.ref {
+	case structGC, structSkip:
+		s.length = d.clock()
+		return s
+	}
+
+	if info&hasOrigin != 0 {
+		s.origin = d.id()
+	}
+	if info&hasRightOrigin != 0 {
+		s.rightOrigin = d.id()
+	}
+	s.hasParentSub = info&hasParentSub != 0
+	if s.origin == nil && s.rightOrigin == nil {
+		if d.varUint() == 1 {
+			s.parentKey = d.varString()
+		} else {
+			s.parentID = d.id()
+		}
+		if s.hasParentSub {
+			s.parentSub = d.varString()
+		}
+	}
+
+	start := d.pos
+	switch s.ref {
+	case contentDeleted:
+		s.content = deletedContent(d.clock())
+	case contentJSON:
+		var c jsonContent
+		for n := d.varUint(); n > 0 && d.err == nil; n-- {
+			c = append(c, d.varString())
+		}
+		s.content = c
+	case contentBinary:
+		d.varBytes()
+	case contentString:
+		s.content = stringContent(utf16.Encode([]rune(d.varString())))
+	case contentEmbed:
+		d.varString()
+	case contentFormat:
+		d.varString()
+		d.varString()
+	case contentType:
+		if ref := d.varUint(); ref == typeXmlElement || ref == typeXmlHook {
+			d.varString()
+		}
+	case contentAny:
+		var c anyContent
+		for n := d.varUint(); n > 0 && d.err == nil; n-- {
+			c = append(c, d.any())
+		}
+		s.content = c
+	case contentDoc:
+		d.varString()
+		d.any()
+	default:
+		d.fail()
+	}
+	if d.err != nil {
+		return nil
+	}
+	if s.content == nil {
+		s.content = atomContent(d.buf[start:d.pos])
+	}
+	s.length = s.content.length()
+	return s
+}
+
+// slice returns the part of s from offset on, which like in Yjs is an item
+// whose left origin is the part cut off
+func (s *ystruct) slice(offset uint64) *ystruct {
+	right := *s
+	right.clock += offset
+	right.length -= offset
+	if s.content != nil {
+		right.origin = &id{s.client, s.clock + offset - 1}
+		right.parentKey, right.parentID = "", nil
+		right.content = s.content.splice(offset)
+	}
+	return &right
+}
+
+func (s *ystruct) write(e *encoder) {
+	if s.content == nil {
+		e.byte(s.ref)
+		e.varUint(s.length)
+		return
+	}
+
+	info := s.ref
+	if s.origin != nil {
+		info |= hasOrigin
+	}
+	if s.rightOrigin != nil {
+		info |= hasRightOrigin
+	}
+	if s.hasParentSub {
+		info |= hasParentSub
+	}
+	e.byte(info)
+
+	if s.origin != nil {
+		e.varUint(s.origin.client)
+		e.varUint(s.origin.clock)
+	}
+	if s.rightOrigin != nil {
+		e.varUint(s.rightOrigin.client)
+		e.varUint(s.rightOrigin.clock)
+	}
+	if s.origin == nil && s.rightOrigin == nil {
+		if s.parentID == nil {
+			e.varUint(1)
+			e.varString(s.parentKey)
+		} else {
+			e.varUint(0)
+			e.varUint(s.parentID.client)
+			e.varUint(s.parentID.clock)
+		}
+		if s.hasParentSub {
+			e.varString(s.parentSub)
+		}
+	}
+	s.content.write(e)
+}
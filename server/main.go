@@ -1,29 +1,74 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gin-contrib/cors"
 	"github.com/pranavdhawale/notex/server/internal/api"
+	"github.com/pranavdhawale/notex/server/internal/config"
+	"github.com/pranavdhawale/notex/server/internal/search"
 	"github.com/pranavdhawale/notex/server/internal/state"
+	"github.com/pranavdhawale/notex/server/internal/web"
 	"github.com/pranavdhawale/notex/server/internal/ws"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 func main() {
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
 	// Set Gin mode
-	if os.Getenv("GIN_MODE") == "release" {
-		gin.SetMode(gin.ReleaseMode)
+	gin.SetMode(cfg.Server.Mode)
+
+	// Cancelled on SIGINT/SIGTERM to start a graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var rooms state.RoomStore
+	var files state.FileStore
+	var templates state.TemplateStore
+	var mongoDB *mongo.Database
+	var boltDB *state.BoltDB
+	switch cfg.Storage.Backend {
+	case config.BackendMemory:
+		log.Println("Using in-memory storage; rooms will not survive a restart")
+		rooms, files = state.NewMemoryRoomStore(), state.NewMemoryFileStore()
+		templates = state.NewMemoryTemplateStore()
+	case config.BackendBolt:
+		boltDB, err = state.OpenBolt(cfg.Storage.BoltPath, state.Codec(cfg.Storage.Compression))
+		if err != nil {
+			log.Fatalf("Failed to open embedded database: %v", err)
+		}
+		rooms, files, templates = boltDB.Rooms(), boltDB.Files(), boltDB.Templates()
+		go boltDB.SweepExpired(ctx, cfg.Storage.SweepInterval)
+	default:
+		mongoDB = state.InitMongo(cfg.Mongo)
+		mongoRooms := state.NewMongoRoomStore(mongoDB, state.Codec(cfg.Storage.Compression))
+		rooms, files = mongoRooms, state.NewMongoFileStore(mongoDB)
+		templates = state.NewMongoTemplateStore(mongoDB)
+		go mongoRooms.SweepChunks(ctx, cfg.Storage.ChunkSweepInterval)
 	}
 
-	mongoURI := os.Getenv("MONGO_URI")
-	if mongoURI == "" {
-		mongoURI = "mongodb://localhost:27017"
+	// Rooms of the memory backend die with the process, so their index does too
+	searchDir := cfg.Search.Dir
+	if cfg.Storage.Backend == config.BackendMemory {
+		searchDir = ""
+	}
+	index, err := search.Open(searchDir)
+	if err != nil {
+		log.Fatalf("Failed to open search index: %v", err)
 	}
-	state.InitMongo(mongoURI, "notex")
 
 	//redisAddr := os.Getenv("REDIS_ADDR")
 	//if redisAddr == "" {
@@ -33,19 +78,13 @@ func main() {
 	//state.InitRedis(redisAddr, redisPassword)
 
 	r := gin.Default()
-	
-	// CORS Configuration
-	clientOrigin := os.Getenv("CLIENT_ORIGIN")
-	if clientOrigin == "" {
-		clientOrigin = "http://localhost:5173"
-	}
 
 	// CORS Configuration
 	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{clientOrigin},
+		AllowOrigins:     cfg.Server.CORSOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "X-User-ID"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "X-User-ID", "If-Match", "If-None-Match"},
+		ExposeHeaders:    []string{"Content-Length", "X-Total-Count", "ETag"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -58,38 +97,108 @@ func main() {
 		})
 	})
 
+	hub := ws.NewHub(cfg.WebSocket, rooms)
+	h, err := api.NewHandler(cfg, hub, rooms, files, templates, index)
+	if err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+	hub.OnViewerLeft(h.ViewerLeft)
+
 	// API Routes
 	apiGroup := r.Group("/api")
 	{
-		apiGroup.POST("/rooms", api.CreateRoom)
-		apiGroup.GET("/rooms/:room", api.GetRoom)
-		apiGroup.DELETE("/rooms/:room", api.DeleteRoom)
-		apiGroup.POST("/rooms/:room/save", api.SaveRoom)
+		apiGroup.POST("/rooms", h.CreateRoom)
+		apiGroup.GET("/rooms", h.ListRooms)
+		apiGroup.GET("/rooms/:room", h.GetRoom)
+		apiGroup.PATCH("/rooms/:room", h.UpdateRoom)
+		apiGroup.DELETE("/rooms/:room", h.DeleteRoom)
+		apiGroup.POST("/rooms/:room/save", h.SaveRoom)
+		apiGroup.GET("/rooms/:room/export", h.ExportRoom)
+		apiGroup.POST("/rooms/:room/fork", h.ForkRoom)
+		apiGroup.POST("/rooms/:room/extend", h.ExtendRoom)
+		apiGroup.GET("/rooms/:room/extend", h.ConfirmExtend) // Links in warning emails
+		apiGroup.GET("/slugs/:slug", h.CheckSlug)
+		apiGroup.GET("/search", h.Search)
+		apiGroup.GET("/templates", h.ListTemplates)
+		apiGroup.POST("/templates", h.SaveTemplate)
+		apiGroup.DELETE("/templates/:id", h.DeleteTemplate)
 		
 		// File Sharing
-		apiGroup.POST("/upload/:room", api.UploadFile)
-		apiGroup.GET("/rooms/:room/files", api.ListFiles)
-		apiGroup.DELETE("/rooms/:room/files/:fileId", api.DeleteFile)
+		apiGroup.POST("/upload/:room", h.UploadFile)
+		apiGroup.GET("/rooms/:room/files", h.ListFiles)
+		apiGroup.PATCH("/rooms/:room/files/:fileId", h.UpdateFile)
+		apiGroup.DELETE("/rooms/:room/files/:fileId", h.DeleteFile)
 	}
 
-	// Static Uploads
-	r.Static("/uploads", "./uploads")
+	// Uploads, resolved through the room so renamed rooms keep their links
+	r.GET("/uploads/:room/:file", h.ServeUpload)
+
+	// Built Client (single-binary mode)
+	if cfg.Client.Serve {
+		clientFiles, err := web.Files(cfg.Client.Dir)
+		if err != nil {
+			log.Fatalf("Failed to load client build: %v", err)
+		}
+		r.NoRoute(web.NewSPA(clientFiles, "/api", "/ws", "/uploads", "/health").Handle)
+		log.Println("Serving client from the Go server")
+	}
 
 	// Start WebSocket Hub
-	go ws.MainHub.Run()
+	go hub.Run()
+	go hub.WatchExpiry(ctx, cfg.Rooms.ExpiryCheckInterval)
+	go h.RefreshOnActivity(ctx, hub.Activity())
+	go h.FillIndex(ctx)
+	go h.WatchExpiring(ctx)
 
 	// WebSocket Route
 	r.GET("/ws/:room", func(c *gin.Context) {
-		ws.ServeWs(ws.MainHub, c)
+		ws.ServeWs(hub, c)
 	})
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%d", cfg.Server.Port),
+		Handler: r,
 	}
 
-	log.Printf("Server starting on port %s", port)
-	if err := r.Run(":" + port); err != nil {
-		log.Fatalf("Failed to run server: %v", err)
+	go func() {
+		log.Printf("Server starting on port %d", cfg.Server.Port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to run server: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	log.Println("Shutting down server...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	// 1. Tell WebSocket clients we're restarting (hijacked connections are
+	// not tracked by http.Server, so they have to be closed separately)
+	if err := hub.Shutdown(shutdownCtx); err != nil {
+		log.Printf("WebSocket clients did not disconnect cleanly: %v", err)
+	}
+
+	// 2. Stop accepting connections and wait for in-flight requests (uploads)
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP server did not shut down cleanly: %v", err)
 	}
+
+	// 3. Disconnect Mongo / close the embedded database
+	if mongoDB != nil {
+		if err := mongoDB.Client().Disconnect(shutdownCtx); err != nil {
+			log.Printf("Failed to disconnect MongoDB: %v", err)
+		}
+	}
+	if boltDB != nil {
+		if err := boltDB.Close(); err != nil {
+			log.Printf("Failed to close embedded database: %v", err)
+		}
+	}
+	if err := index.Close(); err != nil {
+		log.Printf("Failed to close search index: %v", err)
+	}
+
+	log.Println("Server stopped")
 }